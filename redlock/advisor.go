@@ -0,0 +1,78 @@
+package redlock
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// default parameters of TTLAdvisor
+const (
+	DefaultAdvisorWindow = 1000
+	DefaultSafetyFactor  = 2.0
+)
+
+// TTLAdvisor suggests the ttl of a lock from the observed durations of the
+// operations it protects. A ttl that is too short expires the lock in the
+// middle of the work, while a ttl that is too long delays the recovery after
+// the holder crashes. TTLAdvisor is safe for concurrent use.
+type TTLAdvisor struct {
+	factor float64
+
+	mu      sync.Mutex
+	samples []time.Duration
+	// next is the position in samples that the next report overwrites once
+	// the window is full
+	next int
+	size int
+}
+
+// NewTTLAdvisor creates a TTLAdvisor that keeps the latest window durations
+// and suggests the p99 of them multiplied by factor. Non-positive arguments
+// are replaced by DefaultAdvisorWindow and DefaultSafetyFactor.
+func NewTTLAdvisor(window int, factor float64) *TTLAdvisor {
+	if window <= 0 {
+		window = DefaultAdvisorWindow
+	}
+	if factor <= 0 {
+		factor = DefaultSafetyFactor
+	}
+	return &TTLAdvisor{
+		factor:  factor,
+		samples: make([]time.Duration, window),
+	}
+}
+
+// Report records the completion time of a protected operation
+func (a *TTLAdvisor) Report(d time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.samples[a.next] = d
+	a.next = (a.next + 1) % len(a.samples)
+	if a.size < len(a.samples) {
+		a.size++
+	}
+}
+
+// Suggest returns the p99 of the recorded durations multiplied by the safety
+// factor, rounded up to millisecond which is the ttl resolution of redis. It
+// returns zero if nothing is reported yet, callers should fall back to their
+// own ttl then.
+func (a *TTLAdvisor) Suggest() time.Duration {
+	a.mu.Lock()
+	sorted := make([]time.Duration, a.size)
+	copy(sorted, a.samples[:a.size])
+	a.mu.Unlock()
+	if len(sorted) == 0 {
+		return 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	// nearest-rank percentile
+	rank := int(math.Ceil(0.99*float64(len(sorted)))) - 1
+	ttl := time.Duration(float64(sorted[rank]) * a.factor)
+	if rem := ttl % time.Millisecond; rem != 0 {
+		ttl += time.Millisecond - rem
+	}
+	return ttl
+}
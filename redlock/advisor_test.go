@@ -0,0 +1,32 @@
+package redlock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTLAdvisor(t *testing.T) {
+	a := NewTTLAdvisor(0, 0)
+	assert.Len(t, a.samples, DefaultAdvisorWindow)
+	assert.Equal(t, DefaultSafetyFactor, a.factor)
+	assert.Zero(t, a.Suggest())
+
+	a = NewTTLAdvisor(100, 1.5)
+	for i := 1; i <= 100; i++ {
+		a.Report(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(t, 149*time.Millisecond, a.Suggest())
+
+	// only the latest window durations are kept
+	for i := 0; i < 100; i++ {
+		a.Report(10 * time.Millisecond)
+	}
+	assert.Equal(t, 15*time.Millisecond, a.Suggest())
+
+	// rounded up to millisecond
+	a = NewTTLAdvisor(10, 2)
+	a.Report(100 * time.Microsecond)
+	assert.Equal(t, time.Millisecond, a.Suggest())
+}
@@ -0,0 +1,118 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// errNoResources means no resource is given to a batch of locks
+var errNoResources = errors.New("no resource to lock")
+
+// LockAligned acquires the locks of all resources with ttl, and aligns them
+// to a shared validity window, which ends when the earliest of their
+// validities ends. The local cache entry of every lock expires at the shared
+// deadline, so the locks are held or lost together, and a single renewal of
+// ExtendAligned keeps them aligned. It returns the shared validity.
+//
+// The locks are acquired one by one in the order of resource names, so
+// concurrent callers on overlapping resources don't deadlock, and the earlier
+// locks spend their validity while the later ones are acquired. If any lock
+// fails to be acquired, the acquired ones are released and the error is
+// returned.
+func (r *RedLock) LockAligned(ctx context.Context, resources []string, ttl time.Duration) (time.Duration, error) {
+	sorted := sortedResources(resources)
+	if len(sorted) == 0 {
+		return 0, errNoResources
+	}
+	vals := make([]string, 0, len(sorted))
+	var deadline time.Time
+	for _, resource := range sorted {
+		l, err := r.Acquire(ctx, resource, ttl)
+		if err != nil {
+			r.releaseAligned(sorted[:len(vals)])
+			return 0, err
+		}
+		vals = append(vals, l.val)
+		if expiresAt := l.acquiredAt.Add(l.Validity()); deadline.IsZero() || expiresAt.Before(deadline) {
+			deadline = expiresAt
+		}
+	}
+	validity, err := r.alignTo(sorted, vals, deadline)
+	if err != nil {
+		r.releaseAligned(sorted)
+		return 0, err
+	}
+	return validity, nil
+}
+
+// ExtendAligned extends the locks of resources held by r with ttl and aligns
+// them to a shared validity window again, see LockAligned. It returns the
+// shared validity, or the error of the first lock that failed to be
+// extended, in which case the locks extended before it are left extended.
+func (r *RedLock) ExtendAligned(ctx context.Context, resources []string, ttl time.Duration) (time.Duration, error) {
+	sorted := sortedResources(resources)
+	if len(sorted) == 0 {
+		return 0, errNoResources
+	}
+	vals := make([]string, 0, len(sorted))
+	var deadline time.Time
+	for _, resource := range sorted {
+		start := time.Now()
+		validity, err := r.Extend(ctx, resource, ttl)
+		if err != nil {
+			return 0, err
+		}
+		elem, err := r.cache.Get(resource)
+		if err != nil {
+			return 0, err
+		}
+		if elem == nil {
+			return 0, ErrLockNotHeld
+		}
+		vals = append(vals, elem.Val)
+		if expiresAt := start.Add(validity); deadline.IsZero() || expiresAt.Before(deadline) {
+			deadline = expiresAt
+		}
+	}
+	return r.alignTo(sorted, vals, deadline)
+}
+
+// alignTo sets the cache entries of resources with vals to expire at
+// deadline, returns the validity remaining to deadline
+func (r *RedLock) alignTo(resources, vals []string, deadline time.Time) (time.Duration, error) {
+	validity := time.Until(deadline)
+	if validity <= 0 {
+		return 0, ErrNoValidity
+	}
+	for idx, resource := range resources {
+		if _, err := r.cache.Set(resource, vals[idx], int64(validity)); err != nil {
+			return 0, err
+		}
+	}
+	return validity, nil
+}
+
+// releaseAligned releases the locks of resources acquired by LockAligned,
+// regardless of the ctx of acquisition which may be done
+func (r *RedLock) releaseAligned(resources []string) {
+	if len(resources) > 0 {
+		r.ReleaseAll(context.Background(), resources...) // nolint:errcheck
+	}
+}
+
+// sortedResources returns the distinct resources in sorted order
+func sortedResources(resources []string) []string {
+	sorted := make([]string, 0, len(resources))
+	seen := make(map[string]struct{}, len(resources))
+	for _, resource := range resources {
+		if _, ok := seen[resource]; ok {
+			continue
+		}
+		seen[resource] = struct{}{}
+		sorted = append(sorted, resource)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,67 @@
+package redlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockAligned(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	_, err = lock.LockAligned(ctx, nil, time.Second)
+	assert.NotNil(t, err)
+
+	resources := []string{"aligned-b", "aligned-a", "aligned-b", "aligned-c"}
+	validity, err := lock.LockAligned(ctx, resources, time.Second)
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(0))
+	assert.Less(t, int64(validity), int64(time.Second))
+	expiry := func() []time.Duration {
+		remaining := make([]time.Duration, 0, 3)
+		for _, resource := range []string{"aligned-a", "aligned-b", "aligned-c"} {
+			elem, err := lock.cache.Get(resource)
+			assert.Nil(t, err)
+			remaining = append(remaining, time.Duration(elem.remaining()))
+		}
+		return remaining
+	}
+	// the locks expire at the same deadline
+	remaining := expiry()
+	for _, d := range remaining[1:] {
+		assert.InDelta(t, int64(remaining[0]), int64(d), float64(5*time.Millisecond))
+	}
+
+	validity, err = lock.ExtendAligned(ctx, resources, 2*time.Second)
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(time.Second))
+	remaining = expiry()
+	for _, d := range remaining[1:] {
+		assert.InDelta(t, int64(remaining[0]), int64(d), float64(5*time.Millisecond))
+	}
+	assert.Nil(t, lock.ReleaseAll(ctx, resources...))
+
+	// a lock held by others fails the batch, the acquired locks are released
+	other, err := NewRedLock(ctx, redisServers, WithRetryCount(1))
+	assert.Nil(t, err)
+	defer other.Close()
+	_, err = other.Lock(ctx, "aligned-b", time.Second)
+	assert.Nil(t, err)
+	lock.SetRetryCount(1)
+	_, err = lock.LockAligned(ctx, resources, time.Second)
+	assert.NotNil(t, err)
+	assert.False(t, lock.Owns("aligned-a"))
+	for _, cli := range lock.clients {
+		assert.Equal(t, redis.Nil, rawClient(cli).Get(ctx, "aligned-a").Err())
+	}
+	assert.Nil(t, other.UnLock(ctx, "aligned-b"))
+
+	_, err = lock.ExtendAligned(ctx, resources, time.Second)
+	assert.Equal(t, ErrLockNotHeld, err)
+}
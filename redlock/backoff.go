@@ -0,0 +1,23 @@
+package redlock
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithRetryBackoff makes retries wait a random delay with exponential backoff
+// instead of a random delay up to the retry delay, it is the same as
+// ExponentialJitterRetry with the retry count. The n-th retry waits a random
+// delay in [min, min*2^n], capped by max, so retries never spin without sleep
+// under heavy contention, and keep backing off until max. Both min and max
+// must be positive and min must not exceed max.
+func WithRetryBackoff(min, max time.Duration) LockOption {
+	return func(r *RedLock) {
+		if min <= 0 || min > max {
+			r.setOptErr(fmt.Errorf("invalid retry backoff [%s, %s], must be positive and min <= max", min, max))
+			return
+		}
+		r.backoffMin = min
+		r.backoffMax = max
+	}
+}
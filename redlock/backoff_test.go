@@ -0,0 +1,66 @@
+package redlock
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBackoffOption(t *testing.T) {
+	ctx := context.Background()
+	for _, bounds := range [][2]time.Duration{{0, time.Second}, {-time.Millisecond, time.Second}, {time.Second, time.Millisecond}} {
+		_, err := NewRedLock(ctx, []string{"tcp://mock0:6379"}, WithRetryBackoff(bounds[0], bounds[1]))
+		assert.NotNil(t, err, "%v", bounds)
+	}
+
+	lock := newMockRedLockWithOptions(t, []redisCmdable{&mockCmdable{}},
+		WithRetryCount(101), WithRetryBackoff(time.Millisecond, 10*time.Millisecond))
+	retry := lock.retry()
+	for attempt := 1; attempt <= 100; attempt++ {
+		wait, ok := retry.Next(attempt, 0)
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, int64(wait), int64(time.Millisecond))
+		assert.LessOrEqual(t, int64(wait), int64(10*time.Millisecond))
+		if attempt == 1 {
+			assert.LessOrEqual(t, int64(wait), int64(2*time.Millisecond))
+		}
+	}
+	_, ok := retry.Next(101, 0)
+	assert.False(t, ok)
+}
+
+func TestMockRetryBackoff(t *testing.T) {
+	ctx := context.Background()
+	var (
+		mu    sync.Mutex
+		calls []time.Time
+	)
+	failed := &mockCmdable{
+		setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+			mu.Lock()
+			calls = append(calls, time.Now())
+			mu.Unlock()
+			return false, nil
+		},
+	}
+	held := &mockCmdable{
+		setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+			return false, nil
+		},
+	}
+	floor := 20 * time.Millisecond
+	lock := newMockRedLockWithOptions(t, []redisCmdable{failed, held, &mockCmdable{}},
+		WithRetryCount(5), WithRetryBackoff(floor, 50*time.Millisecond))
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	assert.NotNil(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, calls, 5)
+	for i := 1; i < len(calls); i++ {
+		assert.GreaterOrEqual(t, int64(calls[i].Sub(calls[i-1])), int64(floor))
+	}
+}
@@ -0,0 +1,227 @@
+package redlock
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// benchRedLock creates a RedLock on the redis instances listed in env
+// REDLOCK_BENCH_SERVERS (comma separated host:port), or the test servers by
+// default. The benchmark is skipped if any instance is unavailable.
+func benchRedLock(b *testing.B, opts ...Option) *RedLock {
+	ctx := context.Background()
+	addrs := make([]string, 0, len(redisServers))
+	if env := os.Getenv("REDLOCK_BENCH_SERVERS"); env != "" {
+		addrs = strings.Split(env, ",")
+	} else {
+		for _, server := range redisServers {
+			addrs = append(addrs, strings.TrimPrefix(server, "tcp://"))
+		}
+	}
+	clis := make([]*redis.Client, 0, len(addrs))
+	for _, addr := range addrs {
+		cli := redis.NewClient(&redis.Options{Addr: addr})
+		if err := cli.Ping(ctx).Err(); err != nil {
+			b.Skipf("redis %s is unavailable: %s", addr, err)
+		}
+		clis = append(clis, cli)
+	}
+	lock, err := NewRedLockFromClients(ctx, clis, opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return lock
+}
+
+func BenchmarkLock(b *testing.B) {
+	ctx := context.Background()
+	lock := benchRedLock(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resource := "bench_lock_" + strconv.Itoa(i)
+		if _, err := lock.Lock(ctx, resource, time.Second); err != nil {
+			b.Fatal(err)
+		}
+		b.StopTimer()
+		lock.UnLock(ctx, resource)
+		b.StartTimer()
+	}
+}
+
+func BenchmarkTryLock(b *testing.B) {
+	ctx := context.Background()
+	lock := benchRedLock(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m := lock.NewMutex("bench_trylock_"+strconv.Itoa(i), time.Second)
+		ok, err := m.TryLock(ctx)
+		if err != nil || !ok {
+			b.Fatal(ok, err)
+		}
+		b.StopTimer()
+		m.Unlock(ctx)
+		b.StartTimer()
+	}
+}
+
+func BenchmarkUnLock(b *testing.B) {
+	ctx := context.Background()
+	lock := benchRedLock(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		resource := "bench_unlock_" + strconv.Itoa(i)
+		b.StopTimer()
+		if _, err := lock.Lock(ctx, resource, time.Second); err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+		if err := lock.UnLock(ctx, resource); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkChurn measures acquiring and releasing a lock by churn
+func benchmarkChurn(b *testing.B, churn func(ctx context.Context, lock *RedLock, resource string) error) {
+	ctx := context.Background()
+	lock := benchRedLock(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := churn(ctx, lock, "bench_churn_"+strconv.Itoa(i%1024)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAcquireChurn(b *testing.B) {
+	benchmarkChurn(b, func(ctx context.Context, lock *RedLock, resource string) error {
+		l, err := lock.Acquire(ctx, resource, time.Second)
+		if err != nil {
+			return err
+		}
+		return l.Unlock(ctx)
+	})
+}
+
+func BenchmarkAcquirePooledChurn(b *testing.B) {
+	benchmarkChurn(b, func(ctx context.Context, lock *RedLock, resource string) error {
+		l, err := lock.AcquirePooled(ctx, resource, time.Second)
+		if err != nil {
+			return err
+		}
+		return l.Unlock(ctx)
+	})
+}
+
+// benchmarkRelease measures releasing 1000 locks by release
+func benchmarkRelease(b *testing.B, release func(ctx context.Context, lock *RedLock, resources []string)) {
+	ctx := context.Background()
+	// the whole pipeline of ReleaseAll is bounded by the unlock timeout, which
+	// is not enough for 1000 scripts on slow instances such as miniredis
+	lock := benchRedLock(b, WithUnlockTimeout(time.Minute))
+	resources := make([]string, 1000)
+	for i := range resources {
+		resources[i] = "bench_release_" + strconv.Itoa(i)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		for _, resource := range resources {
+			if _, err := lock.Lock(ctx, resource, time.Minute); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.StartTimer()
+		release(ctx, lock, resources)
+	}
+}
+
+func BenchmarkUnLockEach(b *testing.B) {
+	benchmarkRelease(b, func(ctx context.Context, lock *RedLock, resources []string) {
+		for _, resource := range resources {
+			if err := lock.UnLock(ctx, resource); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkReleaseAll(b *testing.B) {
+	benchmarkRelease(b, func(ctx context.Context, lock *RedLock, resources []string) {
+		if err := lock.ReleaseAll(ctx, resources...); err != nil {
+			b.Fatal(err)
+		}
+	})
+}
+
+func benchmarkCache(b *testing.B, cache KVCache) {
+	val := getRandStr()
+	expiry := int64(time.Minute)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := "bench_cache_" + strconv.Itoa(i%1024)
+		if _, err := cache.Set(key, val, expiry); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := cache.Get(key); err != nil {
+			b.Fatal(err)
+		}
+		cache.Delete(key)
+	}
+}
+
+func BenchmarkSimpleCache(b *testing.B) {
+	benchmarkCache(b, NewSimpleCache(context.Background(), &CacheOptions{DisableGC: true}))
+}
+
+func BenchmarkFreeCache(b *testing.B) {
+	benchmarkCache(b, NewFreeCache(&CacheOptions{CacheSize: 10 * 1024 * 1024}))
+}
+
+// BenchmarkSimpleCacheSetDuringGC measures the latency of Set while gc runs
+// on a large cache full of expired elements
+func BenchmarkSimpleCacheSetDuringGC(b *testing.B) {
+	cache := NewSimpleCache(context.Background(), &CacheOptions{DisableGC: true})
+	fill := func() {
+		for i := 0; i < 200000; i++ {
+			cache.Set("bench_expired_"+strconv.Itoa(i), "val", 1) // nolint:errcheck
+		}
+	}
+	fill()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			cache.gc()
+			fill()
+		}
+	}()
+	var maxLatency time.Duration
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		if _, err := cache.Set("bench_cache_"+strconv.Itoa(i%1024), "val", int64(time.Minute)); err != nil {
+			b.Fatal(err)
+		}
+		if latency := time.Since(start); latency > maxLatency {
+			maxLatency = latency
+		}
+	}
+	b.ReportMetric(float64(maxLatency.Nanoseconds()), "max-ns")
+}
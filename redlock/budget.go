@@ -0,0 +1,82 @@
+package redlock
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// retryBudgetWindow is the count of latest Lock calls whose deposits the
+// retry budget can accumulate at most
+const retryBudgetWindow = 100
+
+// ErrRetryBudgetExhausted means the retry budget shared by all Lock calls is depleted
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// retryBudget is a token bucket shared by all Lock calls of a RedLock, each
+// Lock call deposits ratio tokens and each retry withdraws one token. Besides
+// minRetries retries per second are always allowed, so low traffic RedLock
+// can still retry.
+type retryBudget struct {
+	sync.Mutex
+	ratio       float64
+	minRetries  int
+	tokens      float64
+	maxTokens   float64
+	windowStart time.Time
+	windowCount int
+}
+
+func newRetryBudget(ratio float64, minRetries int) *retryBudget {
+	if ratio < 0 {
+		ratio = 0
+	}
+	if minRetries < 0 {
+		minRetries = 0
+	}
+	return &retryBudget{
+		ratio:      ratio,
+		minRetries: minRetries,
+		maxTokens:  ratio * retryBudgetWindow,
+	}
+}
+
+// deposit is called once for each Lock call
+func (b *retryBudget) deposit() {
+	b.Lock()
+	defer b.Unlock()
+	b.tokens += b.ratio
+	if b.tokens > b.maxTokens {
+		b.tokens = b.maxTokens
+	}
+}
+
+// withdraw is called before each retry, returns whether the retry is allowed
+func (b *retryBudget) withdraw() bool {
+	b.Lock()
+	defer b.Unlock()
+	now := time.Now()
+	if now.Sub(b.windowStart) >= time.Second {
+		b.windowStart = now
+		b.windowCount = 0
+	}
+	if b.windowCount < b.minRetries {
+		b.windowCount++
+		return true
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true
+	}
+	return false
+}
+
+// WithRetryBudget rate-limits the total retries of all concurrent Lock calls,
+// each Lock call earns ratio retries for the shared budget and minRetries
+// retries per second are always allowed. A Lock call fails fast with
+// ErrRetryBudgetExhausted when it needs to retry but the budget is depleted.
+func WithRetryBudget(ratio float64, minRetries int) LockOption {
+	return func(r *RedLock) {
+		r.retryBudget = newRetryBudget(ratio, minRetries)
+	}
+}
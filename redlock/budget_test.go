@@ -0,0 +1,47 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBudget(t *testing.T) {
+	b := newRetryBudget(0.5, 0)
+	assert.False(t, b.withdraw())
+	b.deposit()
+	assert.False(t, b.withdraw())
+	b.deposit()
+	assert.True(t, b.withdraw())
+	assert.False(t, b.withdraw())
+
+	// deposits are capped
+	for i := 0; i < 10*retryBudgetWindow; i++ {
+		b.deposit()
+	}
+	assert.Equal(t, 0.5*retryBudgetWindow, b.tokens)
+
+	b = newRetryBudget(0, 2)
+	assert.True(t, b.withdraw())
+	assert.True(t, b.withdraw())
+	assert.False(t, b.withdraw())
+	b.windowStart = b.windowStart.Add(-time.Second)
+	assert.True(t, b.withdraw())
+}
+
+func TestLockRetryBudget(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithRetryBudget(0, 0))
+	assert.Nil(t, err)
+	lock.SetRetryDelay(10)
+
+	for _, cli := range lock.clients {
+		assert.Nil(t, rawClient(cli).Set(ctx, "foo", "others", 0).Err())
+		defer rawClient(cli).Del(ctx, "foo")
+	}
+	_, err = lock.Lock(ctx, "foo", 100*time.Millisecond)
+	assert.True(t, errors.Is(err, ErrRetryBudgetExhausted))
+}
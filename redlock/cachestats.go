@@ -0,0 +1,66 @@
+package redlock
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+// CacheStats is the hit and miss counts of looking up the local cache on
+// release
+type CacheStats struct {
+	// Hits is the count of releases that found the lock in cache
+	Hits int64
+	// Misses is the count of releases that didn't find the lock in cache,
+	// because it expired, was evicted, or was never held by this RedLock
+	Misses int64
+}
+
+// HitRate returns the ratio of hits to all lookups, 0 if none
+func (s CacheStats) HitRate() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
+}
+
+// cacheCounters counts the cache lookups of release
+type cacheCounters struct {
+	hits   int64
+	misses int64
+}
+
+// CacheStats returns the hit and miss counts of the local cache lookups made
+// by UnLock and ReleaseAll, the counts are of r only even if the cache is
+// shared with clones. A high miss rate on locks released within their
+// validity suggests the cache evicts valid locks, such as a FreeCache too
+// small, and those locks are left to expire on redis instead of released.
+func (r *RedLock) CacheStats() CacheStats {
+	if r.cacheStats == nil {
+		return CacheStats{}
+	}
+	return CacheStats{
+		Hits:   atomic.LoadInt64(&r.cacheStats.hits),
+		Misses: atomic.LoadInt64(&r.cacheStats.misses),
+	}
+}
+
+// releaseLookup gets the lock of resource from cache to release it, and
+// counts the hit or miss. A failure of cache is logged and returned as a
+// CacheError, which is distinct from the lock not found.
+func (r *RedLock) releaseLookup(resource string) (*LockElem, error) {
+	elem, err := r.cache.Get(resource)
+	if err != nil {
+		log.Printf("redlock: failed to look up lock %s in cache: %v", resource, err)
+		return nil, &CacheError{resource: resource, Err: err}
+	}
+	if r.cacheStats == nil {
+		return elem, nil
+	}
+	if elem != nil {
+		atomic.AddInt64(&r.cacheStats.hits, 1)
+	} else {
+		atomic.AddInt64(&r.cacheStats.misses, 1)
+	}
+	return elem, nil
+}
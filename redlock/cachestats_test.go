@@ -0,0 +1,43 @@
+package redlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheStats(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	defer lock.Close()
+	assert.Equal(t, CacheStats{}, lock.CacheStats())
+	assert.Equal(t, float64(0), lock.CacheStats().HitRate())
+
+	_, err = lock.Lock(ctx, "cache-stats-foo", time.Second)
+	assert.Nil(t, err)
+	_, err = lock.Lock(ctx, "cache-stats-bar", time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnLock(ctx, "cache-stats-foo"))
+	// released already, or evicted from cache
+	assert.Nil(t, lock.UnLock(ctx, "cache-stats-foo"))
+	lock.cache.Delete("cache-stats-bar")
+	assert.Nil(t, lock.ReleaseAll(ctx, "cache-stats-bar"))
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2}, lock.CacheStats())
+	assert.InDelta(t, 1.0/3, lock.CacheStats().HitRate(), 1e-9)
+
+	// a clone counts its own lookups on the shared cache
+	clone, err := lock.Clone()
+	assert.Nil(t, err)
+	defer clone.Close()
+	assert.Nil(t, clone.UnLock(ctx, "cache-stats-foo"))
+	assert.Equal(t, CacheStats{Misses: 1}, clone.CacheStats())
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2}, lock.CacheStats())
+
+	// the lock evicted from cache is left on redis until it expires
+	for _, cli := range lock.clients {
+		rawClient(cli).Del(ctx, "cache-stats-bar")
+	}
+}
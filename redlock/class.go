@@ -0,0 +1,114 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// errNotAwaited is the result of an instance that acquisition stopped waiting
+// for after quorum was reached
+var errNotAwaited = errors.New("instance reply not awaited after quorum")
+
+// WithInstanceClasses tags instances by latency class, such as the region
+// relative to caller, classes maps the address of instance as given to the
+// constructor to its class, lower is closer, and an instance not listed is of
+// class 0. Acquisition contacts instances in the order of class and always
+// waits for the instances of the lowest class, while it stops waiting for
+// the farther ones as soon as quorum is locked, so quorum is counted on fast
+// local instances when available. Both the time to acquire and the validity
+// of the local cache no longer include the round trip to remote instances.
+//
+// A remote instance that is not awaited keeps trying in the background, it
+// is not reported by Lock.Holders even if it is locked eventually, and it is
+// released along with the others by Unlock. If quorum is not reached, every
+// instance is awaited as without classes.
+func WithInstanceClasses(classes map[string]int) LockOption {
+	return func(r *RedLock) {
+		copied := make(map[string]int, len(classes))
+		known := make(map[string]struct{}, len(r.clients))
+		for _, cli := range r.clients {
+			known[cli.addr] = struct{}{}
+		}
+		for addr := range classes {
+			if _, ok := known[addr]; !ok {
+				r.setOptErr(fmt.Errorf("invalid instance class, unknown instance %s", addr))
+				return
+			}
+			copied[addr] = classes[addr]
+		}
+		r.instanceClasses = copied
+	}
+}
+
+// lockAllByClass tries to set the lock on all instances in the order of
+// class, returns once quorum is locked and the instances of the lowest class
+// replied, or once all instances replied.
+func (r *RedLock) lockAllByClass(ctx context.Context, ttl time.Duration, lockFn lockFunc) []lockResult {
+	type reply struct {
+		idx int
+		res lockResult
+	}
+	order := make([]int, len(r.clients))
+	for idx := range order {
+		order[idx] = idx
+	}
+	class := func(idx int) int {
+		return r.instanceClasses[r.clients[idx].addr]
+	}
+	sort.SliceStable(order, func(i, j int) bool { return class(order[i]) < class(order[j]) })
+	lowest := class(order[0])
+
+	// the context is canceled once every instance replied, the instances not
+	// awaited keep trying after return
+	cctx, cancel := context.WithTimeout(ctx, r.instanceWait(ttl))
+	replies := make(chan reply, len(r.clients))
+	var wg sync.WaitGroup
+	for _, idx := range order {
+		idx, cli := idx, r.clients[idx]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := lockFn(cctx, cli)
+			res.at = time.Now()
+			replies <- reply{idx: idx, res: res}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+	}()
+
+	results := make([]lockResult, len(r.clients))
+	replied := make([]bool, len(r.clients))
+	pending := 0
+	for _, idx := range order {
+		if class(idx) == lowest {
+			pending++
+		}
+	}
+	locked := 0
+	for n := 0; n < len(r.clients); n++ {
+		rep := <-replies
+		results[rep.idx] = rep.res
+		replied[rep.idx] = true
+		if rep.res.locked {
+			locked++
+		}
+		if class(rep.idx) == lowest {
+			pending--
+		}
+		if locked >= r.quorum && pending == 0 {
+			break
+		}
+	}
+	for idx := range results {
+		if !replied[idx] {
+			results[idx] = lockResult{err: errNotAwaited}
+		}
+	}
+	return results
+}
@@ -0,0 +1,72 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockInstanceClasses(t *testing.T) {
+	ctx := context.Background()
+	var remoteLocked int32
+	remote := func(delay time.Duration) *mockCmdable {
+		return &mockCmdable{setNX: func(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+			select {
+			case <-time.After(delay):
+				atomic.AddInt32(&remoteLocked, 1)
+				return true, nil
+			case <-ctx.Done():
+				return false, ctx.Err()
+			}
+		}}
+	}
+	classes := map[string]int{"tcp://mock1:6379": 1, "tcp://mock2:6379": 1}
+
+	// quorum is reached on the local instance and the first remote one, the
+	// slow remote instance is not awaited
+	lock := newMockRedLockWithOptions(t,
+		[]redisCmdable{&mockCmdable{}, remote(10 * time.Millisecond), remote(time.Second)},
+		WithInstanceClasses(classes), WithInstanceTimeout(2*time.Second))
+	start := time.Now()
+	l, err := lock.Acquire(ctx, "foo", 5*time.Second)
+	assert.Nil(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+	assert.Equal(t, []string{"tcp://mock0:6379", "tcp://mock1:6379"}, l.Holders())
+	// the slow instance keeps trying in the background
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&remoteLocked) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// all instances are awaited without classes
+	lock = newMockRedLockWithOptions(t,
+		[]redisCmdable{&mockCmdable{}, remote(10 * time.Millisecond), remote(200 * time.Millisecond)})
+	start = time.Now()
+	l, err = lock.Acquire(ctx, "foo", 5*time.Second)
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(200*time.Millisecond))
+	assert.Len(t, l.Holders(), 3)
+
+	// the local instances are always awaited, and all instances are awaited
+	// if quorum is not reached
+	errInjected := errors.New("injected error")
+	lock = newMockRedLockWithOptions(t,
+		[]redisCmdable{
+			&mockCmdable{setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+				return false, errInjected
+			}},
+			remote(10 * time.Millisecond), remote(200 * time.Millisecond),
+		},
+		WithInstanceClasses(classes), WithRetryCount(1))
+	start = time.Now()
+	l, err = lock.Acquire(ctx, "foo", 5*time.Second)
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(200*time.Millisecond))
+	assert.Len(t, l.Holders(), 2)
+
+	_, err = NewRedLock(ctx, redisServers, WithInstanceClasses(map[string]int{"tcp://unknown:6379": 1}))
+	assert.NotNil(t, err)
+}
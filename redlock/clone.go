@@ -0,0 +1,186 @@
+package redlock
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// sharedClients is the reference count of the RedLocks sharing the same redis
+// clients by Clone
+type sharedClients struct {
+	refs int32
+	// owned is set if the clients are created by RedLock rather than caller,
+	// they are closed by the last Close
+	owned bool
+}
+
+// Clone creates a RedLock sharing the redis clients of r, with the settings
+// of r modified by opts, such as the retry count, retry delay, drift factor
+// or hash tag. No new connection is opened, which suits a service using
+// different policies for different workloads on the same redis servers.
+//
+// The clone shares the local cache of r unless any CacheOption is given, in
+// which case it gets a new cache, note a shared cache tracks held locks by
+// resource, so the same resource can't be held by both of them. It also
+// shares the retry budget and per resource rate unless WithRetryBudget or
+// WithPerResourceRate is given, while the in-flight counts, events,
+// singleflight and expvar stats are its own.
+//
+// Each clone holds a reference of the clients, Close releases it, and the
+// clients created by NewRedLock are closed by the last Close among r and its
+// clones. Clients passed to NewRedLockFromClients or
+// NewRedLockFromClusterClients are owned by caller and never closed.
+func (r *RedLock) Clone(opts ...Option) (*RedLock, error) {
+	return r.clone(r.clients, r.shared, opts...)
+}
+
+// clone creates a RedLock on clients with the settings of r modified by opts,
+// shared is the reference count of clients. A field added to RedLock is either
+// copied here or listed in cloneResetFields of the tests.
+func (r *RedLock) clone(clients []*RedClient, shared *sharedClients, opts ...Option) (*RedLock, error) {
+	c := &RedLock{
+		retryCount:         r.retryCount,
+		retryDelay:         r.retryDelay,
+		connRetries:        r.connRetries,
+		backoffMin:         r.backoffMin,
+		backoffMax:         r.backoffMax,
+		driftFactor:        r.driftFactor,
+		absoluteDrift:      r.absoluteDrift,
+		clients:            clients,
+		quorumPercent:      r.quorumPercent,
+		connectPolicy:      r.connectPolicy,
+		envConfig:          r.envConfig,
+		maxTTL:             r.maxTTL,
+		maxValueBytes:      r.maxValueBytes,
+		retryStrategy:      r.retryStrategy,
+		retryBudget:        r.retryBudget,
+		resourceRate:       r.resourceRate,
+		hedgeDelay:         r.hedgeDelay,
+		setNXGet:           r.setNXGet,
+		informedBackoff:    r.informedBackoff,
+		leaseMetadata:      r.leaseMetadata,
+		hashTag:            r.hashTag,
+		keyHasher:          r.keyHasher,
+		validateResource:   r.validateResource,
+		failFastNoValidity: r.failFastNoValidity,
+		requireDeadline:    r.requireDeadline,
+		reentrant:          r.reentrant,
+		reentrantTTL:       r.reentrantTTL,
+		holdCounts:         r.holdCounts,
+		unlockTimeout:      r.unlockTimeout,
+		unlockRetry:        r.unlockRetry,
+		instanceTimeout:    r.instanceTimeout,
+		resourceTTLs:       r.resourceTTLs,
+		defaultTTL:         r.defaultTTL,
+		longHoldFraction:   r.longHoldFraction,
+		waitReplicas:       r.waitReplicas,
+		waitTimeout:        r.waitTimeout,
+		auditHook:          r.auditHook,
+		ownerProvider:      r.ownerProvider,
+		valuePrefix:        r.valuePrefix,
+		deadlockDetection:  r.deadlockDetection,
+		ulidValue:          r.ulidValue,
+		expiryInValue:      r.expiryInValue,
+		identity:           r.identity,
+		entropyPolicy:      r.entropyPolicy,
+		matcher:            r.matcher,
+		instanceLatency:    r.instanceLatency,
+		instanceClasses:    r.instanceClasses,
+		cacheStats:         &cacheCounters{},
+		shared:             shared,
+	}
+	if r.inflightRes != nil {
+		WithResourceInFlight()(c)
+	}
+	if r.flight != nil {
+		WithLocalSingleflight()(c)
+	}
+	if r.events != nil {
+		WithEvents(cap(r.events.ch))(c)
+	}
+	if r.expvar != nil {
+		WithExpvar()(c)
+	}
+	cacheOpts := make([]CacheOption, 0, len(opts))
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case CacheOption:
+			cacheOpts = append(cacheOpts, o)
+		case LockOption:
+			o(c)
+		}
+	}
+	if c.optErr != nil {
+		return nil, c.optErr
+	}
+	var cacheOptions *CacheOptions
+	if len(cacheOpts) > 0 {
+		cacheOptions = newCacheOptions(cacheOpts...)
+		if err := cacheOptions.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if c.envConfig {
+		if err := c.applyEnvConfig(); err != nil {
+			return nil, err
+		}
+	}
+	c.quorum = c.computeQuorum(len(c.clients))
+	if cacheOptions != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		c.cache = newCache(ctx, cacheOptions)
+		c.cancelCache = cancel
+		if c.tracksHeldTTLs() {
+			c.heldTTLs = newHeldTTLs()
+		}
+		if c.holdCounts != nil {
+			c.holdCounts = &holdCounts{counts: make(map[string]int)}
+		}
+	} else {
+		c.cache = r.cache
+		// the held ttls belong to the locks in the shared cache
+		if c.tracksHeldTTLs() {
+			c.heldTTLs = r.heldTTLs
+			if c.heldTTLs == nil {
+				c.heldTTLs = newHeldTTLs()
+			}
+		}
+		// so are the hold counts
+		if c.holdCounts != nil && r.holdCounts != nil {
+			c.holdCounts = r.holdCounts
+		}
+	}
+	if c.expvar != nil {
+		c.expvar.publish(c)
+	}
+	atomic.AddInt32(&c.shared.refs, 1)
+	return c, nil
+}
+
+// Close releases the reference of redis clients held by r, the clients are
+// closed if r is the last one sharing them and they are created by
+// NewRedLock. Locks held by r are not released, they expire by their ttl.
+// The expvar stats of r are unpublished. Calling Close more than once is a
+// no-op, r must not be used after Close.
+func (r *RedLock) Close() error {
+	if !atomic.CompareAndSwapInt32(&r.closed, 0, 1) {
+		return nil
+	}
+	if r.cancelCache != nil {
+		r.cancelCache()
+	}
+	if r.expvar != nil {
+		r.expvar.unpublish()
+	}
+	if atomic.AddInt32(&r.shared.refs, -1) > 0 || !r.shared.owned {
+		return nil
+	}
+	var firstErr error
+	for _, cli := range r.clients {
+		if err := cli.cli.Close(); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %w", cli.addr, err)
+		}
+	}
+	return firstErr
+}
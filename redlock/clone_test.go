@@ -0,0 +1,134 @@
+package redlock
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockClone(t *testing.T) {
+	ctx := context.Background()
+	var closed int32
+	instance := func() *mockCmdable {
+		return &mockCmdable{close: func() error {
+			atomic.AddInt32(&closed, 1)
+			return nil
+		}}
+	}
+	lock := newMockRedLockWithOptions(t, []redisCmdable{instance(), instance(), instance()}, WithEvents(1))
+
+	clone, err := lock.Clone(WithRetryCount(1), WithHashTag("clone"))
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultRetryCount, lock.retryCount)
+	assert.Equal(t, 1, clone.retryCount)
+	assert.Equal(t, "", lock.hashTag)
+	assert.Equal(t, "clone", clone.hashTag)
+	assert.Equal(t, lock.clients, clone.clients)
+	assert.Equal(t, lock.quorum, clone.quorum)
+	assert.NotEqual(t, lock.Events(), clone.Events())
+
+	// the cache is shared without cache options
+	_, err = clone.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.True(t, lock.Owns("foo"))
+	other, err := lock.Clone(WithCacheType(CacheTypeSimple))
+	assert.Nil(t, err)
+	assert.False(t, other.Owns("foo"))
+
+	_, err = lock.Clone(WithRetryCount(-1))
+	assert.NotNil(t, err)
+	_, err = lock.Clone(WithGCInterval(0))
+	assert.NotNil(t, err)
+
+	// clients are closed by the last Close
+	assert.Nil(t, clone.Close())
+	assert.Nil(t, clone.Close())
+	assert.Nil(t, lock.Close())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&closed))
+	assert.Nil(t, other.Close())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&closed))
+}
+
+// cloneResetFields are the fields of RedLock that clone doesn't copy from r,
+// either per instance state or recreated for the clone
+var cloneResetFields = map[string]bool{
+	"inflight":       true,
+	"pendingUnlocks": true,
+	"inflightRes":    true,
+	"flight":         true,
+	"events":         true,
+	"clients":        true,
+	"shared":         true,
+	"closed":         true,
+	"quorum":         true,
+	"optErr":         true,
+	"heldTTLs":       true,
+	"expvar":         true,
+	"cacheStats":     true,
+	"diag":           true,
+	"cache":          true,
+	"cancelCache":    true,
+}
+
+// TestMockCloneCopiesSettings fails when a field added to RedLock is neither
+// copied by clone nor listed in cloneResetFields
+func TestMockCloneCopiesSettings(t *testing.T) {
+	lock := newMockRedLockWithOptions(t, []redisCmdable{&mockCmdable{}},
+		WithRetryStrategy(FixedRetry(1, time.Millisecond)),
+		WithContextAuditHook(&recordContextAuditHook{}))
+	field := func(r *RedLock, i int) reflect.Value {
+		f := reflect.ValueOf(r).Elem().Field(i)
+		return reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+	}
+	typ := reflect.TypeOf(RedLock{})
+	for i := 0; i < typ.NumField(); i++ {
+		name := typ.Field(i).Name
+		f := field(lock, i)
+		if cloneResetFields[name] || !f.IsZero() {
+			continue
+		}
+		switch f.Kind() {
+		case reflect.Bool:
+			f.SetBool(true)
+		case reflect.Int, reflect.Int32, reflect.Int64:
+			f.SetInt(3)
+		case reflect.Float64:
+			f.SetFloat(0.75)
+		case reflect.String:
+			f.SetString("x")
+		case reflect.Ptr:
+			f.Set(reflect.New(f.Type().Elem()))
+		case reflect.Map:
+			f.Set(reflect.MakeMap(f.Type()))
+		case reflect.Func:
+			f.Set(reflect.MakeFunc(f.Type(), func(args []reflect.Value) []reflect.Value {
+				return nil
+			}))
+		default:
+			t.Fatalf("no test value for field %s of kind %s", name, f.Kind())
+		}
+	}
+
+	clone, err := lock.Clone()
+	assert.Nil(t, err)
+	for i := 0; i < typ.NumField(); i++ {
+		name := typ.Field(i).Name
+		if cloneResetFields[name] {
+			continue
+		}
+		orig, cloned := field(lock, i), field(clone, i)
+		switch orig.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Func:
+			assert.Equal(t, orig.Pointer(), cloned.Pointer(), "field %s is not cloned", name)
+		default:
+			assert.Equal(t, fmt.Sprintf("%#v", orig.Interface()), fmt.Sprintf("%#v", cloned.Interface()),
+				"field %s is not cloned", name)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package redlock
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec defines how LockElem is serialized by the cache backends that store
+// raw bytes, such as FreeCache
+type Codec interface {
+	// Marshal encodes a LockElem
+	Marshal(elem *LockElem) ([]byte, error)
+
+	// Unmarshal decodes data into a LockElem
+	Unmarshal(data []byte, elem *LockElem) error
+}
+
+// JSONCodec serializes LockElem with encoding/json, it is the default codec
+type JSONCodec struct{}
+
+// Marshal implements Codec.Marshal
+func (JSONCodec) Marshal(elem *LockElem) ([]byte, error) {
+	return json.Marshal(elem)
+}
+
+// Unmarshal implements Codec.Unmarshal
+func (JSONCodec) Unmarshal(data []byte, elem *LockElem) error {
+	return json.Unmarshal(data, elem)
+}
+
+// GobCodec serializes LockElem with encoding/gob
+type GobCodec struct{}
+
+// Marshal implements Codec.Marshal
+func (GobCodec) Marshal(elem *LockElem) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(elem); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal implements Codec.Unmarshal
+func (GobCodec) Unmarshal(data []byte, elem *LockElem) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(elem)
+}
+
+// MsgpackCodec serializes LockElem with msgpack
+type MsgpackCodec struct{}
+
+// Marshal implements Codec.Marshal
+func (MsgpackCodec) Marshal(elem *LockElem) ([]byte, error) {
+	return msgpack.Marshal(elem)
+}
+
+// Unmarshal implements Codec.Unmarshal
+func (MsgpackCodec) Unmarshal(data []byte, elem *LockElem) error {
+	return msgpack.Unmarshal(data, elem)
+}
@@ -0,0 +1,74 @@
+package redlock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testCodecs = map[string]Codec{
+	"json":    JSONCodec{},
+	"gob":     GobCodec{},
+	"msgpack": MsgpackCodec{},
+}
+
+func TestCodec(t *testing.T) {
+	elem := &LockElem{
+		Val:    "test_value",
+		Expiry: 1_000_000,
+		Ts:     time.Now(),
+	}
+	for name, codec := range testCodecs {
+		buf, err := codec.Marshal(elem)
+		assert.Nil(t, err, name)
+		elem2 := &LockElem{}
+		err = codec.Unmarshal(buf, elem2)
+		assert.Nil(t, err, name)
+		assert.Equal(t, elem.Val, elem2.Val, name)
+		assert.Equal(t, elem.Expiry, elem2.Expiry, name)
+		assert.True(t, elem.Ts.Equal(elem2.Ts), name)
+	}
+}
+
+func TestFreeCacheCodec(t *testing.T) {
+	for name, codec := range testCodecs {
+		cache := NewFreeCache(&CacheOptions{CacheSize: 1024 * 1024, Codec: codec})
+		elem, err := cache.Set("test_key", "test_value", int64(time.Second))
+		assert.Nil(t, err, name)
+		elem2, err := cache.Get("test_key")
+		assert.Nil(t, err, name)
+		assert.Equal(t, elem.Val, elem2.Val, name)
+	}
+}
+
+func benchmarkCodec(b *testing.B, codec Codec) {
+	elem := &LockElem{
+		Val:    getRandStr(),
+		Expiry: int64(time.Second),
+		Ts:     time.Now(),
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf, err := codec.Marshal(elem)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := codec.Unmarshal(buf, &LockElem{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONCodec(b *testing.B) {
+	benchmarkCodec(b, JSONCodec{})
+}
+
+func BenchmarkGobCodec(b *testing.B) {
+	benchmarkCodec(b, GobCodec{})
+}
+
+func BenchmarkMsgpackCodec(b *testing.B) {
+	benchmarkCodec(b, MsgpackCodec{})
+}
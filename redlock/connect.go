@@ -0,0 +1,96 @@
+package redlock
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// ConnectPolicy decides how many instances must be reachable when a RedLock
+// is created
+type ConnectPolicy int
+
+const (
+	// ConnectLazy doesn't check connectivity at creation, it is the default
+	ConnectLazy ConnectPolicy = iota
+	// ConnectAny requires at least one reachable instance
+	ConnectAny
+	// ConnectQuorum requires a quorum of reachable instances
+	ConnectQuorum
+	// ConnectAll requires every instance to be reachable
+	ConnectAll
+)
+
+// WithConnectPolicy pings every instance when the RedLock is created, the
+// creation fails with a ConnectError if fewer instances than the policy
+// requires are reachable. Any unreachable instance tolerated by the policy
+// is logged as a warning.
+func WithConnectPolicy(policy ConnectPolicy) LockOption {
+	return func(r *RedLock) {
+		r.connectPolicy = policy
+	}
+}
+
+// ConnectError holds the per-instance errors of the connectivity check, in
+// the same order as the redis servers, nil for the reachable instances.
+type ConnectError struct {
+	Policy ConnectPolicy
+	Errs   []error
+}
+
+func (e *ConnectError) Error() string {
+	msgs := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	return fmt.Sprintf("%d of %d instances are unreachable: %s",
+		len(msgs), len(e.Errs), strings.Join(msgs, "; "))
+}
+
+// checkConnect pings all instances concurrently and checks the number of
+// reachable ones against the connect policy
+func (r *RedLock) checkConnect(ctx context.Context) error {
+	var required int
+	switch r.connectPolicy {
+	case ConnectAny:
+		required = 1
+	case ConnectQuorum:
+		required = r.quorum
+	case ConnectAll:
+		required = len(r.clients)
+	default:
+		return nil
+	}
+	errs := make([]error, len(r.clients))
+	var wg sync.WaitGroup
+	for idx, cli := range r.clients {
+		idx, cli := idx, cli
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cli.cli.Ping(ctx).Err(); err != nil {
+				errs[idx] = fmt.Errorf("%s: %w", cli.addr, err)
+			}
+		}()
+	}
+	wg.Wait()
+	reachable := 0
+	for _, err := range errs {
+		if err == nil {
+			reachable++
+		}
+	}
+	if reachable < required {
+		return &ConnectError{Policy: r.connectPolicy, Errs: errs}
+	}
+	for _, err := range errs {
+		if err != nil {
+			log.Printf("redlock: instance is unreachable: %v", err)
+		}
+	}
+	return nil
+}
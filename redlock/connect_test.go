@@ -0,0 +1,47 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectPolicy(t *testing.T) {
+	ctx := context.Background()
+	// the first two servers are reachable
+	addrs := []string{redisServers[0], redisServers[1], "tcp://127.0.0.1:1"}
+
+	testCases := []struct {
+		policy ConnectPolicy
+		valid  bool
+	}{
+		{ConnectLazy, true},
+		{ConnectAny, true},
+		{ConnectQuorum, true},
+		{ConnectAll, false},
+	}
+	for _, tc := range testCases {
+		_, err := NewRedLock(ctx, addrs, WithConnectPolicy(tc.policy))
+		if tc.valid {
+			assert.Nil(t, err, "%+v", tc)
+			continue
+		}
+		var ce *ConnectError
+		assert.True(t, errors.As(err, &ce), "%+v", tc)
+		assert.Equal(t, tc.policy, ce.Policy)
+		assert.Len(t, ce.Errs, 3)
+		assert.Nil(t, ce.Errs[0])
+		assert.Nil(t, ce.Errs[1])
+		assert.NotNil(t, ce.Errs[2])
+	}
+
+	// majority of unreachable servers fails quorum policy
+	addrs = []string{redisServers[0], "tcp://127.0.0.1:1", "tcp://127.0.0.1:2"}
+	_, err := NewRedLock(ctx, addrs, WithConnectPolicy(ConnectAny))
+	assert.Nil(t, err)
+	_, err = NewRedLock(ctx, addrs, WithConnectPolicy(ConnectQuorum))
+	var ce *ConnectError
+	assert.True(t, errors.As(err, &ce))
+}
@@ -0,0 +1,71 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultConnRetries is the default count of retries of setting a lock on
+	// an instance after a transient connection error
+	DefaultConnRetries = 1
+
+	// connRetryDelay is the wait before retrying on a dropped connection
+	connRetryDelay = 5 * time.Millisecond
+)
+
+// WithConnRetries sets how many times setting a lock on an instance is
+// retried right away after a transient connection error, such as EOF or
+// connection reset, so a momentary blip of one instance doesn't cost an
+// acquisition attempt on all instances. It is distinct from the retry count
+// of acquisition, 0 disables it.
+func WithConnRetries(count int) LockOption {
+	return func(r *RedLock) {
+		if count < 0 {
+			r.setOptErr(fmt.Errorf("invalid connection retry count %d, must not be negative", count))
+			return
+		}
+		r.connRetries = count
+	}
+}
+
+// isConnError returns whether err means the connection was dropped, which is
+// worth retrying on a new connection
+func isConnError(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
+
+// setInstanceRetrying sets the lock on client by set, retrying on transient
+// connection errors. The lock may have been set before the connection
+// dropped, so on retry a lock held with val counts as set.
+func (r *RedLock) setInstanceRetrying(
+	ctx context.Context, client *RedClient, key, val string, set func() lockResult,
+) lockResult {
+	res := set()
+	for i := 0; i < r.connRetries && isConnError(res.err); i++ {
+		timer := time.NewTimer(connRetryDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return res
+		case <-timer.C:
+		}
+		res = set()
+		if res.err != nil || res.locked {
+			continue
+		}
+		holder := res.holder
+		if holder == "" {
+			holder, _ = client.cli.Get(ctx, key).Result()
+		}
+		if holder == val {
+			res = lockResult{locked: true}
+		}
+	}
+	return res
+}
@@ -0,0 +1,75 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsConnError(t *testing.T) {
+	assert.True(t, isConnError(io.EOF))
+	assert.True(t, isConnError(fmt.Errorf("read: %w", syscall.ECONNRESET)))
+	assert.False(t, isConnError(errors.New("ERR unknown command")))
+	assert.False(t, isConnError(nil))
+}
+
+func TestMockConnRetries(t *testing.T) {
+	ctx := context.Background()
+	_, err := NewRedLock(ctx, []string{"tcp://mock0:6379"}, WithConnRetries(-1))
+	assert.NotNil(t, err)
+
+	// the connection drops once, then SETNX succeeds
+	var calls int32
+	blip := func() *mockCmdable {
+		return &mockCmdable{
+			setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					return false, io.EOF
+				}
+				return true, nil
+			},
+		}
+	}
+	held := &mockCmdable{
+		setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+			return false, nil
+		},
+	}
+	lock := newMockRedLockWithOptions(t, []redisCmdable{blip(), held, &mockCmdable{}}, WithRetryCount(1))
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// disabled
+	atomic.StoreInt32(&calls, 0)
+	lock = newMockRedLockWithOptions(t, []redisCmdable{blip(), held, &mockCmdable{}}, WithRetryCount(1), WithConnRetries(0))
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// SETNX was applied before the connection dropped, the lock held with
+	// our value counts as set on retry
+	var val atomic.Value
+	applied := &mockCmdable{
+		setNX: func(_ context.Context, _ string, value interface{}, _ time.Duration) (bool, error) {
+			if val.Load() == nil {
+				val.Store(value.(string))
+				return false, syscall.ECONNRESET
+			}
+			return false, nil
+		},
+		get: func(context.Context, string) (string, error) {
+			return val.Load().(string), nil
+		},
+	}
+	lock = newMockRedLockWithOptions(t, []redisCmdable{applied, held, &mockCmdable{}}, WithRetryCount(1))
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+}
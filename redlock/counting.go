@@ -0,0 +1,104 @@
+package redlock
+
+import "sync"
+
+// WithCountingReentrant makes locks re-entrant by count, acquiring a lock
+// already held by this RedLock returns it like WithReentrant and increments
+// its hold count, while UnLock, ReleaseAll and Lock.Unlock decrement it and
+// release the lock on redis only when the count drops to zero. So nested
+// functions locking the same resource don't release it prematurely. A
+// differing ttl is reconciled by WithReentrantTTLPolicy.
+//
+// The count is kept locally along with the cache, a lock lost by expiry
+// starts over from one when acquired again, and UnlockByValue and Transfer
+// release the lock regardless of its count. It is not meant to be combined
+// with WithLocalSingleflight, which counts the holders by itself.
+func WithCountingReentrant() LockOption {
+	return func(r *RedLock) {
+		r.reentrant = true
+		r.holdCounts = &holdCounts{counts: make(map[string]int)}
+		if r.heldTTLs == nil {
+			r.heldTTLs = newHeldTTLs()
+		}
+	}
+}
+
+// holdCounts records the hold count of each lock re-entered by count
+type holdCounts struct {
+	sync.Mutex
+	counts map[string]int
+}
+
+// acquired sets the count of resource acquired afresh to one
+func (h *holdCounts) acquired(resource string) {
+	if h == nil {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	h.counts[resource] = 1
+}
+
+// enter increments the count of resource if it is still held in cache, it
+// returns false if the lock is released or being released meanwhile
+func (h *holdCounts) enter(r *RedLock, resource string) bool {
+	h.Lock()
+	defer h.Unlock()
+	if n, ok := h.counts[resource]; ok && n == 0 {
+		return false
+	}
+	elem, err := r.cache.Get(resource)
+	if err != nil || elem == nil {
+		return false
+	}
+	n := h.counts[resource]
+	if n == 0 {
+		// the lock is put in cache by other than acquisition, such as
+		// LockFromToken, it is held once
+		n = 1
+	}
+	h.counts[resource] = n + 1
+	return true
+}
+
+// leave decrements the count of resource, it returns true if the count
+// drops to zero, in which case the count is kept at zero while the lock is
+// being released, so it can't be re-entered or left again until clear. The
+// lock stays in cache until the release succeeds, so a failed release can be
+// retried.
+func (h *holdCounts) leave(resource string) bool {
+	if h == nil {
+		return true
+	}
+	h.Lock()
+	defer h.Unlock()
+	n, ok := h.counts[resource]
+	switch {
+	case ok && n == 0:
+		// released by another call
+		return false
+	case n > 1:
+		h.counts[resource] = n - 1
+		return false
+	}
+	h.counts[resource] = 0
+	return true
+}
+
+// clear drops the count of resource whose release is done, or which is
+// released regardless of its count
+func (h *holdCounts) clear(resource string) {
+	if h == nil {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	delete(h.counts, resource)
+}
+
+// count returns the hold count of resource
+func (h *holdCounts) count(resource string) int {
+	h.Lock()
+	defer h.Unlock()
+	return h.counts[resource]
+}
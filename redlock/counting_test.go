@@ -0,0 +1,161 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountingReentrant(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithCountingReentrant())
+	assert.Nil(t, err)
+	defer lock.Close()
+	held := func(resource string) bool {
+		values, err := lock.Inspect(ctx, resource)
+		assert.Nil(t, err)
+		return len(values) == len(redisServers)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err = lock.Lock(ctx, "counting-foo", time.Second)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 3, lock.holdCounts.count("counting-foo"))
+	assert.Nil(t, lock.UnLock(ctx, "counting-foo"))
+	l, err := lock.Acquire(ctx, "counting-foo", time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, l.Unlock(ctx))
+	assert.Nil(t, lock.ReleaseAll(ctx, "counting-foo"))
+	assert.True(t, held("counting-foo"))
+	assert.True(t, lock.Owns("counting-foo"))
+	// the last release releases the lock on redis
+	assert.Nil(t, lock.UnLock(ctx, "counting-foo"))
+	assert.False(t, held("counting-foo"))
+	assert.False(t, lock.Owns("counting-foo"))
+	assert.Equal(t, 0, lock.holdCounts.count("counting-foo"))
+
+	// a failed re-entrant acquisition doesn't count
+	lock, err = NewRedLock(ctx, redisServers, WithCountingReentrant(), WithReentrantTTLPolicy(ReentrantTTLReject))
+	assert.Nil(t, err)
+	defer lock.Close()
+	_, err = lock.Lock(ctx, "counting-foo", time.Second)
+	assert.Nil(t, err)
+	_, err = lock.Lock(ctx, "counting-foo", 2*time.Second)
+	assert.True(t, errors.Is(err, ErrTTLMismatch))
+	assert.Equal(t, 1, lock.holdCounts.count("counting-foo"))
+	assert.Nil(t, lock.UnLock(ctx, "counting-foo"))
+	assert.False(t, held("counting-foo"))
+
+	// the count is shared by clones sharing the cache
+	clone, err := lock.Clone()
+	assert.Nil(t, err)
+	defer clone.Close()
+	_, err = lock.Lock(ctx, "counting-foo", time.Second)
+	assert.Nil(t, err)
+	_, err = clone.Lock(ctx, "counting-foo", time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, clone.UnLock(ctx, "counting-foo"))
+	assert.True(t, held("counting-foo"))
+	assert.Nil(t, lock.UnLock(ctx, "counting-foo"))
+	assert.False(t, held("counting-foo"))
+}
+
+func TestCountingReentrantConcurrent(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithCountingReentrant(), WithRetryCount(50), WithRetryDelay(5))
+	assert.Nil(t, err)
+	defer lock.Close()
+	held := func() bool {
+		values, err := lock.Inspect(ctx, "counting-bar")
+		assert.Nil(t, err)
+		return len(values) == len(redisServers)
+	}
+
+	// nested holders never release the outer hold
+	_, err = lock.Lock(ctx, "counting-bar", 5*time.Second)
+	assert.Nil(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_, err := lock.Lock(ctx, "counting-bar", 5*time.Second)
+				assert.Nil(t, err)
+				assert.True(t, lock.Owns("counting-bar"))
+				assert.Nil(t, lock.UnLock(ctx, "counting-bar"))
+			}
+		}()
+	}
+	wg.Wait()
+	assert.True(t, held())
+	assert.Equal(t, 1, lock.holdCounts.count("counting-bar"))
+	assert.Nil(t, lock.UnLock(ctx, "counting-bar"))
+	assert.False(t, held())
+
+	// holders racing without an outer hold leave nothing behind
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				if _, err := lock.Lock(ctx, "counting-bar", 5*time.Second); err != nil {
+					continue
+				}
+				assert.Nil(t, lock.UnLock(ctx, "counting-bar"))
+			}
+		}()
+	}
+	wg.Wait()
+	assert.False(t, held())
+	assert.False(t, lock.Owns("counting-bar"))
+	assert.Equal(t, 0, lock.holdCounts.count("counting-bar"))
+}
+
+func TestMockCountingReentrantRetryRelease(t *testing.T) {
+	ctx := context.Background()
+	var failing int32
+	instance := func() *mockCmdable {
+		return &mockCmdable{
+			eval: func(context.Context, string, []string, ...interface{}) (interface{}, error) {
+				if atomic.LoadInt32(&failing) == 1 {
+					return nil, errors.New("injected error")
+				}
+				return int64(1), nil
+			},
+		}
+	}
+	lock := newMockRedLockWithOptions(t, []redisCmdable{instance(), instance(), instance()}, WithCountingReentrant())
+
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+
+	// the lock failed to be released is kept, so the release can be retried
+	atomic.StoreInt32(&failing, 1)
+	var ue *UnlockError
+	assert.True(t, errors.As(lock.UnLock(ctx, "foo"), &ue))
+	assert.True(t, lock.Owns("foo"))
+	assert.Equal(t, 0, lock.holdCounts.count("foo"))
+	atomic.StoreInt32(&failing, 0)
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+	assert.False(t, lock.Owns("foo"))
+
+	// so is ReleaseAll
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	atomic.StoreInt32(&failing, 1)
+	assert.NotNil(t, lock.ReleaseAll(ctx, "foo"))
+	assert.True(t, lock.Owns("foo"))
+	atomic.StoreInt32(&failing, 0)
+	assert.Nil(t, lock.ReleaseAll(ctx, "foo"))
+	assert.False(t, lock.Owns("foo"))
+}
@@ -0,0 +1,35 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNoDeadline means a blocking acquisition is called with a ctx without
+// deadline while WithRequireDeadline is set
+var ErrNoDeadline = errors.New("context has no deadline")
+
+// WithRequireDeadline makes the acquisitions that retry, such as Lock,
+// Acquire, DistMutex.Lock or WithLock, fail with ErrNoDeadline right away if
+// their ctx has no deadline, rather than retrying as long as the retry
+// strategy allows, which may be very long with a large retry count or a
+// custom RetryStrategy. So a missing deadline is caught at the call site
+// instead of by a stuck caller. The acquisitions making a single attempt,
+// such as DistMutex.TryLock, don't block and are not checked.
+func WithRequireDeadline() LockOption {
+	return func(r *RedLock) {
+		r.requireDeadline = true
+	}
+}
+
+// checkDeadline checks ctx has a deadline if required and the acquisition
+// may retry by retry
+func (r *RedLock) checkDeadline(ctx context.Context, resource string, retry RetryStrategy) error {
+	if !r.requireDeadline || retry == noRetry {
+		return nil
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		return &AcquireError{resource: resource, Err: ErrNoDeadline}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockRequireDeadline(t *testing.T) {
+	ctx := context.Background()
+	lock := newMockRedLockWithOptions(t, []redisCmdable{&mockCmdable{}, &mockCmdable{}, &mockCmdable{}},
+		WithRequireDeadline())
+
+	// blocking acquisitions fail without attempt
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	var ae *AcquireError
+	assert.True(t, errors.As(err, &ae))
+	assert.True(t, errors.Is(err, ErrNoDeadline))
+	assert.Equal(t, "foo", ae.Resource())
+	assert.False(t, lock.Owns("foo"))
+	m := lock.NewMutex("bar", time.Second)
+	assert.True(t, errors.Is(m.Lock(ctx), ErrNoDeadline))
+
+	// a single attempt doesn't block
+	ok, err := m.TryLock(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, m.Unlock(ctx))
+
+	dctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	_, err = lock.Lock(dctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+
+	// ctx without deadline is allowed by default
+	lock = newMockRedLock(t, &mockCmdable{}, &mockCmdable{}, &mockCmdable{})
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+}
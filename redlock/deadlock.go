@@ -0,0 +1,115 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// waitKeyPrefix is the prefix of the key recording the resource that an
+	// owner waits on, which forms the wait-for graph with the owners of lock
+	// values
+	waitKeyPrefix = "redlock:waits:"
+
+	// waitKeySlack is how long the wait record outlives the retry delay, so
+	// it covers the next attempt
+	waitKeySlack = time.Second
+
+	// maxWaitHops bounds the walk of the wait-for graph
+	maxWaitHops = 16
+)
+
+// ErrPotentialDeadlock means the owner acquiring a lock waits on a chain of
+// owners that leads back to itself, see WithDeadlockDetection
+var ErrPotentialDeadlock = errors.New("potential deadlock detected")
+
+// WithDeadlockDetection publishes a wait-for graph to the first redis server
+// and checks it before waiting for a lock held by others. The owner of a lock
+// is embedded by WithOwnerProvider, which should identify the workflow that
+// acquires multiple locks, locks acquired without owner are not checked. An
+// owner records the resource it waits on before each retry delay, and if the
+// holder of the resource waits on a resource held by the owner, directly or
+// through other owners, the acquisition fails with an AcquireError wrapping
+// ErrPotentialDeadlock instead of waiting until a ttl breaks the circular
+// wait. The caller should then release the locks it holds and start over.
+//
+// It costs a few extra commands on the first redis server per failed
+// attempt. The detection is best-effort and approximate, the graph is read
+// while the parties are moving, so a deadlock may be missed, and a cycle may
+// be reported for locks that are being released, or by every party of the
+// same cycle at once.
+func WithDeadlockDetection() LockOption {
+	return func(r *RedLock) {
+		r.deadlockDetection = true
+	}
+}
+
+// waitFor is the wait of an owner on a resource in the wait-for graph
+type waitFor struct {
+	r        *RedLock
+	cli      redisCmdable
+	owner    string
+	resource string
+	recorded bool
+}
+
+// newWaitFor returns the wait of the owner of val on resource, nil if the
+// deadlock detection is disabled or val has no owner
+func (r *RedLock) newWaitFor(resource, val string) *waitFor {
+	if !r.deadlockDetection {
+		return nil
+	}
+	owner := r.ownerOf(val)
+	if owner == "" {
+		return nil
+	}
+	return &waitFor{r: r, cli: r.clients[0].cli, owner: owner, resource: resource}
+}
+
+// waitKey returns the key recording the resource that owner waits on
+func (r *RedLock) waitKey(owner string) string {
+	return r.redisKey(waitKeyPrefix + owner)
+}
+
+// deadlocked records the wait for delay and returns whether the wait-for
+// graph has a cycle through the owner, a failure of redis, including a key
+// not found, is taken as no cycle
+func (w *waitFor) deadlocked(ctx context.Context, delay time.Duration) bool {
+	if w == nil {
+		return false
+	}
+	if err := w.cli.Do(ctx, "set", w.r.waitKey(w.owner), w.resource, "px", formatMs(delay+waitKeySlack)).Err(); err != nil {
+		return false
+	}
+	w.recorded = true
+	resource := w.resource
+	for hop := 0; hop < maxWaitHops; hop++ {
+		holder, err := w.cli.Get(ctx, w.r.redisKey(resource)).Result()
+		if err != nil {
+			return false
+		}
+		owner := w.r.ownerOf(holder)
+		if owner == "" {
+			return false
+		}
+		if owner == w.owner {
+			return true
+		}
+		resource, err = w.cli.Get(ctx, w.r.waitKey(owner)).Result()
+		if err != nil {
+			return false
+		}
+	}
+	return false
+}
+
+// done removes the wait record if it is still of the resource
+func (w *waitFor) done() {
+	if w == nil || !w.recorded {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), w.r.unlockTimeout)
+	defer cancel()
+	unlockScript.run(ctx, w.cli, []string{w.r.waitKey(w.owner)}, w.resource) // nolint:errcheck
+}
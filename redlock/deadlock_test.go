@@ -0,0 +1,100 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadlockDetection(t *testing.T) {
+	ctx := context.Background()
+	newLock := func() *RedLock {
+		lock, err := NewRedLock(ctx, redisServers, WithDeadlockDetection(),
+			WithRetryStrategy(FixedRetry(100, 20*time.Millisecond)),
+			WithOwnerProvider(func(ctx context.Context) string {
+				tenant, _ := ctx.Value(tenantKey{}).(string)
+				return tenant
+			}))
+		assert.Nil(t, err)
+		return lock
+	}
+	lockA, lockB := newLock(), newLock()
+	defer lockA.Close()
+	defer lockB.Close()
+	ctxA := context.WithValue(ctx, tenantKey{}, "workflow-a")
+	ctxB := context.WithValue(ctx, tenantKey{}, "workflow-b")
+
+	_, err := lockA.Lock(ctxA, "deadlock-x", 5*time.Second)
+	assert.Nil(t, err)
+	_, err = lockB.Lock(ctxB, "deadlock-y", 5*time.Second)
+	assert.Nil(t, err)
+
+	// b waits on x held by a
+	acquired := make(chan error, 1)
+	go func() {
+		_, err := lockB.Lock(ctxB, "deadlock-x", 5*time.Second)
+		acquired <- err
+	}()
+	waitKey := lockB.waitKey("workflow-b")
+	assert.Eventually(t, func() bool {
+		resource, _ := rawClient(lockB.clients[0]).Get(ctx, waitKey).Result()
+		return resource == "deadlock-x"
+	}, time.Second, 5*time.Millisecond)
+
+	// a waiting on y held by b would deadlock
+	start := time.Now()
+	_, err = lockA.Lock(ctxA, "deadlock-y", 5*time.Second)
+	assert.True(t, errors.Is(err, ErrPotentialDeadlock))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	n, err := rawClient(lockA.clients[0]).Exists(ctx, lockA.waitKey("workflow-a")).Result()
+	assert.Nil(t, err)
+	assert.Zero(t, n)
+
+	// a backs off, b acquires x and its wait record is removed
+	assert.Nil(t, lockA.UnLock(ctxA, "deadlock-x"))
+	assert.Nil(t, <-acquired)
+	n, err = rawClient(lockB.clients[0]).Exists(ctx, waitKey).Result()
+	assert.Nil(t, err)
+	assert.Zero(t, n)
+	assert.Nil(t, lockB.ReleaseAll(ctxB, "deadlock-x", "deadlock-y"))
+}
+
+func TestDeadlockDetectionNoOwner(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithDeadlockDetection())
+	assert.Nil(t, err)
+	defer lock.Close()
+	assert.Nil(t, lock.newWaitFor("deadlock-x", getRandStr()))
+	// a nil wait is never deadlocked
+	var wait *waitFor
+	assert.False(t, wait.deadlocked(ctx, time.Second))
+	wait.done()
+}
+
+func TestDeadlockDetectionValuePrefix(t *testing.T) {
+	ctx := context.Background()
+	newLock := func() *RedLock {
+		lock, err := NewRedLock(ctx, redisServers, WithDeadlockDetection(), WithValuePrefix("v2:"),
+			WithRetryStrategy(FixedRetry(3, 20*time.Millisecond)))
+		assert.Nil(t, err)
+		return lock
+	}
+	lockA, lockB := newLock(), newLock()
+	defer lockA.Close()
+	defer lockB.Close()
+
+	// the prefix is not an owner shared by all locks of the version, which
+	// would read as waiting on itself
+	_, err := lockA.Lock(ctx, "deadlock-prefix", 5*time.Second)
+	assert.Nil(t, err)
+	defer lockA.UnLock(ctx, "deadlock-prefix") // nolint:errcheck
+	_, err = lockB.Lock(ctx, "deadlock-prefix", 5*time.Second)
+	assert.NotNil(t, err)
+	assert.False(t, errors.Is(err, ErrPotentialDeadlock))
+	n, err := rawClient(lockB.clients[0]).Exists(ctx, lockB.waitKey("v2")).Result()
+	assert.Nil(t, err)
+	assert.Zero(t, n)
+}
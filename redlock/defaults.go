@@ -0,0 +1,57 @@
+package redlock
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultLockTTL is the ttl of LockDefault for the resources without a ttl
+// set by WithResourceDefaults
+const DefaultLockTTL = 10 * time.Second
+
+// WithResourceDefaults sets the ttl of LockDefault per resource, so the ttl
+// of well-known resources is configured in one place rather than repeated at
+// every call site. The map is copied, and every ttl must be positive.
+func WithResourceDefaults(ttls map[string]time.Duration) LockOption {
+	return func(r *RedLock) {
+		copied := make(map[string]time.Duration, len(ttls))
+		for resource, ttl := range ttls {
+			if ttl <= 0 {
+				r.setOptErr(fmt.Errorf("invalid default ttl %s of resource %s, must be positive", ttl, resource))
+				return
+			}
+			copied[resource] = ttl
+		}
+		r.resourceTTLs = copied
+	}
+}
+
+// WithDefaultTTL sets the ttl of LockDefault for the resources without a ttl
+// set by WithResourceDefaults, it is DefaultLockTTL by default.
+func WithDefaultTTL(ttl time.Duration) LockOption {
+	return func(r *RedLock) {
+		if ttl <= 0 {
+			r.setOptErr(fmt.Errorf("invalid default ttl %s, must be positive", ttl))
+			return
+		}
+		r.defaultTTL = ttl
+	}
+}
+
+// DefaultTTL returns the ttl of LockDefault for resource
+func (r *RedLock) DefaultTTL(resource string) time.Duration {
+	if ttl, ok := r.resourceTTLs[resource]; ok {
+		return ttl
+	}
+	if r.defaultTTL > 0 {
+		return r.defaultTTL
+	}
+	return DefaultLockTTL
+}
+
+// LockDefault acquires the lock of resource like Lock, with the ttl set for
+// resource by WithResourceDefaults, or the default ttl if none is set.
+func (r *RedLock) LockDefault(ctx context.Context, resource string) (time.Duration, error) {
+	return r.Lock(ctx, resource, r.DefaultTTL(resource))
+}
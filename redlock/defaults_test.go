@@ -0,0 +1,50 @@
+package redlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceDefaults(t *testing.T) {
+	ctx := context.Background()
+	ttls := map[string]time.Duration{"defaults-foo": 2 * time.Second}
+	lock, err := NewRedLock(ctx, redisServers, WithResourceDefaults(ttls))
+	assert.Nil(t, err)
+	defer lock.Close()
+	// the map is copied
+	ttls["defaults-foo"] = time.Hour
+	assert.Equal(t, 2*time.Second, lock.DefaultTTL("defaults-foo"))
+	assert.Equal(t, DefaultLockTTL, lock.DefaultTTL("defaults-bar"))
+
+	validity, err := lock.LockDefault(ctx, "defaults-foo")
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(time.Second))
+	assert.LessOrEqual(t, int64(validity), int64(2*time.Second))
+	ttl := rawClient(lock.clients[0]).PTTL(ctx, "defaults-foo").Val()
+	assert.Greater(t, int64(ttl), int64(time.Second))
+	assert.LessOrEqual(t, int64(ttl), int64(2*time.Second))
+	assert.Nil(t, lock.UnLock(ctx, "defaults-foo"))
+
+	// the other resources fall back to the global default
+	lock, err = NewRedLock(ctx, redisServers, WithResourceDefaults(ttls), WithDefaultTTL(3*time.Second))
+	assert.Nil(t, err)
+	defer lock.Close()
+	assert.Equal(t, time.Hour, lock.DefaultTTL("defaults-foo"))
+	validity, err = lock.LockDefault(ctx, "defaults-bar")
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(2*time.Second))
+	assert.LessOrEqual(t, int64(validity), int64(3*time.Second))
+	assert.Nil(t, lock.UnLock(ctx, "defaults-bar"))
+
+	for _, opt := range []Option{
+		WithResourceDefaults(map[string]time.Duration{"defaults-foo": 0}),
+		WithResourceDefaults(map[string]time.Duration{"defaults-foo": -time.Second}),
+		WithDefaultTTL(0),
+	} {
+		_, err = NewRedLock(ctx, redisServers, opt)
+		assert.NotNil(t, err)
+	}
+}
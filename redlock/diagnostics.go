@@ -0,0 +1,108 @@
+package redlock
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// InstanceEvent is the outcome of setting a lock on a single instance during
+// an acquisition attempt
+type InstanceEvent struct {
+	Resource string
+	// Addr is the address of instance
+	Addr string
+	// Locked is whether the lock is set on the instance
+	Locked bool
+	// Holder is the value of lock held by others, if reported by instance
+	Holder string
+	// Err is the error of the instance, nil if it replied
+	Err error
+	// Start is when the instance was asked to set the lock
+	Start time.Time
+	// Latency is how long the instance took to reply
+	Latency time.Duration
+}
+
+// diagnostics holds the listeners attached by AttachDiagnostics
+type diagnostics struct {
+	mu      sync.Mutex
+	lastID  int
+	fns     map[int]func(InstanceEvent)
+	current atomic.Value // []func(InstanceEvent)
+}
+
+// AttachDiagnostics attaches a listener that receives the outcome of every
+// instance in each acquisition attempt as it happens, for live debugging of
+// a flaky deployment. It returns a function that detaches the listener, and
+// is meant for transient sessions rather than permanent metrics. The
+// listener is called synchronously by the goroutine of each instance, so it
+// must be fast and safe for concurrent use, it is picked up by the
+// acquisitions starting after it is attached. Acquisition pays nothing when
+// no listener is attached. The listeners are of r only, not of its clones.
+func (r *RedLock) AttachDiagnostics(fn func(InstanceEvent)) (detach func()) {
+	d := &r.diag
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.fns == nil {
+		d.fns = make(map[int]func(InstanceEvent))
+	}
+	d.lastID++
+	id := d.lastID
+	d.fns[id] = fn
+	d.publish()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			delete(d.fns, id)
+			d.publish()
+		})
+	}
+}
+
+// publish makes the current listeners visible to acquisition, it must be
+// called with mu held
+func (d *diagnostics) publish() {
+	fns := make([]func(InstanceEvent), 0, len(d.fns))
+	for id := 1; id <= d.lastID; id++ {
+		if fn, ok := d.fns[id]; ok {
+			fns = append(fns, fn)
+		}
+	}
+	d.current.Store(fns)
+}
+
+// listeners returns the attached listeners
+func (d *diagnostics) listeners() []func(InstanceEvent) {
+	fns, _ := d.current.Load().([]func(InstanceEvent))
+	return fns
+}
+
+// diagnose wraps lockFn to report the outcome of each instance to the
+// attached listeners, lockFn is returned as is without listener
+func (r *RedLock) diagnose(resource string, lockFn lockFunc) lockFunc {
+	fns := r.diag.listeners()
+	if len(fns) == 0 {
+		return lockFn
+	}
+	return func(ctx context.Context, cli *RedClient) lockResult {
+		start := time.Now()
+		res := lockFn(ctx, cli)
+		ev := InstanceEvent{
+			Resource: resource,
+			Addr:     cli.addr,
+			Locked:   res.locked,
+			Holder:   res.holder,
+			Err:      res.err,
+			Start:    start,
+			Latency:  time.Since(start),
+		}
+		for _, fn := range fns {
+			fn(ev)
+		}
+		return res
+	}
+}
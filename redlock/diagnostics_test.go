@@ -0,0 +1,61 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockDiagnostics(t *testing.T) {
+	ctx := context.Background()
+	errInjected := errors.New("injected error")
+	faulty := &mockCmdable{
+		setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+			return false, errInjected
+		},
+	}
+	lock := newMockRedLock(t, &mockCmdable{}, faulty, &mockCmdable{})
+
+	var (
+		mu     sync.Mutex
+		events []InstanceEvent
+	)
+	detach := lock.AttachDiagnostics(func(ev InstanceEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, ev)
+	})
+	before := time.Now()
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+
+	assert.Len(t, events, 3)
+	sort.Slice(events, func(i, j int) bool { return events[i].Addr < events[j].Addr })
+	for idx, ev := range events {
+		assert.Equal(t, "foo", ev.Resource)
+		assert.Equal(t, lock.clients[idx].addr, ev.Addr)
+		assert.False(t, ev.Start.Before(before))
+		assert.GreaterOrEqual(t, int64(ev.Latency), int64(0))
+	}
+	assert.True(t, events[0].Locked)
+	assert.Nil(t, events[0].Err)
+	assert.False(t, events[1].Locked)
+	assert.True(t, errors.Is(events[1].Err, errInjected))
+	assert.True(t, events[2].Locked)
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+
+	// a detached listener receives nothing, detaching again is a no-op
+	detach()
+	detach()
+	events = nil
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Empty(t, events)
+	assert.Empty(t, lock.diag.listeners())
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+}
@@ -0,0 +1,75 @@
+package redlock
+
+import (
+	crand "crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// EntropyPolicy decides how to generate a lock value when crypto/rand fails
+type EntropyPolicy int
+
+// entropy policies
+const (
+	// EntropyFail fails the acquisition with an error wrapping ErrEntropy, so
+	// no lock is ever held with a guessable value. It is the default.
+	EntropyFail EntropyPolicy = iota
+	// EntropyFallback falls back to math/rand seeded by time and pid, with a
+	// warning logged. Acquisition keeps working, but the value is predictable
+	// to anyone knowing the seed, and values of processes started at the same
+	// time may collide, so another client may release or extend the lock.
+	EntropyFallback
+	// EntropyPanic panics, which suits programs preferring to crash and be
+	// restarted over running on a broken system.
+	EntropyPanic
+)
+
+// ErrEntropy means no random bytes could be read to generate a lock value
+var ErrEntropy = errors.New("failed to read random bytes")
+
+// randRead reads random bytes for lock values
+var randRead = crand.Read
+
+// fallbackRand is the math/rand source of EntropyFallback
+var fallbackRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{Rand: rand.New(rand.NewSource(time.Now().UnixNano() ^ int64(os.Getpid())<<32))}
+
+// WithEntropyPolicy sets how to generate a lock value when crypto/rand is
+// unavailable, which may happen momentarily in some containers or early boot.
+func WithEntropyPolicy(policy EntropyPolicy) LockOption {
+	return func(r *RedLock) {
+		if policy < EntropyFail || policy > EntropyPanic {
+			r.setOptErr(fmt.Errorf("invalid entropy policy %d", policy))
+			return
+		}
+		r.entropyPolicy = policy
+	}
+}
+
+// readEntropy fills b with random bytes, applying the entropy policy if
+// crypto/rand fails
+func (r *RedLock) readEntropy(b []byte) error {
+	_, err := randRead(b)
+	if err == nil {
+		return nil
+	}
+	switch r.entropyPolicy {
+	case EntropyFallback:
+		log.Printf("redlock: crypto/rand is unavailable, falling back to math/rand: %v", err)
+		fallbackRand.Lock()
+		fallbackRand.Read(b) // nolint:errcheck
+		fallbackRand.Unlock()
+		return nil
+	case EntropyPanic:
+		panic(fmt.Sprintf("redlock: crypto/rand is unavailable: %v", err))
+	default:
+		return fmt.Errorf("%w: %v", ErrEntropy, err)
+	}
+}
@@ -0,0 +1,50 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockEntropyPolicy(t *testing.T) {
+	ctx := context.Background()
+	_, err := NewRedLock(ctx, []string{"tcp://mock0:6379"}, WithEntropyPolicy(EntropyPolicy(-1)))
+	assert.NotNil(t, err)
+	_, err = NewRedLock(ctx, []string{"tcp://mock0:6379"}, WithEntropyPolicy(EntropyPanic+1))
+	assert.NotNil(t, err)
+
+	errInjected := errors.New("injected error")
+	defer func(read func([]byte) (int, error)) { randRead = read }(randRead)
+	randRead = func([]byte) (int, error) {
+		return 0, errInjected
+	}
+
+	// fails by default
+	lock := newMockRedLock(t, &mockCmdable{})
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.True(t, errors.Is(err, ErrEntropy))
+	ok, err := lock.NewMutex("foo", time.Second).TryLock(ctx)
+	assert.False(t, ok)
+	assert.True(t, errors.Is(err, ErrEntropy))
+	assert.Equal(t, 0, lock.cache.Size())
+
+	// falls back to math/rand
+	lock = newMockRedLockWithOptions(t, []redisCmdable{&mockCmdable{}}, WithEntropyPolicy(EntropyFallback))
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	_, err = lock.Lock(ctx, "bar", time.Second)
+	assert.Nil(t, err)
+	foo, err := lock.cache.Get("foo")
+	assert.Nil(t, err)
+	bar, err := lock.cache.Get("bar")
+	assert.Nil(t, err)
+	assert.NotEqual(t, foo.Val, bar.Val)
+
+	lock = newMockRedLockWithOptions(t, []redisCmdable{&mockCmdable{}}, WithEntropyPolicy(EntropyPanic))
+	assert.Panics(t, func() {
+		lock.Lock(ctx, "foo", time.Second) // nolint:errcheck
+	})
+}
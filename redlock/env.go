@@ -0,0 +1,57 @@
+package redlock
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+// environment variables read by WithEnvConfig
+const (
+	EnvRetryCount  = "REDLOCK_RETRY_COUNT"
+	EnvRetryDelay  = "REDLOCK_RETRY_DELAY"
+	EnvDriftFactor = "REDLOCK_DRIFT_FACTOR"
+)
+
+// WithEnvConfig makes the RedLock read its retry count, retry delay in
+// millisecond and clock drift factor from the environment variables
+// REDLOCK_RETRY_COUNT, REDLOCK_RETRY_DELAY and REDLOCK_DRIFT_FACTOR, which
+// override the defaults and the options of the same settings. The setters
+// called after creation still take precedence. The creation fails if any
+// variable is invalid, and the applied values are logged.
+func WithEnvConfig() LockOption {
+	return func(r *RedLock) {
+		r.envConfig = true
+	}
+}
+
+// applyEnvConfig reads the environment variables set, validates them and
+// applies them to r
+func (r *RedLock) applyEnvConfig() error {
+	if v, ok := os.LookupEnv(EnvRetryCount); ok {
+		count, err := strconv.Atoi(v)
+		if err != nil || count <= 0 {
+			return fmt.Errorf("invalid %s %q, must be a positive integer", EnvRetryCount, v)
+		}
+		r.retryCount = count
+		log.Printf("redlock: %s applied, retry count %d", EnvRetryCount, count)
+	}
+	if v, ok := os.LookupEnv(EnvRetryDelay); ok {
+		delay, err := strconv.Atoi(v)
+		if err != nil || delay <= 0 {
+			return fmt.Errorf("invalid %s %q, must be a positive integer in millisecond", EnvRetryDelay, v)
+		}
+		r.retryDelay = delay
+		log.Printf("redlock: %s applied, retry delay %dms", EnvRetryDelay, delay)
+	}
+	if v, ok := os.LookupEnv(EnvDriftFactor); ok {
+		factor, err := strconv.ParseFloat(v, 64)
+		if err != nil || factor < 0 || factor >= 1 {
+			return fmt.Errorf("invalid %s %q, must be in [0, 1)", EnvDriftFactor, v)
+		}
+		r.driftFactor = factor
+		log.Printf("redlock: %s applied, clock drift factor %v", EnvDriftFactor, factor)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package redlock
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvConfig(t *testing.T) {
+	ctx := context.Background()
+	setenv := func(kvs map[string]string) {
+		for _, k := range []string{EnvRetryCount, EnvRetryDelay, EnvDriftFactor} {
+			os.Unsetenv(k)
+		}
+		for k, v := range kvs {
+			os.Setenv(k, v)
+		}
+	}
+	defer setenv(nil)
+
+	setenv(map[string]string{EnvRetryCount: "3", EnvRetryDelay: "50", EnvDriftFactor: "0.02"})
+	// env is ignored without WithEnvConfig
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultRetryCount, lock.retryCount)
+
+	lock, err = NewRedLock(ctx, redisServers, WithEnvConfig())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, lock.retryCount)
+	assert.Equal(t, 50, lock.retryDelay)
+	assert.Equal(t, 0.02, lock.driftFactor)
+	// setters take precedence over env
+	lock.SetRetryCount(5)
+	assert.Equal(t, 5, lock.retryCount)
+
+	// unset variables keep the defaults
+	setenv(map[string]string{EnvRetryCount: "3"})
+	lock, err = NewRedLock(ctx, redisServers, WithEnvConfig())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, lock.retryCount)
+	assert.Equal(t, DefaultRetryDelay, lock.retryDelay)
+	assert.Equal(t, ClockDriftFactor, lock.driftFactor)
+
+	for _, kvs := range []map[string]string{
+		{EnvRetryCount: "0"},
+		{EnvRetryCount: "x"},
+		{EnvRetryDelay: "-1"},
+		{EnvDriftFactor: "1"},
+		{EnvDriftFactor: "abc"},
+	} {
+		setenv(kvs)
+		_, err = NewRedLock(ctx, redisServers, WithEnvConfig())
+		assert.NotNil(t, err, "%v", kvs)
+	}
+}
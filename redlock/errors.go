@@ -0,0 +1,125 @@
+package redlock
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RedlockError is implemented by the errors carrying the resource they
+// happened on, use errors.As to inspect the details.
+type RedlockError interface {
+	error
+	Resource() string
+}
+
+var (
+	_ RedlockError = &AcquireError{}
+	_ RedlockError = &QuorumError{}
+	_ RedlockError = &UnlockError{}
+	_ RedlockError = &CacheError{}
+)
+
+// ErrCacheUnavailable means the local cache failed to look up a lock, which
+// is then unknown rather than not held
+var ErrCacheUnavailable = errors.New("local cache is unavailable")
+
+// AcquireError means acquiring a lock failed for a reason other than missing
+// quorum, Err is the cause, such as ErrConditionFailed, ErrNoValidity or
+// ErrRetryBudgetExhausted.
+type AcquireError struct {
+	resource string
+	Err      error
+}
+
+// Resource implements RedlockError
+func (e *AcquireError) Resource() string {
+	return e.resource
+}
+
+func (e *AcquireError) Error() string {
+	return fmt.Sprintf("%s: %v", e.resource, e.Err)
+}
+
+// Unwrap returns the cause, so errors.Is works with the sentinel errors
+func (e *AcquireError) Unwrap() error {
+	return e.Err
+}
+
+// QuorumError means a lock was not acquired on quorum after max retry time,
+// Errs holds the per-instance errors of the last attempt, in the same order
+// as the redis servers, nil for the instances that were locked or held by
+// others. For MultiClusterLock, the quorum and errors are of clusters
+// instead. It unwraps to ErrAcquireLock.
+type QuorumError struct {
+	resource string
+	Acquired int
+	Quorum   int
+	Errs     []error
+}
+
+// Resource implements RedlockError
+func (e *QuorumError) Resource() string {
+	return e.resource
+}
+
+func (e *QuorumError) Error() string {
+	return fmt.Sprintf("%s: %v, acquired on %d of %d instances, quorum %d",
+		e.resource, ErrAcquireLock, e.Acquired, len(e.Errs), e.Quorum)
+}
+
+// Unwrap returns ErrAcquireLock
+func (e *QuorumError) Unwrap() error {
+	return ErrAcquireLock
+}
+
+// UnlockError means a lock failed to be released on so many instances that
+// it may still prevent others from acquiring it on quorum until it expires.
+// Errs holds the per-instance errors, in the same order as the redis servers.
+type UnlockError struct {
+	resource string
+	Errs     []error
+}
+
+// Resource implements RedlockError
+func (e *UnlockError) Resource() string {
+	return e.resource
+}
+
+func (e *UnlockError) Error() string {
+	failed := 0
+	for _, err := range e.Errs {
+		if err != nil {
+			failed++
+		}
+	}
+	return fmt.Sprintf("%s: failed to release lock on %d of %d instances", e.resource, failed, len(e.Errs))
+}
+
+// CacheError means the local cache failed to look up the lock of a resource,
+// so whether it is held and its value are unknown. Err is the error of the
+// cache, and it matches ErrCacheUnavailable by errors.Is. The lock may still
+// be held on redis until it expires, release it by UnlockByValue if its value
+// is known, such as by Lock.Value.
+type CacheError struct {
+	resource string
+	Err      error
+}
+
+// Resource implements RedlockError
+func (e *CacheError) Resource() string {
+	return e.resource
+}
+
+func (e *CacheError) Error() string {
+	return fmt.Sprintf("%s: %v: %v", e.resource, ErrCacheUnavailable, e.Err)
+}
+
+// Unwrap returns the error of the cache
+func (e *CacheError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrCacheUnavailable
+func (e *CacheError) Is(target error) bool {
+	return target == ErrCacheUnavailable
+}
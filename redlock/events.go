@@ -0,0 +1,119 @@
+package redlock
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// EventType is the type of a lock lifecycle transition
+type EventType int
+
+// lock lifecycle event types
+const (
+	// EventAcquired means a lock is acquired
+	EventAcquired EventType = iota
+	// EventRenewed means the ttl of a lock is extended
+	EventRenewed
+	// EventReleased means a lock is released
+	EventReleased
+	// EventLost means a lock renewed in background is lost
+	EventLost
+	// EventAcquireFailed means an acquisition fails
+	EventAcquireFailed
+	// EventLongHold means a lock is released after holding most of its
+	// validity, see WithLongHoldWarning
+	EventLongHold
+	// EventReleaseFailed means a release fails on too many instances for
+	// others to acquire the lock on quorum
+	EventReleaseFailed
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventAcquired:
+		return "acquired"
+	case EventRenewed:
+		return "renewed"
+	case EventReleased:
+		return "released"
+	case EventLost:
+		return "lost"
+	case EventAcquireFailed:
+		return "acquire_failed"
+	case EventLongHold:
+		return "long_hold"
+	case EventReleaseFailed:
+		return "release_failed"
+	default:
+		return "unknown"
+	}
+}
+
+// LockEvent is a lock lifecycle transition
+type LockEvent struct {
+	Type     EventType
+	Resource string
+	Time     time.Time
+	// Validity is the remaining validity of Acquired and Renewed events, and
+	// the validity of LongHold events when acquired or last extended
+	Validity time.Duration
+	// Held is how long the lock was held since acquired or last extended of
+	// LongHold events
+	Held time.Duration
+	// Err is the cause of Lost, AcquireFailed and ReleaseFailed events
+	Err error
+}
+
+// lockEvents is a non-blocking event stream, events are dropped if the
+// buffer is full
+type lockEvents struct {
+	// dropped is accessed atomically, keep it 64-bit aligned
+	dropped int64
+	ch      chan LockEvent
+}
+
+// WithEvents enables the lifecycle events of all resources, which are
+// delivered on the channel returned by Events with a buffer of size events.
+// Emitting never blocks locking, events are dropped when the buffer is full,
+// the count of dropped events is reported by DroppedEvents.
+func WithEvents(size int) LockOption {
+	return func(r *RedLock) {
+		if size < 0 {
+			size = 0
+		}
+		r.events = &lockEvents{ch: make(chan LockEvent, size)}
+	}
+}
+
+// Events returns the channel of lifecycle events, it returns nil if events
+// are not enabled by WithEvents.
+func (r *RedLock) Events() <-chan LockEvent {
+	if r.events == nil {
+		return nil
+	}
+	return r.events.ch
+}
+
+// DroppedEvents returns the count of events dropped due to a full buffer
+func (r *RedLock) DroppedEvents() int64 {
+	if r.events == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&r.events.dropped)
+}
+
+func (r *RedLock) emit(tp EventType, resource string, validity time.Duration, err error) {
+	if r.events == nil {
+		return
+	}
+	r.sendEvent(LockEvent{Type: tp, Resource: resource, Time: time.Now(), Validity: validity, Err: err})
+}
+
+// sendEvent delivers ev without blocking, events must be enabled
+func (r *RedLock) sendEvent(ev LockEvent) {
+	select {
+	case r.events.ch <- ev:
+	default:
+		atomic.AddInt64(&r.events.dropped, 1)
+	}
+}
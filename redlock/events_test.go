@@ -0,0 +1,110 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockEvents(t *testing.T) {
+	ctx := context.Background()
+	lost := int32(0)
+	instance := func() *mockCmdable {
+		return &mockCmdable{
+			eval: func(_ context.Context, script string, _ []string, _ ...interface{}) (interface{}, error) {
+				if strings.Contains(script, "pexpire") && atomic.LoadInt32(&lost) == 1 {
+					return int64(0), nil
+				}
+				return int64(1), nil
+			},
+		}
+	}
+	lock := newMockRedLockWithOptions(t, []redisCmdable{instance(), instance(), instance()}, WithEvents(10))
+	assert.Nil(t, newMockRedLock(t, instance(), instance(), instance()).Events())
+
+	l, err := lock.Acquire(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	_, err = l.ExtendTo(ctx, time.Second)
+	assert.Nil(t, err)
+	lock.SetRetryCount(1)
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.True(t, errors.Is(err, ErrAlreadyHeld))
+	assert.Nil(t, l.Unlock(ctx))
+
+	expected := []EventType{EventAcquired, EventRenewed, EventAcquireFailed, EventReleased}
+	for _, tp := range expected {
+		ev := <-lock.Events()
+		assert.Equal(t, tp, ev.Type, tp.String())
+		assert.Equal(t, "foo", ev.Resource)
+		assert.False(t, ev.Time.IsZero())
+		switch tp {
+		case EventAcquired, EventRenewed:
+			assert.Greater(t, int64(ev.Validity), int64(0))
+		case EventAcquireFailed:
+			assert.True(t, errors.Is(ev.Err, ErrAlreadyHeld))
+		}
+	}
+
+	// the lock renewed in background is lost
+	ch, err := lock.LockStream(ctx, "foo", 30*time.Millisecond)
+	assert.Nil(t, err)
+	atomic.StoreInt32(&lost, 1)
+	for range ch {
+	}
+	assert.Equal(t, EventAcquired, (<-lock.Events()).Type)
+	ev := <-lock.Events()
+	for ev.Type == EventRenewed {
+		ev = <-lock.Events()
+	}
+	assert.Equal(t, EventLost, ev.Type)
+	assert.Equal(t, ErrExtendLock, ev.Err)
+	assert.Zero(t, lock.DroppedEvents())
+}
+
+func TestMockEventsDropped(t *testing.T) {
+	ctx := context.Background()
+	lock := newMockRedLockWithOptions(t, []redisCmdable{&mockCmdable{}, &mockCmdable{}, &mockCmdable{}}, WithEvents(1))
+	for i := 0; i < 3; i++ {
+		_, err := lock.Lock(ctx, "foo", time.Second)
+		assert.Nil(t, err)
+		assert.Nil(t, lock.UnLock(ctx, "foo"))
+	}
+	// emitting never blocks, events beyond the buffer are dropped
+	assert.Equal(t, int64(5), lock.DroppedEvents())
+	assert.Equal(t, EventAcquired, (<-lock.Events()).Type)
+}
+
+func TestMockReleaseFailedEvent(t *testing.T) {
+	ctx := context.Background()
+	instance := func() *mockCmdable {
+		return &mockCmdable{
+			eval: func(context.Context, string, []string, ...interface{}) (interface{}, error) {
+				return nil, errors.New("injected error")
+			},
+		}
+	}
+	hook := &recordAuditHook{}
+	lock := newMockRedLockWithOptions(t, []redisCmdable{instance(), instance(), instance()},
+		WithEvents(10), WithAuditHook(hook))
+
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	var ue *UnlockError
+	assert.True(t, errors.As(lock.UnLock(ctx, "foo"), &ue))
+	assert.True(t, errors.As(lock.ReleaseAll(ctx, "foo"), &ue))
+
+	// the failed releases are not reported as released
+	assert.Equal(t, EventAcquired, (<-lock.Events()).Type)
+	for i := 0; i < 2; i++ {
+		ev := <-lock.Events()
+		assert.Equal(t, EventReleaseFailed, ev.Type, ev.Type.String())
+		assert.True(t, errors.As(ev.Err, &ue))
+	}
+	assert.Len(t, hook.records, 1)
+	assert.Equal(t, "acquired", hook.records[0].event)
+}
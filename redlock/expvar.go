@@ -0,0 +1,79 @@
+package redlock
+
+import (
+	"expvar"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	expvarOnce   sync.Once
+	expvarRoot   *expvar.Map
+	expvarLastID int64
+)
+
+// expvarStats holds the counters of a RedLock published via expvar
+type expvarStats struct {
+	id         string
+	acquires   *expvar.Int
+	failures   *expvar.Int
+	retries    *expvar.Int
+	noValidity *expvar.Int
+	zeroMargin *expvar.Int
+	longHolds  *expvar.Int
+}
+
+// WithExpvar publishes the counters of RedLock via expvar, under the
+// `redlock` map and namespaced by an instance id, so multiple RedLock
+// instances don't collide. The variables of each instance are
+// - acquires: count of successful lock acquisitions
+// - failures: count of failed lock acquisitions
+// - retries: count of acquisition retries
+// - no_validity: count of attempts that reached quorum without remaining validity
+// - zero_margin: count of acquisitions on exactly quorum, see Lock.Margin
+// - active: count of locks in local cache
+// - cache_hits, cache_misses: counts of cache lookups on release, see CacheStats
+// - long_holds: count of locks released after a long hold, see WithLongHoldWarning
+func WithExpvar() LockOption {
+	return func(r *RedLock) {
+		r.expvar = &expvarStats{}
+	}
+}
+
+// publish registers the variables of stats, it must be called after the
+// cache of RedLock is initialized.
+func (s *expvarStats) publish(r *RedLock) {
+	expvarOnce.Do(func() {
+		expvarRoot = expvar.NewMap("redlock")
+	})
+	s.id = strconv.FormatInt(atomic.AddInt64(&expvarLastID, 1), 10)
+	s.acquires = new(expvar.Int)
+	s.failures = new(expvar.Int)
+	s.retries = new(expvar.Int)
+	s.noValidity = new(expvar.Int)
+	s.zeroMargin = new(expvar.Int)
+	s.longHolds = new(expvar.Int)
+	m := new(expvar.Map).Init()
+	m.Set("acquires", s.acquires)
+	m.Set("failures", s.failures)
+	m.Set("retries", s.retries)
+	m.Set("no_validity", s.noValidity)
+	m.Set("zero_margin", s.zeroMargin)
+	m.Set("long_holds", s.longHolds)
+	m.Set("active", expvar.Func(func() interface{} {
+		return r.cache.Size()
+	}))
+	m.Set("cache_hits", expvar.Func(func() interface{} {
+		return r.CacheStats().Hits
+	}))
+	m.Set("cache_misses", expvar.Func(func() interface{} {
+		return r.CacheStats().Misses
+	}))
+	expvarRoot.Set(s.id, m)
+}
+
+// unpublish removes the variables of stats, which refer to the RedLock
+func (s *expvarStats) unpublish() {
+	expvarRoot.Delete(s.id)
+}
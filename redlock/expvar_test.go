@@ -0,0 +1,56 @@
+package redlock
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpvar(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithExpvar())
+	assert.Nil(t, err)
+	lock2, err := NewRedLock(ctx, redisServers, WithExpvar())
+	assert.Nil(t, err)
+	lock2.SetRetryCount(2)
+	lock2.SetRetryDelay(10)
+	assert.NotEqual(t, lock.expvar.id, lock2.expvar.id)
+
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	_, err = lock2.Lock(ctx, "foo", time.Second)
+	assert.NotNil(t, err)
+
+	vars := func(id string) map[string]int {
+		m := map[string]int{}
+		v := expvar.Get("redlock").(*expvar.Map).Get(id)
+		assert.Nil(t, json.Unmarshal([]byte(v.String()), &m))
+		return m
+	}
+	assert.Equal(t, map[string]int{"acquires": 1, "failures": 0, "retries": 0, "no_validity": 0, "zero_margin": 0, "active": 1, "cache_hits": 0, "cache_misses": 0, "long_holds": 0}, vars(lock.expvar.id))
+	assert.Equal(t, map[string]int{"acquires": 0, "failures": 1, "retries": 1, "no_validity": 0, "zero_margin": 0, "active": 0, "cache_hits": 0, "cache_misses": 0, "long_holds": 0}, vars(lock2.expvar.id))
+
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+	assert.Equal(t, 0, vars(lock.expvar.id)["active"])
+	assert.Equal(t, 1, vars(lock.expvar.id)["cache_hits"])
+
+	// acquired on exactly quorum
+	cli := rawClient(lock.clients[1])
+	assert.Nil(t, cli.Set(ctx, "foo", "others", 0).Err())
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, vars(lock.expvar.id)["zero_margin"])
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+	assert.Nil(t, cli.Del(ctx, "foo").Err())
+
+	// the stats are removed on Close
+	assert.Nil(t, lock2.Close())
+	assert.Nil(t, expvar.Get("redlock").(*expvar.Map).Get(lock2.expvar.id))
+	assert.NotNil(t, expvar.Get("redlock").(*expvar.Map).Get(lock.expvar.id))
+	assert.Nil(t, lock.Close())
+	assert.Nil(t, expvar.Get("redlock").(*expvar.Map).Get(lock.expvar.id))
+}
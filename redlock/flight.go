@@ -0,0 +1,118 @@
+package redlock
+
+import (
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// localFlight coalesces concurrent acquisitions of the same resource in
+// process, and counts the local holders of each shared lock
+type localFlight struct {
+	group singleflight.Group
+
+	mu   sync.Mutex
+	refs map[string]int
+	// releasing is closed when the release of the lock of resource is done
+	releasing map[string]chan struct{}
+}
+
+// WithLocalSingleflight coalesces concurrent Acquire and Lock calls of the
+// same resource in process into a single acquisition on redis, the acquired
+// lock is shared by all callers of the flight. The lock is reference counted,
+// each caller holds a reference and must unlock it, the lock is released on
+// redis only when the last local holder unlocks. Note the acquisition runs
+// with the ctx of the caller who starts the flight, its cancellation fails
+// all the callers. An acquisition of a lock being released locally waits
+// until the release is done, the lock failed to be released is kept with the
+// reference of its last holder, who can retry the release.
+func WithLocalSingleflight() LockOption {
+	return func(r *RedLock) {
+		r.flight = &localFlight{refs: make(map[string]int), releasing: make(map[string]chan struct{})}
+	}
+}
+
+// acquire runs fn for resource, concurrent callers share the result of a
+// single fn call, each caller that gets the lock holds a reference of it
+func (f *localFlight) acquire(r *RedLock, resource string, fn func() (*Lock, error)) (*Lock, error) {
+	for {
+		// the lock being released is still in cache until the release is
+		// done, acquiring it meanwhile would find it held
+		f.wait(resource)
+		v, err, _ := f.group.Do(resource, func() (interface{}, error) {
+			return fn()
+		})
+		if err != nil {
+			return nil, err
+		}
+		l := v.(*Lock)
+		if f.ref(r, resource, l.val) {
+			return l, nil
+		}
+		// all the other holders unlocked before we joined, acquire again
+	}
+}
+
+// ref adds a reference to the lock of resource if it is still held with val
+// and not being released
+func (f *localFlight) ref(r *RedLock, resource, val string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.releasing[resource]; ok {
+		return false
+	}
+	elem, err := r.cache.Get(resource)
+	if err != nil || elem == nil || elem.Val != val {
+		return false
+	}
+	f.refs[resource]++
+	return true
+}
+
+// unref drops a reference to the lock of resource, it returns true if the
+// lock should be released. The lock is marked as being released along with
+// its last reference until done, so late callers of the flight won't join it,
+// while it stays in cache so a failed release can be retried.
+func (f *localFlight) unref(resource string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.releasing[resource]; ok {
+		// released by another call
+		return false
+	}
+	if f.refs[resource] > 1 {
+		f.refs[resource]--
+		return false
+	}
+	f.releasing[resource] = make(chan struct{})
+	return true
+}
+
+// done ends the release of resource started by unref, the last reference is
+// restored if the release failed
+func (f *localFlight) done(resource string, released bool) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if released {
+		delete(f.refs, resource)
+	} else {
+		f.refs[resource] = 1
+	}
+	if ch, ok := f.releasing[resource]; ok {
+		close(ch)
+		delete(f.releasing, resource)
+	}
+}
+
+// wait waits until the release of resource is done if any
+func (f *localFlight) wait(resource string) {
+	f.mu.Lock()
+	ch := f.releasing[resource]
+	f.mu.Unlock()
+	if ch != nil {
+		<-ch
+	}
+}
@@ -0,0 +1,106 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockLocalSingleflight(t *testing.T) {
+	ctx := context.Background()
+	var attempts, unlocks int32
+	instance := func() *mockCmdable {
+		return &mockCmdable{
+			setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+				atomic.AddInt32(&attempts, 1)
+				// keep the flight open for the other callers to join
+				time.Sleep(100 * time.Millisecond)
+				return true, nil
+			},
+			eval: func(context.Context, string, []string, ...interface{}) (interface{}, error) {
+				atomic.AddInt32(&unlocks, 1)
+				return int64(1), nil
+			},
+		}
+	}
+	lock := newMockRedLockWithOptions(t, []redisCmdable{instance(), instance(), instance()}, WithLocalSingleflight())
+
+	const callers = 5
+	var wg sync.WaitGroup
+	locks := make([]*Lock, callers)
+	for i := 0; i < callers; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l, err := lock.Acquire(ctx, "foo", time.Second)
+			assert.Nil(t, err)
+			locks[i] = l
+		}()
+	}
+	wg.Wait()
+	// a single acquisition on redis is shared by all callers
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	for _, l := range locks[1:] {
+		assert.Equal(t, locks[0].Value(), l.Value())
+	}
+
+	// released on redis only when the last holder unlocks
+	for _, l := range locks[:callers-1] {
+		assert.Nil(t, l.Unlock(ctx))
+		assert.True(t, lock.Owns("foo"))
+	}
+	assert.Zero(t, atomic.LoadInt32(&unlocks))
+	assert.Nil(t, locks[callers-1].Unlock(ctx))
+	assert.False(t, lock.Owns("foo"))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&unlocks))
+
+	// a new flight acquires again
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(6), atomic.LoadInt32(&attempts))
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+	assert.Equal(t, int32(6), atomic.LoadInt32(&unlocks))
+}
+
+func TestMockLocalSingleflightRetryRelease(t *testing.T) {
+	ctx := context.Background()
+	var failing, unlocks int32
+	instance := func() *mockCmdable {
+		return &mockCmdable{
+			eval: func(context.Context, string, []string, ...interface{}) (interface{}, error) {
+				atomic.AddInt32(&unlocks, 1)
+				if atomic.LoadInt32(&failing) == 1 {
+					return nil, errors.New("injected error")
+				}
+				return int64(1), nil
+			},
+		}
+	}
+	lock := newMockRedLockWithOptions(t, []redisCmdable{instance(), instance(), instance()}, WithLocalSingleflight())
+
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	atomic.StoreInt32(&failing, 1)
+	var ue *UnlockError
+	assert.True(t, errors.As(lock.UnLock(ctx, "foo"), &ue))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&unlocks))
+
+	// the lock failed to be released is kept with its reference
+	assert.True(t, lock.Owns("foo"))
+	atomic.StoreInt32(&failing, 0)
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+	assert.Equal(t, int32(6), atomic.LoadInt32(&unlocks))
+	assert.False(t, lock.Owns("foo"))
+
+	// and acquired again after released
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.ReleaseAll(ctx, "foo"))
+	assert.False(t, lock.Owns("foo"))
+}
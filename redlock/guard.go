@@ -0,0 +1,84 @@
+package redlock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Guard is a lock handle that captures everything needed to release it, so
+// the resource needn't be repeated when releasing:
+//
+//	g, err := lockMgr.Guard(ctx, "resource_name", time.Second)
+//	if err != nil {
+//		return err
+//	}
+//	defer g.Unlock()
+type Guard struct {
+	l   *Lock
+	ctx context.Context
+
+	mu         sync.Mutex
+	validUntil time.Time
+	released   bool
+}
+
+// Guard acquires a distribute lock the same as Lock, and returns a Guard
+// bound to ctx, which is used by its Extend and Unlock as well.
+func (r *RedLock) Guard(ctx context.Context, resource string, ttl time.Duration) (*Guard, error) {
+	l, err := r.Acquire(ctx, resource, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return &Guard{
+		l:          l,
+		ctx:        ctx,
+		validUntil: l.acquiredAt.Add(l.validity),
+	}, nil
+}
+
+// Lock returns the underlying lock handle
+func (g *Guard) Lock() *Lock {
+	return g.l
+}
+
+// Valid returns the validity remaining from now, it is zero or negative once
+// the validity of the lock has passed.
+func (g *Guard) Valid() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return time.Until(g.validUntil)
+}
+
+// Extend resets the ttl of the lock to ttl, returns the new validity.
+func (g *Guard) Extend(ttl time.Duration) (time.Duration, error) {
+	start := time.Now()
+	validity, err := g.l.ExtendTo(g.ctx, ttl)
+	if err != nil {
+		return 0, err
+	}
+	g.mu.Lock()
+	g.validUntil = start.Add(validity)
+	g.mu.Unlock()
+	return validity, nil
+}
+
+// Unlock releases the lock, calls after the first one are no-op, so a lock
+// of the same resource acquired again is never released by a stale Guard.
+// The lock is released even if the ctx of Guard is already done, such as a
+// deferred Unlock after the ctx is canceled, each instance is then given the
+// unlock timeout to reply.
+func (g *Guard) Unlock() error {
+	g.mu.Lock()
+	if g.released {
+		g.mu.Unlock()
+		return nil
+	}
+	g.released = true
+	g.mu.Unlock()
+	ctx := g.ctx
+	if ctx.Err() != nil {
+		ctx = context.Background()
+	}
+	return g.l.Unlock(ctx)
+}
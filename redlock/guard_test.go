@@ -0,0 +1,40 @@
+package redlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockGuard(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	lock := newMockRedLock(t, &mockCmdable{}, &mockCmdable{}, &mockCmdable{})
+
+	ttl := time.Second
+	g, err := lock.Guard(ctx, "foo", ttl)
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", g.Lock().Resource())
+	assert.True(t, lock.Owns("foo"))
+	valid := g.Valid()
+	assert.Greater(t, int64(valid), int64(0))
+	assert.Less(t, int64(valid), int64(ttl))
+
+	validity, err := g.Extend(2 * ttl)
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(ttl))
+	assert.Greater(t, int64(g.Valid()), int64(ttl))
+	assert.Equal(t, 2*ttl, g.Lock().TTL())
+
+	// released even if ctx is canceled
+	cancel()
+	assert.Nil(t, g.Unlock())
+	assert.False(t, lock.Owns("foo"))
+
+	// a stale guard doesn't release the lock acquired again
+	_, err = lock.Lock(context.Background(), "foo", ttl)
+	assert.Nil(t, err)
+	assert.Nil(t, g.Unlock())
+	assert.True(t, lock.Owns("foo"))
+}
@@ -0,0 +1,78 @@
+package redlock
+
+import "time"
+
+// LockToken carries what is needed to release or extend a lock elsewhere,
+// it is serializable by encoding/json or any codec of plain structs
+type LockToken struct {
+	Resource string        `json:"resource"`
+	Value    string        `json:"value"`
+	TTL      time.Duration `json:"ttl"`
+	// ExpiresAt is the time that the validity of lock ends on the sender
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Transfer hands the ownership of the lock over, it returns a token that the
+// receiver turns into a handle by LockFromToken, in another goroutine or
+// process. The lock is removed from the local cache, so the sending RedLock
+// no longer owns it, and l must not be used afterward. It returns
+// ErrLockNotHeld if the validity of lock already passed.
+//
+// The lock is not renewed during the handover, the receiver should extend it
+// if the remaining validity is short. Across processes ExpiresAt is compared
+// with the clock of the receiver, so their clock difference eats into the
+// validity, and the token must be kept secret since anyone holding it can
+// release the lock.
+func (l *Lock) Transfer() (LockToken, error) {
+	r := l.r
+	elem, err := r.cache.Get(l.resource)
+	if err != nil {
+		return LockToken{}, err
+	}
+	if elem == nil || elem.Val != l.val {
+		return LockToken{}, ErrLockNotHeld
+	}
+	token := LockToken{
+		Resource:  l.resource,
+		Value:     l.val,
+		TTL:       l.TTL(),
+		ExpiresAt: time.Now().Add(time.Duration(elem.remaining())),
+	}
+	r.cache.Delete(l.resource)
+	r.heldTTLs.delete(l.resource)
+	r.holdCounts.clear(l.resource)
+	return token, nil
+}
+
+// LockFromToken takes over the lock of a token returned by Transfer, the
+// returned handle and r can release or extend it as if r acquired it. It
+// returns ErrLockNotHeld if the validity of lock already passed, or
+// ErrLockHeld if r holds the resource with another value.
+func (r *RedLock) LockFromToken(token LockToken) (*Lock, error) {
+	if err := r.checkResource(token.Resource); err != nil {
+		return nil, err
+	}
+	validity := time.Until(token.ExpiresAt)
+	if validity <= 0 {
+		return nil, ErrLockNotHeld
+	}
+	elem, err := r.cache.Get(token.Resource)
+	if err != nil {
+		return nil, err
+	}
+	if elem != nil && elem.Val != token.Value {
+		return nil, ErrLockHeld
+	}
+	if _, err := r.cache.Set(token.Resource, token.Value, int64(validity)); err != nil {
+		return nil, err
+	}
+	r.heldTTLs.set(token.Resource, token.TTL)
+	return &Lock{
+		r:          r,
+		resource:   token.Resource,
+		val:        token.Value,
+		ttl:        token.TTL,
+		validity:   validity,
+		acquiredAt: time.Now(),
+	}, nil
+}
@@ -0,0 +1,72 @@
+package redlock
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockTransfer(t *testing.T) {
+	ctx := context.Background()
+	sender, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	defer sender.Close()
+	receiver, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	defer receiver.Close()
+
+	l, err := sender.Acquire(ctx, "handover", time.Second)
+	assert.Nil(t, err)
+	token, err := l.Transfer()
+	assert.Nil(t, err)
+	assert.False(t, sender.Owns("handover"))
+	assert.Equal(t, time.Second, token.TTL)
+	assert.True(t, token.ExpiresAt.After(time.Now()))
+	// the lock is transferred only once
+	_, err = l.Transfer()
+	assert.Equal(t, ErrLockNotHeld, err)
+
+	// the token survives serialization
+	data, err := json.Marshal(token)
+	assert.Nil(t, err)
+	var decoded LockToken
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+
+	done := make(chan error)
+	go func() {
+		h, err := receiver.LockFromToken(decoded)
+		if err != nil {
+			done <- err
+			return
+		}
+		assert.True(t, receiver.Owns("handover"))
+		assert.Equal(t, l.Value(), h.Value())
+		_, err = h.ExtendBy(ctx, time.Second)
+		if err != nil {
+			done <- err
+			return
+		}
+		done <- h.Unlock(ctx)
+	}()
+	assert.Nil(t, <-done)
+	assert.False(t, receiver.Owns("handover"))
+	for _, cli := range receiver.clients {
+		assert.Equal(t, redis.Nil, rawClient(cli).Get(ctx, "handover").Err())
+	}
+
+	// the receiver holds the resource with another value
+	_, err = receiver.Lock(ctx, "handover", time.Second)
+	assert.Nil(t, err)
+	_, err = receiver.LockFromToken(decoded)
+	assert.Equal(t, ErrLockHeld, err)
+	assert.Nil(t, receiver.UnLock(ctx, "handover"))
+
+	// an expired token
+	decoded.ExpiresAt = time.Now().Add(-time.Millisecond)
+	_, err = receiver.LockFromToken(decoded)
+	assert.Equal(t, ErrLockNotHeld, err)
+}
@@ -0,0 +1,52 @@
+package redlock
+
+import (
+	"context"
+	"time"
+)
+
+// WithHedgeDelay enables hedged requests during lock acquisition, if an
+// instance doesn't reply within delay, a second attempt is issued to it and
+// whichever succeeds first counts.
+func WithHedgeDelay(delay time.Duration) LockOption {
+	return func(r *RedLock) {
+		r.hedgeDelay = delay
+	}
+}
+
+// hedgeLock wraps lockFn, attempts to a slow instance are hedged after delay
+func hedgeLock(lockFn lockFunc, delay time.Duration) lockFunc {
+	if delay <= 0 {
+		return lockFn
+	}
+	return func(ctx context.Context, cli *RedClient) lockResult {
+		hctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		results := make(chan lockResult, 2)
+		launch := func() {
+			go func() {
+				results <- lockFn(hctx, cli)
+			}()
+		}
+		launch()
+		inflight := 1
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		hedge := timer.C
+		for {
+			select {
+			case res := <-results:
+				inflight--
+				// the other attempt may be still in flight, a failed attempt
+				// could be caused by the slow attempt that holds the lock.
+				if res.locked || inflight == 0 {
+					return res
+				}
+			case <-hedge:
+				hedge = nil
+				launch()
+				inflight++
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package redlock
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHedgeLock(t *testing.T) {
+	ctx := context.Background()
+	calls := int32(0)
+	slowFirst := func(ctx context.Context, cli *RedClient) lockResult {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return lockResult{err: ctx.Err()}
+			}
+		}
+		return lockResult{locked: true}
+	}
+
+	start := time.Now()
+	res := hedgeLock(slowFirst, 20*time.Millisecond)(ctx, nil)
+	assert.Equal(t, lockResult{locked: true}, res)
+	assert.Less(t, int64(time.Since(start)), int64(500*time.Millisecond))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// fast instance is not hedged
+	atomic.StoreInt32(&calls, 1)
+	res = hedgeLock(slowFirst, 20*time.Millisecond)(ctx, nil)
+	assert.Equal(t, lockResult{locked: true}, res)
+	time.Sleep(40 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// the hedged attempt failed, wait for the slow attempt
+	atomic.StoreInt32(&calls, 0)
+	slowSuccess := func(ctx context.Context, cli *RedClient) lockResult {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			time.Sleep(100 * time.Millisecond)
+			return lockResult{locked: true}
+		}
+		return lockResult{}
+	}
+	res = hedgeLock(slowSuccess, 20*time.Millisecond)(ctx, nil)
+	assert.Equal(t, lockResult{locked: true}, res)
+}
+
+func TestLockWithHedge(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithHedgeDelay(10*time.Millisecond))
+	assert.Nil(t, err)
+	_, err = lock.Lock(ctx, "foo", 200*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+}
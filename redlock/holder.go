@@ -0,0 +1,54 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// LockOrGetHolder acquires a distribute lock the same as Lock, if the lock is
+// held by others, it returns false along with the value of current holder
+// instead of an error. The holder is the value found on a quorum of instances
+// in the last attempt, or empty if no value reaches quorum. `SET NX GET` is
+// used to read the holder in the same round trip where available, older
+// servers read it by GET right after a failed SETNX.
+func (r *RedLock) LockOrGetHolder(
+	ctx context.Context, resource string, ttl time.Duration,
+) (acquired bool, validity time.Duration, holder string, err error) {
+	val, err := r.newValue(ctx, ttl)
+	if err != nil {
+		return false, 0, "", err
+	}
+	l, results, err := r.acquireWithResults(ctx, resource, ttl, val, r.retry(), func(ctx context.Context, cli *RedClient) lockResult {
+		res := r.lockInstanceWith(ctx, cli, resource, val, ttl, true)
+		if res.err == nil && !res.locked && res.holder == "" {
+			// the holder may expire in between, leave it empty then
+			if holder, err := cli.cli.Get(ctx, r.redisKey(resource)).Result(); err == nil {
+				res.holder = holder
+			}
+		}
+		return res
+	})
+	if errors.Is(err, ErrAcquireLock) {
+		return false, 0, quorumHolder(results, r.quorum), nil
+	}
+	if err != nil {
+		return false, 0, "", err
+	}
+	return true, l.validity, "", nil
+}
+
+// quorumHolder returns the holder reported by at least quorum instances
+func quorumHolder(results []lockResult, quorum int) string {
+	counts := make(map[string]int, len(results))
+	for _, res := range results {
+		if res.holder == "" {
+			continue
+		}
+		counts[res.holder]++
+		if counts[res.holder] >= quorum {
+			return res.holder
+		}
+	}
+	return ""
+}
@@ -0,0 +1,73 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockOrGetHolder(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	lock.SetRetryCount(1)
+
+	acquired, validity, holder, err := lock.LockOrGetHolder(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.True(t, acquired)
+	assert.Greater(t, int64(validity), int64(0))
+	assert.Empty(t, holder)
+	elem, err := lock.cache.Get("foo")
+	assert.Nil(t, err)
+
+	lock2, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	lock2.SetRetryCount(1)
+	acquired, validity, holder, err = lock2.LockOrGetHolder(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.False(t, acquired)
+	assert.Zero(t, validity)
+	assert.Equal(t, elem.Val, holder)
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+}
+
+func TestQuorumHolder(t *testing.T) {
+	testCases := []struct {
+		results []lockResult
+		holder  string
+	}{
+		{[]lockResult{{holder: "a"}, {holder: "a"}, {holder: "b"}}, "a"},
+		{[]lockResult{{holder: "a"}, {locked: true}, {holder: "b"}}, ""},
+		{[]lockResult{{}, {holder: "b"}, {holder: "b"}}, "b"},
+		{[]lockResult{{}, {}, {}}, ""},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.holder, quorumHolder(tc.results, 2), "%+v", tc)
+	}
+}
+
+func TestMockLockOrGetHolderFallback(t *testing.T) {
+	ctx := context.Background()
+	// the mocks reject SET NX GET and report the lock held, the holder is
+	// read by GET which returns no value
+	held := &mockCmdable{
+		setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+			return false, nil
+		},
+		do: func(context.Context, ...interface{}) (interface{}, error) {
+			return nil, errors.New("ERR syntax error")
+		},
+	}
+	lock := newMockRedLock(t, held, held, held)
+	lock.SetRetryCount(1)
+	acquired, _, holder, err := lock.LockOrGetHolder(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.False(t, acquired)
+	assert.Empty(t, holder)
+	for _, cli := range lock.clients {
+		assert.Equal(t, int32(1), cli.noSetNXGet)
+	}
+}
@@ -0,0 +1,57 @@
+package redlock
+
+import (
+	"context"
+	"time"
+)
+
+// AuditHook receives an event for every successful lock acquire and release,
+// carrying the resource and the lock value. The callbacks are invoked
+// synchronously on the caller's goroutine, so they should be fast.
+type AuditHook interface {
+	// OnAcquired is called after a lock is acquired with its validity
+	OnAcquired(resource, val string, validity time.Duration)
+
+	// OnReleased is called after a lock is released
+	OnReleased(resource, val string)
+}
+
+// ContextAuditHook is the same as AuditHook, except that the callbacks also
+// receive the ctx of the call that acquired or released the lock, so values
+// such as the trace id or tenant can be correlated. The callbacks must not
+// retain ctx after they return, it may be canceled right after the call.
+type ContextAuditHook interface {
+	// OnAcquired is called after a lock is acquired with its validity
+	OnAcquired(ctx context.Context, resource, val string, validity time.Duration)
+
+	// OnReleased is called after a lock is released
+	OnReleased(ctx context.Context, resource, val string)
+}
+
+// WithAuditHook sets the AuditHook of RedLock
+func WithAuditHook(hook AuditHook) LockOption {
+	return func(r *RedLock) {
+		r.auditHook = auditHookAdapter{hook}
+	}
+}
+
+// WithContextAuditHook sets the ContextAuditHook of RedLock, it replaces the
+// hook set by WithAuditHook
+func WithContextAuditHook(hook ContextAuditHook) LockOption {
+	return func(r *RedLock) {
+		r.auditHook = hook
+	}
+}
+
+// auditHookAdapter adapts AuditHook to ContextAuditHook
+type auditHookAdapter struct {
+	hook AuditHook
+}
+
+func (a auditHookAdapter) OnAcquired(ctx context.Context, resource, val string, validity time.Duration) {
+	a.hook.OnAcquired(resource, val, validity)
+}
+
+func (a auditHookAdapter) OnReleased(ctx context.Context, resource, val string) {
+	a.hook.OnReleased(resource, val)
+}
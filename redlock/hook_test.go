@@ -0,0 +1,89 @@
+package redlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type auditRecord struct {
+	event    string
+	resource string
+	val      string
+	validity time.Duration
+}
+
+type recordAuditHook struct {
+	records []auditRecord
+}
+
+func (h *recordAuditHook) OnAcquired(resource, val string, validity time.Duration) {
+	h.records = append(h.records, auditRecord{"acquired", resource, val, validity})
+}
+
+func (h *recordAuditHook) OnReleased(resource, val string) {
+	h.records = append(h.records, auditRecord{"released", resource, val, 0})
+}
+
+func TestAuditHook(t *testing.T) {
+	ctx := context.Background()
+	hook := &recordAuditHook{}
+	lock, err := NewRedLock(ctx, redisServers, WithAuditHook(hook))
+	assert.Nil(t, err)
+
+	validity, err := lock.Lock(ctx, "foo", 200*time.Millisecond)
+	assert.Nil(t, err)
+	elem, err := lock.cache.Get("foo")
+	assert.Nil(t, err)
+	err = lock.UnLock(ctx, "foo")
+	assert.Nil(t, err)
+	// release a lock not held doesn't trigger hook
+	err = lock.UnLock(ctx, "foo")
+	assert.Nil(t, err)
+
+	assert.Equal(t, []auditRecord{
+		{"acquired", "foo", elem.Val, validity},
+		{"released", "foo", elem.Val, 0},
+	}, hook.records)
+}
+
+type traceKey struct{}
+
+type recordContextAuditHook struct {
+	records []auditRecord
+}
+
+func (h *recordContextAuditHook) OnAcquired(ctx context.Context, resource, val string, validity time.Duration) {
+	h.records = append(h.records, auditRecord{"acquired:" + ctx.Value(traceKey{}).(string), resource, val, validity})
+}
+
+func (h *recordContextAuditHook) OnReleased(ctx context.Context, resource, val string) {
+	h.records = append(h.records, auditRecord{"released:" + ctx.Value(traceKey{}).(string), resource, val, 0})
+}
+
+func TestContextAuditHook(t *testing.T) {
+	ctx := context.WithValue(context.Background(), traceKey{}, "trace-1")
+	hook := &recordContextAuditHook{}
+	lock, err := NewRedLockFromStores(ctx, []LockStore{newMemStore("a")}, WithContextAuditHook(hook))
+	assert.Nil(t, err)
+
+	validity, err := lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	elem, err := lock.cache.Get("foo")
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnLock(context.WithValue(context.Background(), traceKey{}, "trace-2"), "foo"))
+
+	_, err = lock.Lock(ctx, "bar", time.Second)
+	assert.Nil(t, err)
+	bar, err := lock.cache.Get("bar")
+	assert.Nil(t, err)
+	assert.Nil(t, lock.ReleaseAll(context.WithValue(context.Background(), traceKey{}, "trace-3"), "bar"))
+
+	assert.Len(t, hook.records, 4)
+	assert.Equal(t, auditRecord{"acquired:trace-1", "foo", elem.Val, validity}, hook.records[0])
+	assert.Equal(t, auditRecord{"released:trace-2", "foo", elem.Val, 0}, hook.records[1])
+	assert.Equal(t, "acquired:trace-1", hook.records[2].event)
+	assert.Equal(t, auditRecord{"released:trace-3", "bar", bar.Val, 0}, hook.records[3])
+}
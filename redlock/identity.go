@@ -0,0 +1,76 @@
+package redlock
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// identitySep separates random token and identity in lock value
+const identitySep = "@"
+
+// DefaultIdentity returns the identity of the current process, which is
+// `<hostname>/<pid>`
+func DefaultIdentity() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s/%d", host, os.Getpid())
+}
+
+// WithIdentity embeds the identity of caller into every lock value, the value
+// becomes `<random token>@<identity>`, after the owner embedded by
+// WithOwnerProvider if any. So the holder of a stuck lock can be told from
+// the values returned by Inspect or ScanLocks via IdentityOf, or from the
+// local cache via LockElem.Meta. An empty id uses DefaultIdentity, an id
+// must not contain `@`, `:` or `|`, which separate the parts of lock value.
+//
+// The identity is informational only, it is not verified and anyone can
+// claim any identity, so it must not be relied on as a security boundary.
+func WithIdentity(id string) LockOption {
+	return func(r *RedLock) {
+		if id == "" {
+			id = DefaultIdentity()
+		}
+		if strings.ContainsAny(id, identitySep+ownerSep+prioritySep) {
+			r.setOptErr(fmt.Errorf("invalid identity %q, must not contain any of %q", id, identitySep+ownerSep+prioritySep))
+			return
+		}
+		r.identity = id
+	}
+}
+
+// splitIdentity splits a lock value into the value before identity and the
+// identity, the identity is empty if the value has none
+func splitIdentity(val string) (string, string) {
+	idx := strings.LastIndex(val, identitySep)
+	if idx < 0 {
+		return val, ""
+	}
+	id := val[idx+1:]
+	if p := strings.Index(id, prioritySep); p >= 0 {
+		id = id[:p]
+	}
+	// the separator is in the owner if followed by an owner separator
+	if strings.Contains(id, ownerSep) {
+		return val, ""
+	}
+	return val[:idx], id
+}
+
+// IdentityOf returns the identity embedded in a lock value by WithIdentity,
+// or empty string if the value has no identity.
+func IdentityOf(val string) string {
+	_, id := splitIdentity(val)
+	return id
+}
+
+// Identity returns the identity of holder, which is kept in Meta, or parsed
+// from the lock value for the elements cached without Meta
+func (e *LockElem) Identity() string {
+	if e.Meta != "" {
+		return e.Meta
+	}
+	return IdentityOf(e.Val)
+}
@@ -0,0 +1,84 @@
+package redlock
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentity(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithHashTag("identity"), WithIdentity("worker-1"))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	defer lock.UnLock(ctx, "foo") // nolint:errcheck
+	elem, err := lock.cache.Get("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, "worker-1", elem.Meta)
+	assert.Equal(t, "worker-1", elem.Identity())
+	assert.Equal(t, "", elem.Owner())
+
+	values, err := lock.Inspect(ctx, "foo")
+	assert.Nil(t, err)
+	assert.Len(t, values, len(redisServers))
+	for _, val := range values {
+		assert.Equal(t, "worker-1", IdentityOf(val))
+	}
+	locks, err := lock.ScanLocks(ctx)
+	assert.Nil(t, err)
+	for _, held := range locks {
+		assert.Equal(t, "worker-1", IdentityOf(held["foo"]))
+	}
+
+	// the identity comes after the owner and before the priority
+	lock, err = NewRedLock(ctx, redisServers, WithIdentity(""), WithOwnerProvider(func(context.Context) string {
+		return "tenant-a"
+	}))
+	assert.Nil(t, err)
+	defer lock.Close()
+	_, err = lock.LockWithPriority(ctx, "bar", time.Second, 3)
+	assert.Nil(t, err)
+	defer lock.UnLock(ctx, "bar") // nolint:errcheck
+	elem, err = lock.cache.Get("bar")
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultIdentity(), elem.Identity())
+	assert.Equal(t, "tenant-a", elem.Owner())
+	priority, ok := PriorityOf(elem.Val)
+	assert.True(t, ok)
+	assert.Equal(t, 3, priority)
+
+	for _, id := range []string{"a@b", "a:b", "a|b"} {
+		_, err = NewRedLock(ctx, redisServers, WithIdentity(id))
+		assert.NotNil(t, err, id)
+	}
+}
+
+func TestIdentityOf(t *testing.T) {
+	token := getRandStr()
+	testCases := []struct {
+		val   string
+		owner string
+		id    string
+	}{
+		{token, "", ""},
+		{token + "@host/1", "", "host/1"},
+		{"a:" + token + "@host/1", "a", "host/1"},
+		{"a@b:" + token, "a@b", ""},
+		{"a@b:" + token + "@host/1", "a@b", "host/1"},
+		{token + "@host/1|5", "", "host/1"},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.owner, OwnerOf(tc.val), tc.val)
+		assert.Equal(t, tc.id, IdentityOf(tc.val), tc.val)
+	}
+
+	assert.True(t, strings.HasSuffix(DefaultIdentity(), fmt.Sprintf("/%d", os.Getpid())))
+}
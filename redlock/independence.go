@@ -0,0 +1,137 @@
+package redlock
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Warning reports instances that may share a failure domain
+type Warning struct {
+	// Addrs are the addresses of the instances involved
+	Addrs  []string
+	Reason string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%s: %s", strings.Join(w.Addrs, ", "), w.Reason)
+}
+
+// instanceInfo is the identity of an instance parsed from INFO
+type instanceInfo struct {
+	runID string
+	// replID is the replication id, which is shared by a master and its
+	// replicas
+	replID string
+}
+
+// parseInfo parses the `key:value` lines of INFO reply
+func parseInfo(info string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if idx := strings.Index(line, ":"); idx > 0 {
+			fields[line[:idx]] = line[idx+1:]
+		}
+	}
+	return fields
+}
+
+// hostOf returns the host of an instance address, which may be an url
+func hostOf(addr string) string {
+	if idx := strings.Index(addr, "://"); idx >= 0 {
+		addr = addr[idx+3:]
+	}
+	if idx := strings.LastIndex(addr, "@"); idx >= 0 {
+		addr = addr[idx+1:]
+	}
+	if idx := strings.Index(addr, "/"); idx >= 0 {
+		addr = addr[:idx]
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+// CheckIndependence is a best-effort audit of the assumption that instances
+// fail independently. It returns warnings for instances on the same host,
+// instances that are the same server by run_id, and instances in a
+// replication relationship by the replication id. The first error of INFO
+// is returned along with the warnings of the other instances.
+func (r *RedLock) CheckIndependence(ctx context.Context) ([]Warning, error) {
+	var (
+		wg       sync.WaitGroup
+		infos    = make([]*instanceInfo, len(r.clients))
+		mu       sync.Mutex
+		firstErr error
+	)
+	for idx, cli := range r.clients {
+		idx, cli := idx, cli
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			server, err := cli.cli.Do(ctx, "info", "server").Text()
+			if err == nil {
+				var repl string
+				repl, err = cli.cli.Do(ctx, "info", "replication").Text()
+				if err == nil {
+					infos[idx] = &instanceInfo{
+						runID:  parseInfo(server)["run_id"],
+						replID: parseInfo(repl)["master_replid"],
+					}
+					return
+				}
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", cli.addr, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var warnings []Warning
+	group := func(key func(idx int) string, reason string) {
+		addrs := make(map[string][]string)
+		var keys []string
+		for idx, cli := range r.clients {
+			k := key(idx)
+			if k == "" {
+				continue
+			}
+			if _, ok := addrs[k]; !ok {
+				keys = append(keys, k)
+			}
+			addrs[k] = append(addrs[k], cli.addr)
+		}
+		for _, k := range keys {
+			if len(addrs[k]) > 1 {
+				warnings = append(warnings, Warning{Addrs: addrs[k], Reason: fmt.Sprintf(reason, k)})
+			}
+		}
+	}
+	group(func(idx int) string {
+		return hostOf(r.clients[idx].addr)
+	}, "instances on the same host %s")
+	group(func(idx int) string {
+		if infos[idx] == nil {
+			return ""
+		}
+		return infos[idx].runID
+	}, "instances are the same server with run_id %s")
+	group(func(idx int) string {
+		if infos[idx] == nil {
+			return ""
+		}
+		return infos[idx].replID
+	}, "instances replicate each other with replication id %s")
+	return warnings, firstErr
+}
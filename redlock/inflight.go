@@ -0,0 +1,63 @@
+package redlock
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// inflightResources counts the in-flight acquisitions of each resource
+type inflightResources struct {
+	sync.Mutex
+	counts map[string]int
+}
+
+// WithResourceInFlight enables per-resource tracking of in-flight
+// acquisitions, which is reported by InFlightResources. It is disabled by
+// default because every acquisition needs to update a shared map.
+func WithResourceInFlight() LockOption {
+	return func(r *RedLock) {
+		r.inflightRes = &inflightResources{counts: make(map[string]int)}
+	}
+}
+
+// InFlight returns the count of acquisitions that are waiting or retrying
+func (r *RedLock) InFlight() int {
+	return int(atomic.LoadInt64(&r.inflight))
+}
+
+// InFlightResources returns the count of in-flight acquisitions of each
+// resource, it returns nil if per-resource tracking is not enabled.
+func (r *RedLock) InFlightResources() map[string]int {
+	if r.inflightRes == nil {
+		return nil
+	}
+	r.inflightRes.Lock()
+	defer r.inflightRes.Unlock()
+	counts := make(map[string]int, len(r.inflightRes.counts))
+	for resource, count := range r.inflightRes.counts {
+		counts[resource] = count
+	}
+	return counts
+}
+
+// enterInFlight marks an acquisition on resource begins, the returned
+// function must be called when the acquisition ends
+func (r *RedLock) enterInFlight(resource string) func() {
+	atomic.AddInt64(&r.inflight, 1)
+	if r.inflightRes != nil {
+		r.inflightRes.Lock()
+		r.inflightRes.counts[resource]++
+		r.inflightRes.Unlock()
+	}
+	return func() {
+		atomic.AddInt64(&r.inflight, -1)
+		if r.inflightRes != nil {
+			r.inflightRes.Lock()
+			r.inflightRes.counts[resource]--
+			if r.inflightRes.counts[resource] == 0 {
+				delete(r.inflightRes.counts, resource)
+			}
+			r.inflightRes.Unlock()
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package redlock
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInFlight(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	assert.Zero(t, lock.InFlight())
+	assert.Nil(t, lock.InFlightResources())
+
+	blocked := make(chan struct{})
+	block := &mockCmdable{
+		setNX: func(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+			<-blocked
+			return true, nil
+		},
+	}
+	lock = newMockRedLock(t, block, block, block)
+	WithResourceInFlight()(lock)
+	var wg sync.WaitGroup
+	for _, resource := range []string{"foo", "foo", "bar"} {
+		wg.Add(1)
+		go func(resource string) {
+			defer wg.Done()
+			_, err := lock.Lock(ctx, resource, time.Second)
+			assert.Nil(t, err)
+		}(resource)
+	}
+	assert.Eventually(t, func() bool {
+		return lock.InFlight() == 3
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, map[string]int{"foo": 2, "bar": 1}, lock.InFlightResources())
+
+	close(blocked)
+	wg.Wait()
+	assert.Zero(t, lock.InFlight())
+	assert.Equal(t, map[string]int{}, lock.InFlightResources())
+}
@@ -0,0 +1,83 @@
+package redlock
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// InformedLockScript is redis lua script to set a lock with NX and PX,
+	// it returns {1} if set, or {0, pttl, value} of the holder otherwise.
+	InformedLockScript = `
+        if redis.call("set", KEYS[1], ARGV[1], "px", ARGV[2], "nx") then
+            return {1}
+        end
+        return {0, redis.call("pttl", KEYS[1]), redis.call("get", KEYS[1])}
+        `
+)
+
+var informedLockScript = newLuaScript(InformedLockScript)
+
+// WithInformedBackoff makes a failed acquisition attempt read the remaining
+// ttl of the holder on each instance, in the same round trip by a lua
+// script, and the next attempt waits no longer than until the lock is
+// expected to expire on quorum, if it's shorter than the delay of the retry
+// strategy. So a waiter on a lock about to expire retries right after it
+// expires instead of after a blind delay. The holder value is reported as
+// WithSetNXGet does. The wait is not shortened if the ttl is unknown on too
+// many instances, such as an instance fails or holds the lock without ttl.
+func WithInformedBackoff() LockOption {
+	return func(r *RedLock) {
+		r.informedBackoff = true
+	}
+}
+
+// informedLockInstance sets the lock on client by InformedLockScript
+func informedLockInstance(ctx context.Context, client *RedClient, key, val string, ttl time.Duration) lockResult {
+	v, err := informedLockScript.run(ctx, client.cli, []string{key}, val, formatMs(ttl)).Result()
+	if err != nil {
+		return lockResult{err: err}
+	}
+	reply, _ := v.([]interface{})
+	if len(reply) == 0 {
+		return lockResult{err: fmt.Errorf("unexpected informed lock reply %v", reply)}
+	}
+	if set, _ := reply[0].(int64); set == 1 {
+		return lockResult{locked: true}
+	}
+	var res lockResult
+	if len(reply) > 1 {
+		if pttl, _ := reply[1].(int64); pttl > 0 {
+			res.pttl = time.Duration(pttl) * time.Millisecond
+		}
+	}
+	if len(reply) > 2 {
+		res.holder, _ = reply[2].(string)
+	}
+	return res
+}
+
+// informedWait returns how long until the lock is expected to be free on
+// quorum after a failed attempt, the instances locked by the attempt are
+// released and free right away. It returns false if the remaining ttl is
+// unknown on too many instances.
+func informedWait(results []lockResult, quorum int) (time.Duration, bool) {
+	waits := make([]time.Duration, 0, len(results))
+	for _, res := range results {
+		switch {
+		case res.locked:
+			waits = append(waits, 0)
+		case res.err == nil && res.pttl > 0:
+			waits = append(waits, res.pttl)
+		}
+	}
+	if len(waits) < quorum {
+		return 0, false
+	}
+	sort.Slice(waits, func(i, j int) bool { return waits[i] < waits[j] })
+	// the pttl is truncated to milliseconds, wait a bit more so the lock is
+	// surely expired
+	return waits[quorum-1] + time.Millisecond, true
+}
@@ -0,0 +1,65 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInformedBackoff(t *testing.T) {
+	ctx := context.Background()
+	other, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	defer other.Close()
+	lock, err := NewRedLock(ctx, redisServers, WithInformedBackoff(), WithRetryStrategy(FixedRetry(3, 2*time.Second)))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	_, err = other.Lock(ctx, "informed-foo", 300*time.Millisecond)
+	assert.Nil(t, err)
+	holder, err := other.cache.Get("informed-foo")
+	assert.Nil(t, err)
+	res := lock.lockInstance(ctx, lock.clients[0], "informed-foo", getRandStr(), time.Second)
+	assert.Nil(t, res.err)
+	assert.False(t, res.locked)
+	assert.Equal(t, holder.Val, res.holder)
+	assert.Greater(t, int64(res.pttl), int64(0))
+	assert.LessOrEqual(t, int64(res.pttl), int64(300*time.Millisecond))
+
+	// the retry waits until the lock of holder expires rather than the delay
+	// of the retry strategy
+	start := time.Now()
+	_, err = lock.Lock(ctx, "informed-foo", time.Second)
+	assert.Nil(t, err)
+	elapsed := time.Since(start)
+	assert.Greater(t, int64(elapsed), int64(200*time.Millisecond))
+	assert.Less(t, int64(elapsed), int64(time.Second))
+	assert.Nil(t, lock.UnLock(ctx, "informed-foo"))
+}
+
+func TestInformedWait(t *testing.T) {
+	held := func(pttl time.Duration) lockResult {
+		return lockResult{pttl: pttl}
+	}
+	failed := lockResult{err: errors.New("injected error")}
+	testCases := []struct {
+		results []lockResult
+		wait    time.Duration
+		known   bool
+	}{
+		{[]lockResult{held(time.Second), held(3 * time.Second), held(2 * time.Second)}, 2*time.Second + time.Millisecond, true},
+		{[]lockResult{{locked: true}, held(3 * time.Second), held(2 * time.Second)}, 2*time.Second + time.Millisecond, true},
+		{[]lockResult{{locked: true}, {locked: true}, held(2 * time.Second)}, time.Millisecond, true},
+		{[]lockResult{failed, held(3 * time.Second), held(2 * time.Second)}, 3*time.Second + time.Millisecond, true},
+		// the lock is held without ttl or the instance failed
+		{[]lockResult{failed, held(0), held(2 * time.Second)}, 0, false},
+	}
+	for idx, tc := range testCases {
+		wait, known := informedWait(tc.results, 2)
+		assert.Equal(t, tc.known, known, "case %d", idx)
+		assert.Equal(t, tc.wait, wait, "case %d", idx)
+	}
+}
@@ -2,8 +2,10 @@ package redlock
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"log"
 	"math"
+	"runtime"
 	"sync"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 const (
 	CacheTypeSimple    = "simple"
 	CacheTypeFreeCache = "freecache"
+	CacheTypeNone      = "none"
 )
 
 // CacheOptions defines optional parameters for configuring kv cache.
@@ -21,14 +24,23 @@ type CacheOptions struct {
 	CacheType  string
 	DisableGC  bool
 	GCInterval time.Duration
-	CacheSize  int
+	// GCBatchSize is the number of elements SimpleCache scans before
+	// yielding its lock during gc
+	GCBatchSize int
+	CacheSize   int
+	Codec       Codec
+	// Capacity is the max number of elements of SimpleCache, 0 means
+	// unbounded
+	Capacity int
 }
 
 var defaultCacheOptions = &CacheOptions{
-	CacheType:  CacheTypeSimple,
-	DisableGC:  false,
-	GCInterval: time.Minute,
-	CacheSize:  10 * 1024 * 1024,
+	CacheType:   CacheTypeSimple,
+	DisableGC:   false,
+	GCInterval:  time.Minute,
+	GCBatchSize: 1000,
+	CacheSize:   10 * 1024 * 1024,
+	Codec:       JSONCodec{},
 }
 
 // CacheOption alias to the function that can be used to configure CacheOptions
@@ -55,6 +67,13 @@ func WithGCInterval(interval time.Duration) CacheOption {
 	}
 }
 
+// WithGCBatchSize sets GCBatchSize of CacheOptions
+func WithGCBatchSize(size int) CacheOption {
+	return func(o *CacheOptions) {
+		o.GCBatchSize = size
+	}
+}
+
 // WithCacheSize sets CacheSize of CacheOptions
 func WithCacheSize(size int) CacheOption {
 	return func(o *CacheOptions) {
@@ -62,15 +81,67 @@ func WithCacheSize(size int) CacheOption {
 	}
 }
 
+// WithSimpleCacheCap sets Capacity of CacheOptions, SimpleCache evicts the
+// element nearest to expiry when a new key is set beyond capacity, since its
+// lock is about to be lost anyway. Evicting a lock only forgets it locally,
+// the lock is still held on redis until it expires.
+func WithSimpleCacheCap(n int) CacheOption {
+	return func(o *CacheOptions) {
+		o.Capacity = n
+	}
+}
+
+// WithCacheCodec sets Codec of CacheOptions
+func WithCacheCodec(codec Codec) CacheOption {
+	return func(o *CacheOptions) {
+		o.Codec = codec
+	}
+}
+
+// processStart is used as the base of monotonic timestamps
+var processStart = time.Now()
+
+// monoNow returns a monotonic timestamp in nanoseconds, it is meaningful only
+// in current process, but is immune to wall clock changes.
+func monoNow() int64 {
+	return int64(time.Since(processStart))
+}
+
 // LockElem keeps a lock element
 type LockElem struct {
-	Val    string    `json:"val"`
-	Expiry int64     `json:"expiry"`
-	Ts     time.Time `json:"ts"`
+	Val    string    `json:"val" msgpack:"val"`
+	Expiry int64     `json:"expiry" msgpack:"expiry"`
+	Ts     time.Time `json:"ts" msgpack:"ts"`
+	// Mono is the monotonic timestamp when the element is set, unlike Ts it
+	// survives serialization without losing the monotonic clock reading.
+	Mono int64 `json:"mono,omitempty" msgpack:"mono,omitempty"`
+	// Meta is the identity of holder embedded in Val by WithIdentity
+	Meta string `json:"meta,omitempty" msgpack:"meta,omitempty"`
+}
+
+func newLockElem(val string, expiry int64) *LockElem {
+	return &LockElem{
+		Val:    val,
+		Expiry: expiry,
+		Ts:     time.Now(),
+		Mono:   monoNow(),
+		Meta:   IdentityOf(val),
+	}
+}
+
+// remaining returns the remaining validity in nanoseconds, it is computed
+// with the monotonic timestamp if available, falls back to wall clock, which
+// may be affected by clock steps, otherwise.
+func (e *LockElem) remaining() int64 {
+	if e.Mono > 0 {
+		return e.Expiry - (monoNow() - e.Mono)
+	}
+	return e.Expiry - time.Since(e.Ts).Nanoseconds()
 }
 
+// expire checks whether the element has no remaining validity
 func (e *LockElem) expire() bool {
-	return time.Since(e.Ts).Nanoseconds() > e.Expiry
+	return e.remaining() < 0
 }
 
 // KVCache defines interface for redlock key value storage
@@ -88,16 +159,49 @@ type KVCache interface {
 	Size() int
 }
 
-// NewCacheImpl returns a KVCache implementation based on given cache type
-func NewCacheImpl(ctx context.Context, opts ...CacheOption) KVCache {
+// Validate checks the options, it rejects a non-positive GCInterval if gc of
+// the simple cache is enabled, a negative Capacity, and a non-positive
+// CacheSize of FreeCache.
+func (o *CacheOptions) Validate() error {
+	if o.Capacity < 0 {
+		return fmt.Errorf("invalid cache capacity %d, must not be negative", o.Capacity)
+	}
+	switch o.CacheType {
+	case CacheTypeFreeCache:
+		if o.CacheSize <= 0 {
+			return fmt.Errorf("invalid freecache size %d, must be positive", o.CacheSize)
+		}
+	case CacheTypeNone:
+	default:
+		if !o.DisableGC && o.GCInterval <= 0 {
+			return fmt.Errorf("invalid cache gc interval %s, must be positive if gc is enabled", o.GCInterval)
+		}
+	}
+	return nil
+}
+
+// newCacheOptions returns the default cache options modified by opts
+func newCacheOptions(opts ...CacheOption) *CacheOptions {
 	options := new(CacheOptions)
 	*options = *defaultCacheOptions
 	for _, opt := range opts {
 		opt(options)
 	}
+	return options
+}
+
+// NewCacheImpl returns a KVCache implementation based on given cache type,
+// invalid options are replaced by defaults, see CacheOptions.Validate
+func NewCacheImpl(ctx context.Context, opts ...CacheOption) KVCache {
+	return newCache(ctx, newCacheOptions(opts...))
+}
+
+func newCache(ctx context.Context, options *CacheOptions) KVCache {
 	switch options.CacheType {
 	case CacheTypeFreeCache:
 		return NewFreeCache(options)
+	case CacheTypeNone:
+		return NoCache{}
 	case CacheTypeSimple:
 		fallthrough
 	default:
@@ -107,18 +211,39 @@ func NewCacheImpl(ctx context.Context, opts ...CacheOption) KVCache {
 
 // SimpleCache is the most native implementation of KVCache interface
 type SimpleCache struct {
-	kvs  map[string]*LockElem
-	lock sync.RWMutex
+	kvs     map[string]*LockElem
+	lock    sync.RWMutex
+	gcBatch int
+
+	// capacity bounds the elements if positive, the expiry heap and its
+	// items are maintained only then
+	capacity int
+	expiries expiryHeap
+	items    map[string]*expiryItem
 }
 
-// NewSimpleCache creates a new SimpleCache object
+// NewSimpleCache creates a new SimpleCache object, a non-positive GCInterval
+// with gc enabled is replaced by the default with a warning
 func NewSimpleCache(ctx context.Context, options *CacheOptions) *SimpleCache {
 	c := &SimpleCache{
-		kvs: make(map[string]*LockElem),
+		kvs:      make(map[string]*LockElem),
+		gcBatch:  options.GCBatchSize,
+		capacity: options.Capacity,
+	}
+	if c.gcBatch <= 0 {
+		c.gcBatch = defaultCacheOptions.GCBatchSize
+	}
+	if c.capacity > 0 {
+		c.items = make(map[string]*expiryItem)
 	}
 	if !options.DisableGC {
+		interval := options.GCInterval
+		if interval <= 0 {
+			log.Printf("redlock: invalid cache gc interval %s, default %s is used", interval, defaultCacheOptions.GCInterval)
+			interval = defaultCacheOptions.GCInterval
+		}
 		go func() {
-			ticker := time.NewTicker(options.GCInterval)
+			ticker := time.NewTicker(interval)
 			defer ticker.Stop()
 			for {
 				select {
@@ -133,14 +258,17 @@ func NewSimpleCache(ctx context.Context, options *CacheOptions) *SimpleCache {
 	return c
 }
 
-// Set implements KVCache.Set
+// Set implements KVCache.Set, setting a new key beyond capacity evicts the
+// element nearest to expiry in O(log n)
 func (sc *SimpleCache) Set(key, val string, expiry int64) (*LockElem, error) {
 	sc.lock.Lock()
 	defer sc.lock.Unlock()
-	elem := &LockElem{
-		Val:    val,
-		Expiry: expiry,
-		Ts:     time.Now(),
+	elem := newLockElem(val, expiry)
+	if sc.capacity > 0 {
+		if _, ok := sc.kvs[key]; !ok && len(sc.kvs) >= sc.capacity {
+			sc.evict()
+		}
+		sc.track(key, elem)
 	}
 	sc.kvs[key] = elem
 	return elem, nil
@@ -161,7 +289,15 @@ func (sc *SimpleCache) Get(key string) (*LockElem, error) {
 func (sc *SimpleCache) Delete(key string) {
 	sc.lock.Lock()
 	defer sc.lock.Unlock()
+	sc.remove(key)
+}
+
+// remove deletes key from the map and the expiry heap
+func (sc *SimpleCache) remove(key string) {
 	delete(sc.kvs, key)
+	if sc.capacity > 0 {
+		sc.untrack(key)
+	}
 }
 
 // Size implements KVCache.Size
@@ -171,41 +307,69 @@ func (sc *SimpleCache) Size() int {
 	return len(sc.kvs)
 }
 
+// gc removes expired elements. The expired keys are collected under the read
+// lock, which doesn't block Get, then removed in batches of gcBatch under the
+// write lock, so a large cache doesn't stall Set and Delete for a whole pass.
+// Each key is checked again before removal, since it may be set again in
+// between.
 func (sc *SimpleCache) gc() {
-	sc.lock.Lock()
-	defer sc.lock.Unlock()
+	sc.lock.RLock()
+	expired := make([]string, 0)
 	for key, elem := range sc.kvs {
 		if elem.expire() {
-			delete(sc.kvs, key)
+			expired = append(expired, key)
+		}
+	}
+	sc.lock.RUnlock()
+
+	for start := 0; start < len(expired); start += sc.gcBatch {
+		end := start + sc.gcBatch
+		if end > len(expired) {
+			end = len(expired)
 		}
+		sc.lock.Lock()
+		for _, key := range expired[start:end] {
+			if elem, ok := sc.kvs[key]; ok && elem.expire() {
+				sc.remove(key)
+			}
+		}
+		sc.lock.Unlock()
+		runtime.Gosched()
 	}
 }
 
-// FreeCache is a wrapper of freecache.Cache
+// FreeCache is a wrapper of freecache.Cache. The ttl of freecache is in
+// second resolution, so the expiry is rounded up to seconds for freecache to
+// evict elements, and the precise expiry in nanoseconds is checked by Get.
+// Note the monotonic clock reading of LockElem.Ts is stripped by
+// serialization, LockElem.Mono keeps a monotonic timestamp instead.
 type FreeCache struct {
-	c *freecache.Cache
+	c     *freecache.Cache
+	codec Codec
 }
 
 // NewFreeCache returns a new FreeCache instance
 func NewFreeCache(options *CacheOptions) *FreeCache {
+	codec := options.Codec
+	if codec == nil {
+		codec = defaultCacheOptions.Codec
+	}
 	return &FreeCache{
-		c: freecache.NewCache(options.CacheSize),
+		c:     freecache.NewCache(options.CacheSize),
+		codec: codec,
 	}
 }
 
 // Set implements KVCache.Set
 func (fc *FreeCache) Set(key, val string, expiry int64) (*LockElem, error) {
-	elem := &LockElem{
-		Val:    val,
-		Expiry: expiry,
-		Ts:     time.Now(),
-	}
-	buf, err := json.Marshal(elem)
+	elem := newLockElem(val, expiry)
+	buf, err := fc.codec.Marshal(elem)
 	if err != nil {
 		return nil, err
 	}
-	// freecache only supports second resolution
-	err = fc.c.Set([]byte(key), buf, int(math.Ceil(float64(expiry)/1000)))
+	// freecache only supports second resolution, round up and check the
+	// precise expiry in Get
+	err = fc.c.Set([]byte(key), buf, int(math.Ceil(float64(expiry)/float64(time.Second))))
 	if err != nil {
 		return nil, err
 	}
@@ -222,10 +386,13 @@ func (fc *FreeCache) Get(key string) (*LockElem, error) {
 		return nil, err
 	}
 	elem := &LockElem{}
-	err = json.Unmarshal(val, &elem)
+	err = fc.codec.Unmarshal(val, elem)
 	if err != nil {
 		return nil, err
 	}
+	if elem.expire() {
+		return nil, nil
+	}
 	return elem, nil
 }
 
@@ -0,0 +1,72 @@
+package redlock
+
+import "container/heap"
+
+// expiryItem is an element of expiryHeap
+type expiryItem struct {
+	key string
+	// deadline is the monotonic timestamp the element expires at
+	deadline int64
+	index    int
+}
+
+// expiryHeap is a min-heap of cache elements keyed by their deadlines, it
+// implements heap.Interface
+type expiryHeap []*expiryItem
+
+func (h expiryHeap) Len() int { return len(h) }
+
+func (h expiryHeap) Less(i, j int) bool { return h[i].deadline < h[j].deadline }
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x interface{}) {
+	item := x.(*expiryItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expiryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// deadlineOf returns the monotonic timestamp that elem expires at
+func deadlineOf(elem *LockElem) int64 {
+	return elem.Mono + elem.Expiry
+}
+
+// track adds or updates the deadline of key in the expiry heap
+func (sc *SimpleCache) track(key string, elem *LockElem) {
+	if item, ok := sc.items[key]; ok {
+		item.deadline = deadlineOf(elem)
+		heap.Fix(&sc.expiries, item.index)
+		return
+	}
+	item := &expiryItem{key: key, deadline: deadlineOf(elem)}
+	heap.Push(&sc.expiries, item)
+	sc.items[key] = item
+}
+
+// untrack removes key from the expiry heap
+func (sc *SimpleCache) untrack(key string) {
+	if item, ok := sc.items[key]; ok {
+		heap.Remove(&sc.expiries, item.index)
+		delete(sc.items, key)
+	}
+}
+
+// evict removes the element nearest to expiry
+func (sc *SimpleCache) evict() {
+	item := heap.Pop(&sc.expiries).(*expiryItem)
+	delete(sc.items, item.key)
+	delete(sc.kvs, item.key)
+}
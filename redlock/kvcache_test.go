@@ -2,6 +2,8 @@ package redlock
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"testing"
 	"time"
 
@@ -54,6 +56,20 @@ func TestSimpleCache(t *testing.T) {
 	cache.gc()
 	assert.Zero(t, cache.Size())
 
+	// test gc in batches, unexpired elements are kept
+	cache = NewSimpleCache(ctx, &CacheOptions{DisableGC: true, GCBatchSize: 7})
+	for i := 0; i < 100; i++ {
+		exp := shortExpiry
+		if i%10 == 0 {
+			exp = int64(time.Minute)
+		}
+		_, err = cache.Set(fmt.Sprintf("key_%d", i), val, exp)
+		assert.Nil(t, err)
+	}
+	time.Sleep(time.Nanosecond * time.Duration(shortExpiry+1))
+	cache.gc()
+	assert.Equal(t, 10, cache.Size())
+
 	// test auto gc
 	opts = &CacheOptions{
 		DisableGC:  false,
@@ -67,12 +83,93 @@ func TestSimpleCache(t *testing.T) {
 	assert.Zero(t, cache.Size())
 }
 
+func TestCacheOptionsValidate(t *testing.T) {
+	testCases := []struct {
+		opts  []CacheOption
+		valid bool
+	}{
+		{nil, true},
+		{[]CacheOption{WithGCInterval(0)}, false},
+		{[]CacheOption{WithGCInterval(-time.Second)}, false},
+		{[]CacheOption{WithDisableGC(true), WithGCInterval(0)}, true},
+		{[]CacheOption{WithCacheType(CacheTypeFreeCache), WithCacheSize(0)}, false},
+		{[]CacheOption{WithCacheType(CacheTypeFreeCache), WithCacheSize(-1)}, false},
+		{[]CacheOption{WithCacheType(CacheTypeFreeCache), WithGCInterval(0)}, true},
+		{[]CacheOption{WithCacheSize(0)}, true},
+		{[]CacheOption{WithSimpleCacheCap(-1)}, false},
+		{[]CacheOption{WithNoCache(), WithGCInterval(0)}, true},
+	}
+	for idx, tc := range testCases {
+		err := newCacheOptions(tc.opts...).Validate()
+		if tc.valid {
+			assert.Nil(t, err, "case %d", idx)
+		} else {
+			assert.NotNil(t, err, "case %d", idx)
+		}
+	}
+}
+
+func TestSimpleCacheInvalidGCInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// an invalid gc interval falls back to the default instead of panicking
+	cache := NewCacheImpl(ctx, WithGCInterval(0))
+	_, err := cache.Set("foo", "bar", int64(time.Second))
+	assert.Nil(t, err)
+}
+
+func TestSimpleCacheCap(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cache := NewCacheImpl(ctx, WithDisableGC(true), WithSimpleCacheCap(3)).(*SimpleCache)
+	keys := func() []string {
+		keys := make([]string, 0, cache.Size())
+		for key := range cache.kvs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	set := func(key string, expiry time.Duration) {
+		_, err := cache.Set(key, "val", int64(expiry))
+		assert.Nil(t, err)
+		assert.Equal(t, len(cache.kvs), cache.expiries.Len())
+	}
+
+	set("a", 3*time.Second)
+	set("b", time.Second)
+	set("c", 2*time.Second)
+	// the element nearest to expiry is evicted first
+	set("d", 5*time.Second)
+	assert.Equal(t, []string{"a", "c", "d"}, keys())
+	set("e", 4*time.Second)
+	assert.Equal(t, []string{"a", "d", "e"}, keys())
+
+	// updating a key doesn't evict, its new expiry is tracked
+	set("a", 10*time.Second)
+	assert.Equal(t, []string{"a", "d", "e"}, keys())
+	set("f", 6*time.Second)
+	assert.Equal(t, []string{"a", "d", "f"}, keys())
+
+	// deleted and expired elements free their room
+	cache.Delete("d")
+	set("g", time.Nanosecond)
+	set("h", 7*time.Second)
+	assert.Equal(t, []string{"a", "f", "h"}, keys())
+	cache.Delete("f")
+	set("i", time.Nanosecond)
+	time.Sleep(time.Millisecond)
+	cache.gc()
+	assert.Equal(t, []string{"a", "h"}, keys())
+	assert.Equal(t, 2, cache.expiries.Len())
+}
+
 func TestFreeCache(t *testing.T) {
 	var (
 		key               = "test_key"
 		val               = "test_value"
-		expiry      int64 = 1000
-		shortExpiry int64 = 50
+		expiry      int64 = 1_000_000_000
+		shortExpiry int64 = 50_000_000
 		elem, elem2 *LockElem
 		err         error
 	)
@@ -104,3 +201,33 @@ func TestFreeCache(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Nil(t, elem)
 }
+
+func TestLockElemClockStep(t *testing.T) {
+	var expiry int64 = 5_000_000
+	elem := newLockElem("test_value", expiry)
+	// wall clock steps backward after the element is set, Ts looks like in
+	// the future, besides the monotonic reading of Ts is stripped.
+	elem.Ts = elem.Ts.Round(0).Add(time.Hour)
+	assert.False(t, elem.expire())
+	time.Sleep(time.Duration(expiry) + time.Millisecond)
+	assert.True(t, elem.expire())
+
+	// without the monotonic timestamp, wall clock is used
+	elem = &LockElem{Val: "test_value", Expiry: expiry, Ts: time.Now().Round(0).Add(-time.Hour)}
+	assert.True(t, elem.expire())
+
+	// monotonic timestamp survives serialization of FreeCache
+	for name, codec := range testCodecs {
+		cache := NewFreeCache(&CacheOptions{CacheSize: 1024 * 1024, Codec: codec})
+		elem, err := cache.Set("test_key", "test_value", expiry)
+		assert.Nil(t, err, name)
+		elem.Ts = elem.Ts.Round(0).Add(time.Hour)
+		elem2, err := cache.Get("test_key")
+		assert.Nil(t, err, name)
+		assert.Equal(t, elem.Mono, elem2.Mono, name)
+		time.Sleep(time.Duration(expiry) + time.Millisecond)
+		elem2, err = cache.Get("test_key")
+		assert.Nil(t, err, name)
+		assert.Nil(t, elem2, name)
+	}
+}
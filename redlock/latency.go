@@ -0,0 +1,136 @@
+package redlock
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// LatencyStats is the command latency observed on a redis instance
+type LatencyStats struct {
+	// Count is the count of commands, a pipeline is counted as one command
+	Count int64
+	// Errors is the count of commands failed
+	Errors int64
+	// Total is the sum of latencies of all commands
+	Total time.Duration
+	// Max is the max latency of a single command
+	Max time.Duration
+}
+
+// Mean returns the average latency of commands
+func (s LatencyStats) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// WithInstanceLatency records the latency of every command sent to each
+// redis instance, by a hook added to the redis clients via AddHook, so a slow
+// instance dragging down quorum can be identified by InstanceLatencies. It
+// takes effect on construction, since the hook is added to the clients, which
+// are shared by clones along with the stats. Note the hook is also added to
+// the clients passed to NewRedLockFromClients, and it costs a little on each
+// command, so it is disabled by default.
+func WithInstanceLatency() LockOption {
+	return func(r *RedLock) {
+		r.instanceLatency = true
+	}
+}
+
+// InstanceLatencies returns the command latency of each redis instance keyed
+// by its address, it is empty unless WithInstanceLatency is set.
+func (r *RedLock) InstanceLatencies() map[string]LatencyStats {
+	stats := make(map[string]LatencyStats, len(r.clients))
+	for _, cli := range r.clients {
+		if cli.latency != nil {
+			stats[cli.addr] = cli.latency.stats()
+		}
+	}
+	return stats
+}
+
+// hookable is implemented by the go-redis clients supporting hooks
+type hookable interface {
+	AddHook(redis.Hook)
+}
+
+// addLatencyHooks adds a latency hook to each client that supports hooks and
+// doesn't have one yet
+func addLatencyHooks(clients []*RedClient) {
+	for _, cli := range clients {
+		h, ok := cli.cli.(hookable)
+		if !ok || cli.latency != nil {
+			continue
+		}
+		cli.latency = &latencyHook{}
+		h.AddHook(cli.latency)
+	}
+}
+
+type latencyStartKey struct{}
+
+// latencyHook is a redis.Hook recording the latency of commands
+type latencyHook struct {
+	mu sync.Mutex
+	s  LatencyStats
+}
+
+var _ redis.Hook = (*latencyHook)(nil)
+
+func (h *latencyHook) stats() LatencyStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.s
+}
+
+func (h *latencyHook) observe(ctx context.Context, failed bool) {
+	start, ok := ctx.Value(latencyStartKey{}).(time.Time)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(start)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.s.Count++
+	if failed {
+		h.s.Errors++
+	}
+	h.s.Total += elapsed
+	if elapsed > h.s.Max {
+		h.s.Max = elapsed
+	}
+}
+
+func (h *latencyHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, latencyStartKey{}, time.Now()), nil
+}
+
+func (h *latencyHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.observe(ctx, cmdFailed(cmd))
+	return nil
+}
+
+func (h *latencyHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	return context.WithValue(ctx, latencyStartKey{}, time.Now()), nil
+}
+
+func (h *latencyHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	failed := false
+	for _, cmd := range cmds {
+		failed = failed || cmdFailed(cmd)
+	}
+	h.observe(ctx, failed)
+	return nil
+}
+
+// cmdFailed returns whether cmd failed, neither a nil reply nor NOSCRIPT,
+// which is expected before the script is loaded, is a failure
+func cmdFailed(cmd redis.Cmder) bool {
+	err := cmd.Err()
+	return err != nil && err != redis.Nil && !strings.HasPrefix(err.Error(), "NOSCRIPT")
+}
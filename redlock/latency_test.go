@@ -0,0 +1,48 @@
+package redlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstanceLatency(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	defer lock.Close()
+	assert.Empty(t, lock.InstanceLatencies())
+	assert.Equal(t, time.Duration(0), LatencyStats{}.Mean())
+
+	lock, err = NewRedLock(ctx, redisServers, WithInstanceLatency())
+	assert.Nil(t, err)
+	defer lock.Close()
+	_, err = lock.Lock(ctx, "latency-foo", time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnLock(ctx, "latency-foo"))
+	assert.Nil(t, lock.ReleaseAll(ctx, "latency-bar"))
+
+	stats := lock.InstanceLatencies()
+	assert.Len(t, stats, len(redisServers))
+	for _, addr := range redisServers {
+		s := stats[addr]
+		// SETNX, the unlock script and the release pipeline
+		assert.GreaterOrEqual(t, s.Count, int64(2), addr)
+		assert.Equal(t, int64(0), s.Errors, addr)
+		assert.Greater(t, int64(s.Total), int64(0), addr)
+		assert.LessOrEqual(t, int64(s.Mean()), int64(s.Max), addr)
+	}
+
+	// clones share the clients and their stats
+	clone, err := lock.Clone()
+	assert.Nil(t, err)
+	defer clone.Close()
+	_, err = clone.Lock(ctx, "latency-foo", time.Second)
+	assert.Nil(t, err)
+	for addr, s := range clone.InstanceLatencies() {
+		assert.Greater(t, s.Count, stats[addr].Count, addr)
+	}
+	assert.Nil(t, clone.UnLock(ctx, "latency-foo"))
+}
@@ -0,0 +1,152 @@
+package redlock
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	// LeaseLockScript is redis lua script to acquire a lock along with its
+	// lease metadata, which is a hash of the value, owner, acquisition time
+	// in unix milliseconds and ttl in milliseconds, expiring with the lock
+	LeaseLockScript = `
+        if redis.call("set", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+            redis.call("hset", KEYS[2], "value", ARGV[1], "owner", ARGV[3], "acquired_at", ARGV[4], "ttl", ARGV[2])
+            redis.call("pexpire", KEYS[2], ARGV[2])
+            return 1
+        else
+            return 0
+        end
+        `
+
+	// LeaseExtendScript is redis lua script to reset the ttl of a lock held
+	// by us along with its lease metadata
+	LeaseExtendScript = `
+        if redis.call("get", KEYS[1]) ~= ARGV[1] then
+            return 0
+        end
+        if redis.call("hget", KEYS[2], "value") == ARGV[1] then
+            redis.call("hset", KEYS[2], "ttl", ARGV[2])
+            redis.call("pexpire", KEYS[2], ARGV[2])
+        end
+        return redis.call("pexpire", KEYS[1], ARGV[2])
+        `
+
+	// LeaseInspectScript is redis lua script to read the lease metadata of
+	// the lock currently held, a lease left by a released lock is ignored
+	LeaseInspectScript = `
+        local v = redis.call("get", KEYS[1])
+        if not v or redis.call("hget", KEYS[2], "value") ~= v then
+            return false
+        end
+        return redis.call("hmget", KEYS[2], "owner", "acquired_at", "ttl")
+        `
+)
+
+var (
+	leaseLockScript    = newLuaScript(LeaseLockScript)
+	leaseExtendScript  = newLuaScript(LeaseExtendScript)
+	leaseInspectScript = newLuaScript(LeaseInspectScript)
+)
+
+// leaseSuffix is appended to the lock key to form the key of its lease
+const leaseSuffix = ":lease"
+
+// Lease is the metadata of a lock stored in redis
+type Lease struct {
+	// Owner is the owner embedded by WithOwnerProvider, or empty
+	Owner      string
+	AcquiredAt time.Time
+	// TTL is the ttl that lock is acquired or last extended with
+	TTL time.Duration
+}
+
+// WithLeaseMetadata makes each acquisition write a companion hash of the lock,
+// under key `<lock key>:lease`, storing the owner, acquisition time and ttl of
+// the lock, which is read by InspectLease. The hash is written atomically with
+// the lock, expires with the lock and is deleted atomically with the lock on
+// release. On redis cluster the lock key and lease key must be in the same
+// hash slot, use WithHashTag.
+func WithLeaseMetadata() LockOption {
+	return func(r *RedLock) {
+		r.leaseMetadata = true
+	}
+}
+
+// leaseLockInstance sets the lock along with its lease on a single instance
+func (r *RedLock) leaseLockInstance(ctx context.Context, client *RedClient, key, val string, ttl time.Duration) lockResult {
+	acquiredAt := time.Now().UnixNano() / int64(time.Millisecond)
+	reply := leaseLockScript.run(ctx, client.cli, []string{key, key + leaseSuffix}, val, formatMs(ttl), r.ownerOf(val), acquiredAt)
+	if reply.Err() != nil {
+		return lockResult{err: reply.Err()}
+	}
+	return lockResult{locked: reply.Val() == int64(1)}
+}
+
+// InspectLease reads the lease metadata of the lock of resource from every
+// instance, returns a map from the address of instance to the lease,
+// instances without the lock or its lease are omitted. The first error of
+// instances is returned along with the leases read from the others. Leases
+// are written only if WithLeaseMetadata is enabled.
+func (r *RedLock) InspectLease(ctx context.Context, resource string) (map[string]Lease, error) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		leases   = make(map[string]Lease, len(r.clients))
+		firstErr error
+	)
+	key := r.redisKey(resource)
+	for _, cli := range r.clients {
+		cli := cli
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reply, err := leaseInspectScript.run(ctx, cli.cli, []string{key, key + leaseSuffix}).Result()
+			var lease Lease
+			if err == nil {
+				fields, _ := reply.([]interface{})
+				lease, err = parseLease(fields)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				leases[cli.addr] = lease
+			case err != redis.Nil && firstErr == nil:
+				firstErr = err
+			}
+		}()
+	}
+	wg.Wait()
+	return leases, firstErr
+}
+
+// parseLease parses the owner, acquired_at and ttl fields of a lease
+func parseLease(fields []interface{}) (Lease, error) {
+	if len(fields) != 3 || fields[1] == nil || fields[2] == nil {
+		return Lease{}, redis.Nil
+	}
+	owner, _ := fields[0].(string)
+	acquiredAt, err := parseInt(fields[1])
+	if err != nil {
+		return Lease{}, err
+	}
+	ttl, err := parseInt(fields[2])
+	if err != nil {
+		return Lease{}, err
+	}
+	return Lease{
+		Owner:      owner,
+		AcquiredAt: time.Unix(0, acquiredAt*int64(time.Millisecond)),
+		TTL:        time.Duration(ttl) * time.Millisecond,
+	}, nil
+}
+
+func parseInt(v interface{}) (int64, error) {
+	s, _ := v.(string)
+	return strconv.ParseInt(s, 10, 64)
+}
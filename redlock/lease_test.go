@@ -0,0 +1,107 @@
+package redlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaseMetadata(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithLeaseMetadata(), WithOwnerProvider(func(context.Context) string {
+		return "worker-1"
+	}))
+	assert.Nil(t, err)
+
+	start := time.Now().Truncate(time.Millisecond)
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	leases, err := lock.InspectLease(ctx, "foo")
+	assert.Nil(t, err)
+	assert.Len(t, leases, len(redisServers))
+	for _, lease := range leases {
+		assert.Equal(t, "worker-1", lease.Owner)
+		assert.Equal(t, time.Second, lease.TTL)
+		assert.False(t, lease.AcquiredAt.Before(start))
+		assert.False(t, lease.AcquiredAt.After(time.Now()))
+	}
+	for _, cli := range lock.clients {
+		pttl, err := rawClient(cli).PTTL(ctx, "foo"+leaseSuffix).Result()
+		assert.Nil(t, err)
+		assert.Greater(t, int64(pttl), int64(0))
+		assert.LessOrEqual(t, int64(pttl), int64(time.Second))
+	}
+
+	// the lease is extended with the lock
+	_, err = lock.Extend(ctx, "foo", 2*time.Second)
+	assert.Nil(t, err)
+	leases, err = lock.InspectLease(ctx, "foo")
+	assert.Nil(t, err)
+	assert.Len(t, leases, len(redisServers))
+	for _, lease := range leases {
+		assert.Equal(t, 2*time.Second, lease.TTL)
+	}
+	for _, cli := range lock.clients {
+		pttl, err := rawClient(cli).PTTL(ctx, "foo"+leaseSuffix).Result()
+		assert.Nil(t, err)
+		assert.Greater(t, int64(pttl), int64(time.Second))
+	}
+
+	// the lease is deleted with the lock
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+	leases, err = lock.InspectLease(ctx, "foo")
+	assert.Nil(t, err)
+	assert.Empty(t, leases)
+	for _, cli := range lock.clients {
+		n, err := rawClient(cli).Exists(ctx, "foo", "foo"+leaseSuffix).Result()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), n)
+	}
+}
+
+func TestUnlockLeaseMetadata(t *testing.T) {
+	ctx := context.Background()
+	for _, matcher := range []ValueMatcher{ExactMatch(), PrefixMatch(8), FieldMatch(":", 0)} {
+		lock, err := NewRedLock(ctx, redisServers, WithLeaseMetadata(), WithValueMatcher(matcher))
+		assert.Nil(t, err)
+		_, err = lock.Lock(ctx, "bar", time.Second)
+		assert.Nil(t, err)
+		elem, err := lock.cache.Get("bar")
+		assert.Nil(t, err)
+
+		// the lease of a lock held by others is kept
+		for _, cli := range lock.clients {
+			_, err := lock.unlockInstance(ctx, cli, "bar", "other value")
+			assert.Nil(t, err)
+			n, err := rawClient(cli).Exists(ctx, "bar", "bar"+leaseSuffix).Result()
+			assert.Nil(t, err)
+			assert.Equal(t, int64(2), n)
+		}
+
+		for _, cli := range lock.clients {
+			_, err := lock.unlockInstance(ctx, cli, "bar", elem.Val)
+			assert.Nil(t, err)
+			n, err := rawClient(cli).Exists(ctx, "bar", "bar"+leaseSuffix).Result()
+			assert.Nil(t, err)
+			assert.Equal(t, int64(0), n)
+		}
+		lock.cache.Delete("bar")
+	}
+}
+
+func TestParseLease(t *testing.T) {
+	lease, err := parseLease([]interface{}{"owner", "1600000000000", "1500"})
+	assert.Nil(t, err)
+	assert.Equal(t, Lease{
+		Owner:      "owner",
+		AcquiredAt: time.Unix(1600000000, 0),
+		TTL:        1500 * time.Millisecond,
+	}, lease)
+
+	_, err = parseLease([]interface{}{nil, nil, nil})
+	assert.NotNil(t, err)
+	_, err = parseLease([]interface{}{"owner", "now", "1500"})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,126 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Lock is the handle of an acquired lock, it is safe for concurrent use
+type Lock struct {
+	// gen is the generation of a pooled handle, it is bumped when the handle
+	// is released by PooledLock.Unlock. It is the first field to be 64-bit
+	// aligned for atomic access.
+	gen uint64
+
+	r        *RedLock
+	resource string
+	val      string
+	holders  []string
+	margin   int
+	attempts int
+
+	// acquiredAt is the time that quorum was reached in the successful
+	// acquisition attempt, validity is counted from it
+	acquiredAt time.Time
+
+	mu       sync.Mutex
+	ttl      time.Duration
+	validity time.Duration
+}
+
+// Resource returns the resource name of the lock
+func (l *Lock) Resource() string {
+	return l.resource
+}
+
+// Value returns the random value of the lock
+func (l *Lock) Value() string {
+	return l.val
+}
+
+// TTL returns the current ttl of the lock, which is updated by each
+// successful extend
+func (l *Lock) TTL() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.ttl
+}
+
+// Validity returns the valid duration that lock is guaranted when acquired or
+// last extended, it is the ttl minus the time cost of the round trips and the
+// clock drift, so it is always shorter than TTL.
+func (l *Lock) Validity() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.validity
+}
+
+// ExtendTo resets the ttl of the lock to ttl, returns the new validity. The
+// ttl must not exceed the max ttl of RedLock.
+func (l *Lock) ExtendTo(ctx context.Context, ttl time.Duration) (time.Duration, error) {
+	if err := l.r.checkTTL(ttl); err != nil {
+		return 0, err
+	}
+	validity, err := l.r.extend(ctx, l.resource, l.val, ttl)
+	if err != nil {
+		return 0, err
+	}
+	l.mu.Lock()
+	l.ttl = ttl
+	l.validity = validity
+	l.mu.Unlock()
+	return validity, nil
+}
+
+// ExtendBy grows the ttl of the lock by delta, returns the new validity. The
+// new ttl is capped by the max ttl of RedLock, so repeated ExtendBy
+// calls hold the lock longer and longer up to the cap.
+func (l *Lock) ExtendBy(ctx context.Context, delta time.Duration) (time.Duration, error) {
+	ttl := l.TTL() + delta
+	if l.r.maxTTL > 0 && ttl > l.r.maxTTL {
+		ttl = l.r.maxTTL
+	}
+	return l.ExtendTo(ctx, ttl)
+}
+
+// Margin returns how many instances the lock was acquired on beyond quorum.
+// A zero margin means the lock was acquired on exactly quorum, one more
+// instance failure would make the lock unavailable, a persistently zero
+// margin warrants investigation.
+func (l *Lock) Margin() int {
+	return l.margin
+}
+
+// Attempts returns how many attempts the acquisition took, including the
+// successful one, it is zero for a re-entrant acquisition which takes no
+// attempt on redis.
+func (l *Lock) Attempts() int {
+	return l.attempts
+}
+
+// Holders returns the addresses of redis instances that acknowledged the lock
+// during acquisition, the result is read-only metadata and is not refreshed
+// after acquisition.
+func (l *Lock) Holders() []string {
+	return l.holders
+}
+
+// Unlock releases the lock, it is released by its value even if the local
+// cache fails to look it up
+func (l *Lock) Unlock(ctx context.Context) error {
+	if _, ok := l.r.cache.(NoCache); ok {
+		return l.r.UnlockByValue(ctx, l.resource, l.val)
+	}
+	err := l.r.UnLock(ctx, l.resource)
+	// the handle knows the value when the cache doesn't, and drops the
+	// entry that can't be checked
+	if errors.Is(err, ErrCacheUnavailable) {
+		defer l.r.holdCounts.clear(l.resource)
+		defer l.r.cache.Delete(l.resource)
+		defer l.r.heldTTLs.delete(l.resource)
+		return l.r.UnlockByValue(ctx, l.resource, l.val)
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockHolders(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+
+	l, err := lock.Acquire(ctx, "foo", 200*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", l.Resource())
+	assert.Greater(t, int64(l.Validity()), int64(0))
+	assert.Equal(t, redisServers, l.Holders())
+	assert.Equal(t, 1, l.Margin())
+	elem, err := lock.cache.Get("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, elem.Val, l.Value())
+	assert.Nil(t, l.Unlock(ctx))
+
+	// one instance is held by others, it is not a holder
+	cli := rawClient(lock.clients[1])
+	assert.Nil(t, cli.Set(ctx, "foo", "others", 0).Err())
+	defer cli.Del(ctx, "foo")
+	l, err = lock.Acquire(ctx, "foo", 200*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{redisServers[0], redisServers[2]}, l.Holders())
+	assert.Zero(t, l.Margin())
+	assert.Nil(t, l.Unlock(ctx))
+}
+
+func TestLockExtendByTo(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	lock.SetMaxTTL(time.Second)
+
+	l, err := lock.Acquire(ctx, "foo", 200*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, 200*time.Millisecond, l.TTL())
+
+	validity, err := l.ExtendBy(ctx, 300*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, 500*time.Millisecond, l.TTL())
+	assert.Equal(t, validity, l.Validity())
+	assert.Less(t, int64(validity), int64(l.TTL()))
+
+	// capped by max ttl
+	_, err = l.ExtendBy(ctx, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Second, l.TTL())
+
+	_, err = l.ExtendTo(ctx, 2*time.Second)
+	assert.True(t, errors.Is(err, ErrTTLTooLong))
+	assert.Equal(t, time.Second, l.TTL())
+
+	_, err = l.ExtendTo(ctx, 100*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, 100*time.Millisecond, l.TTL())
+	assert.Nil(t, l.Unlock(ctx))
+
+	// lock lost, extend fails and ttl is not changed
+	_, err = l.ExtendTo(ctx, 300*time.Millisecond)
+	assert.Equal(t, ErrExtendLock, err)
+	assert.Equal(t, 100*time.Millisecond, l.TTL())
+}
@@ -0,0 +1,49 @@
+package redlock
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// WithLongHoldWarning warns when a lock is released after holding more than
+// fraction of its validity since it was acquired or last extended, such as
+// 0.8, which means the critical section ran dangerously close to losing the
+// lock. The warning is logged, emitted as an EventLongHold if events are
+// enabled, and counted by the long_holds variable of WithExpvar. The fraction
+// must be in (0, 1].
+func WithLongHoldWarning(fraction float64) LockOption {
+	return func(r *RedLock) {
+		if fraction <= 0 || fraction > 1 {
+			r.setOptErr(fmt.Errorf("invalid long hold fraction %v, must be in (0, 1]", fraction))
+			return
+		}
+		r.longHoldFraction = fraction
+	}
+}
+
+// checkHold warns if the lock of resource in elem, which is being released,
+// was held longer than the long hold fraction of its validity
+func (r *RedLock) checkHold(resource string, elem *LockElem) {
+	if r.longHoldFraction == 0 || elem.Expiry <= 0 {
+		return
+	}
+	held := elem.Expiry - elem.remaining()
+	if float64(held) <= r.longHoldFraction*float64(elem.Expiry) {
+		return
+	}
+	validity := time.Duration(elem.Expiry)
+	log.Printf("redlock: lock %s released after holding %s of validity %s", resource, time.Duration(held), validity)
+	if r.expvar != nil {
+		r.expvar.longHolds.Add(1)
+	}
+	if r.events != nil {
+		r.sendEvent(LockEvent{
+			Type:     EventLongHold,
+			Resource: resource,
+			Time:     time.Now(),
+			Validity: validity,
+			Held:     time.Duration(held),
+		})
+	}
+}
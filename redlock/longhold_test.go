@@ -0,0 +1,50 @@
+package redlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLongHoldWarning(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithLongHoldWarning(0.5), WithEvents(10), WithExpvar())
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	// a short hold is not warned
+	_, err = lock.Lock(ctx, "long-hold-foo", time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnLock(ctx, "long-hold-foo"))
+	assert.Equal(t, EventAcquired, (<-lock.Events()).Type)
+	assert.Equal(t, EventReleased, (<-lock.Events()).Type)
+
+	_, err = lock.Lock(ctx, "long-hold-foo", 200*time.Millisecond)
+	assert.Nil(t, err)
+	_, err = lock.Lock(ctx, "long-hold-bar", 200*time.Millisecond)
+	assert.Nil(t, err)
+	time.Sleep(150 * time.Millisecond)
+	assert.Nil(t, lock.UnLock(ctx, "long-hold-foo"))
+	assert.Nil(t, lock.ReleaseAll(ctx, "long-hold-bar"))
+	assert.Equal(t, int64(2), lock.expvar.longHolds.Value())
+
+	longHolds := 0
+	for len(lock.Events()) > 0 {
+		ev := <-lock.Events()
+		if ev.Type != EventLongHold {
+			continue
+		}
+		longHolds++
+		assert.Equal(t, "long_hold", ev.Type.String())
+		assert.Greater(t, int64(ev.Held), int64(ev.Validity/2))
+		assert.LessOrEqual(t, int64(ev.Validity), int64(200*time.Millisecond))
+	}
+	assert.Equal(t, 2, longHolds)
+
+	for _, fraction := range []float64{0, -0.5, 1.5} {
+		_, err = NewRedLock(ctx, redisServers, WithLongHoldWarning(fraction))
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,88 @@
+package redlock
+
+const (
+	// PrefixUnlockScript is redis lua script to release a lock whose value
+	// shares the first ARGV[2] bytes with ARGV[1], along with the companion
+	// keys in KEYS[2:]
+	PrefixUnlockScript = `
+        local v = redis.call("get", KEYS[1])
+        local n = tonumber(ARGV[2])
+        if v and string.sub(v, 1, n) == string.sub(ARGV[1], 1, n) then
+            return redis.call("del", unpack(KEYS))
+        else
+            return 0
+        end
+        `
+
+	// FieldUnlockScript is redis lua script to release a lock whose value has
+	// the same field as ARGV[1], fields are split by ARGV[2] and ARGV[3] is
+	// the 1-based field index, the companion keys in KEYS[2:] are deleted
+	// along with it
+	FieldUnlockScript = `
+        local function field(s, sep, idx)
+            local i, start = 1, 1
+            while true do
+                local pos = string.find(s, sep, start, true)
+                if i == idx then
+                    if pos then
+                        return string.sub(s, start, pos - 1)
+                    end
+                    return string.sub(s, start)
+                end
+                if not pos then
+                    return nil
+                end
+                start = pos + string.len(sep)
+                i = i + 1
+            end
+        end
+        local v = redis.call("get", KEYS[1])
+        if not v then
+            return 0
+        end
+        local idx = tonumber(ARGV[3])
+        local f = field(v, ARGV[2], idx)
+        if f and f == field(ARGV[1], ARGV[2], idx) then
+            return redis.call("del", unpack(KEYS))
+        else
+            return 0
+        end
+        `
+)
+
+// ValueMatcher defines how the unlock script compares the value stored in
+// redis with the lock value held by client, the check and delete are always
+// executed atomically.
+type ValueMatcher struct {
+	script *luaScript
+	args   []interface{}
+}
+
+// ExactMatch releases the lock only if the whole value matches, it is the default
+func ExactMatch() ValueMatcher {
+	return ValueMatcher{script: unlockScript}
+}
+
+// PrefixMatch releases the lock if the first n bytes of the value match
+func PrefixMatch(n int) ValueMatcher {
+	if n <= 0 {
+		return ExactMatch()
+	}
+	return ValueMatcher{script: prefixUnlockScript, args: []interface{}{n}}
+}
+
+// FieldMatch splits the value by sep and releases the lock if the field
+// with the given 0-based index matches
+func FieldMatch(sep string, index int) ValueMatcher {
+	if sep == "" || index < 0 {
+		return ExactMatch()
+	}
+	return ValueMatcher{script: fieldUnlockScript, args: []interface{}{sep, index + 1}}
+}
+
+// WithValueMatcher sets the ValueMatcher used by unlock script
+func WithValueMatcher(m ValueMatcher) LockOption {
+	return func(r *RedLock) {
+		r.matcher = m
+	}
+}
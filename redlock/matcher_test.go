@@ -0,0 +1,46 @@
+package redlock
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueMatcher(t *testing.T) {
+	ctx := context.Background()
+	opts, err := parseConnString(redisServers[0])
+	assert.Nil(t, err)
+	cli := redis.NewClient(opts)
+	client := &RedClient{addr: redisServers[0], cli: cli}
+
+	testCases := []struct {
+		matcher ValueMatcher
+		stored  string
+		held    string
+		deleted bool
+	}{
+		{ExactMatch(), "owner-a:token", "owner-a:token", true},
+		{ExactMatch(), "owner-a:token", "owner-b:token", false},
+		{PrefixMatch(7), "owner-a:token1", "owner-a:token2", true},
+		{PrefixMatch(7), "owner-a:token", "owner-b:token", false},
+		{PrefixMatch(0), "owner-a:token1", "owner-a:token2", false},
+		{FieldMatch(":", 1), "owner-a:token", "owner-b:token", true},
+		{FieldMatch(":", 1), "owner-a:token1", "owner-a:token2", false},
+		{FieldMatch("::", 0), "owner::token-a", "owner::token-b", true},
+		{FieldMatch(":", 2), "a:b:c", "x:y:c", true},
+		{FieldMatch(":", 3), "a:b:c", "a:b:c", false},
+		{FieldMatch("", 1), "owner-a:token", "owner-b:token", false},
+	}
+	for _, tc := range testCases {
+		lock := &RedLock{matcher: tc.matcher}
+		assert.Nil(t, cli.Set(ctx, "foo", tc.stored, 0).Err())
+		_, err := lock.unlockInstance(ctx, client, "foo", tc.held)
+		assert.Nil(t, err)
+		exists, err := cli.Exists(ctx, "foo").Result()
+		assert.Nil(t, err)
+		assert.Equal(t, tc.deleted, exists == 0, "%+v", tc)
+	}
+	cli.Del(ctx, "foo")
+}
@@ -0,0 +1,46 @@
+//go:build miniredis
+// +build miniredis
+
+package redlock
+
+import (
+	"strings"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// With the miniredis build tag, the tests run against in-process miniredis
+// servers listening on the addresses of redisServers, so no real redis is
+// needed:
+//
+//	go test -tags miniredis ./redlock/
+//
+// miniredis covers SETNX, EVAL and ttl semantics well, but its lua support
+// is gopher-lua rather than the lua of redis, and it doesn't support WAIT,
+// SET NX GET or replication, the tests of them pass only by the fallback
+// paths and still need real redis servers to cover.
+func init() {
+	inProcessRedis = true
+	servers := make([]*miniredis.Miniredis, 0, len(redisServers))
+	for _, server := range redisServers {
+		addr := strings.TrimPrefix(server, "tcp://")
+		m := miniredis.NewMiniRedis()
+		if err := m.StartAddr(addr); err != nil {
+			panic(err)
+		}
+		servers = append(servers, m)
+	}
+	// miniredis doesn't expire keys by itself, advance its clock with the
+	// wall clock
+	go func() {
+		last := time.Now()
+		for range time.Tick(time.Millisecond) {
+			now := time.Now()
+			for _, m := range servers {
+				m.FastForward(now.Sub(last))
+			}
+			last = now
+		}
+	}()
+}
@@ -0,0 +1,402 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// rawClient returns the underlying *redis.Client of a RedClient
+func rawClient(c *RedClient) *redis.Client {
+	if rec, ok := c.cli.(*recordingCmdable); ok {
+		return rec.redisCmdable.(*redis.Client)
+	}
+	return c.cli.(*redis.Client)
+}
+
+// mockCmdable is a redisCmdable whose behaviors are injected by test
+type mockCmdable struct {
+	setNX func(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error)
+	eval  func(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+	do    func(ctx context.Context, args ...interface{}) (interface{}, error)
+	get   func(ctx context.Context, key string) (string, error)
+
+	scriptLoad func(ctx context.Context, script string) (string, error)
+	close      func() error
+}
+
+func (m *mockCmdable) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) *redis.BoolCmd {
+	if m.setNX == nil {
+		return redis.NewBoolResult(true, nil)
+	}
+	return redis.NewBoolResult(m.setNX(ctx, key, value, ttl))
+}
+
+func (m *mockCmdable) Get(ctx context.Context, key string) *redis.StringCmd {
+	if m.get == nil {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	return redis.NewStringResult(m.get(ctx, key))
+}
+
+func (m *mockCmdable) Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	if m.eval == nil {
+		return redis.NewCmdResult(int64(1), nil)
+	}
+	return redis.NewCmdResult(m.eval(ctx, script, keys, args...))
+}
+
+func (m *mockCmdable) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	return redis.NewCmdResult(nil, errors.New("NOSCRIPT"))
+}
+
+func (m *mockCmdable) ScriptLoad(ctx context.Context, script string) *redis.StringCmd {
+	if m.scriptLoad == nil {
+		return redis.NewStringResult(newLuaScript(script).sha, nil)
+	}
+	return redis.NewStringResult(m.scriptLoad(ctx, script))
+}
+
+func (m *mockCmdable) Do(ctx context.Context, args ...interface{}) *redis.Cmd {
+	if m.do == nil {
+		return redis.NewCmdResult(nil, errors.New("ERR unknown command"))
+	}
+	return redis.NewCmdResult(m.do(ctx, args...))
+}
+
+func (m *mockCmdable) Wait(ctx context.Context, numSlaves int, timeout time.Duration) *redis.IntCmd {
+	return redis.NewIntResult(int64(numSlaves), nil)
+}
+
+func (m *mockCmdable) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
+	return redis.NewSliceResult(make([]interface{}, len(keys)), nil)
+}
+
+func (m *mockCmdable) Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd {
+	return redis.NewScanCmdResult(nil, 0, nil)
+}
+
+func (m *mockCmdable) Watch(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error {
+	return errors.New("ERR unknown command")
+}
+
+func (m *mockCmdable) Ping(ctx context.Context) *redis.StatusCmd {
+	return redis.NewStatusResult("PONG", nil)
+}
+
+func (m *mockCmdable) Close() error {
+	if m.close == nil {
+		return nil
+	}
+	return m.close()
+}
+
+// newMockRedLock creates a RedLock whose clients are the given mocks
+func newMockRedLock(t *testing.T, mocks ...redisCmdable) *RedLock {
+	return newMockRedLockWithOptions(t, mocks)
+}
+
+// newMockRedLockWithOptions creates a RedLock with options whose clients are
+// the given mocks
+func newMockRedLockWithOptions(t *testing.T, mocks []redisCmdable, opts ...Option) *RedLock {
+	addrs := make([]string, 0, len(mocks))
+	for i := range mocks {
+		addrs = append(addrs, fmt.Sprintf("tcp://mock%d:6379", i))
+	}
+	lock, err := NewRedLock(context.Background(), addrs, opts...)
+	assert.Nil(t, err)
+	for i, m := range mocks {
+		lock.clients[i].cli = m
+	}
+	return lock
+}
+
+func TestMockQuorum(t *testing.T) {
+	ctx := context.Background()
+	errInjected := errors.New("injected error")
+	faulty := func() *mockCmdable {
+		return &mockCmdable{
+			setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+				return false, errInjected
+			},
+		}
+	}
+
+	// a minority of faulty instances doesn't break quorum
+	lock := newMockRedLock(t, &mockCmdable{}, faulty(), &mockCmdable{})
+	l, err := lock.Acquire(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"tcp://mock0:6379", "tcp://mock2:6379"}, l.Holders())
+
+	// a majority of faulty instances fails every attempt, and each attempt
+	// cleans up the instances that were locked
+	unlocks := 0
+	healthy := &mockCmdable{
+		eval: func(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+			unlocks++
+			return int64(1), nil
+		},
+	}
+	lock = newMockRedLock(t, healthy, faulty(), faulty())
+	lock.SetRetryCount(3)
+	lock.SetRetryDelay(1)
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.True(t, errors.Is(err, ErrAcquireLock))
+	assert.Equal(t, 3, unlocks)
+}
+
+func TestMockSetNXGetFallback(t *testing.T) {
+	ctx := context.Background()
+	calls := 0
+	legacy := &mockCmdable{
+		do: func(ctx context.Context, args ...interface{}) (interface{}, error) {
+			calls++
+			return nil, errors.New("ERR syntax error")
+		},
+	}
+	modern := &mockCmdable{
+		do: func(ctx context.Context, args ...interface{}) (interface{}, error) {
+			return "others", nil
+		},
+	}
+	lock := newMockRedLock(t, legacy, modern, &mockCmdable{})
+	lock.setNXGet = true
+
+	res := lock.lockInstance(ctx, lock.clients[0], "foo", "val", time.Second)
+	assert.Equal(t, lockResult{locked: true}, res)
+	res = lock.lockInstance(ctx, lock.clients[0], "foo", "val", time.Second)
+	assert.Equal(t, lockResult{locked: true}, res)
+	// SET NX GET is tried only once on legacy server
+	assert.Equal(t, 1, calls)
+
+	res = lock.lockInstance(ctx, lock.clients[1], "foo", "val", time.Second)
+	assert.Equal(t, lockResult{holder: "others"}, res)
+}
+
+func TestMockCancelCleanup(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	unlocks := int32(0)
+	newMock := func() *mockCmdable {
+		return &mockCmdable{
+			eval: func(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+				atomic.AddInt32(&unlocks, 1)
+				return int64(1), ctx.Err()
+			},
+		}
+	}
+	blocking := newMock()
+	blocking.setNX = func(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+		cancel()
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+	lock := newMockRedLock(t, newMock(), blocking, newMock())
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	assert.Equal(t, context.Canceled, err)
+	// instances are released with a detached context
+	assert.Equal(t, int32(3), atomic.LoadInt32(&unlocks))
+	assert.Zero(t, lock.cache.Size())
+}
+
+func TestMockAcquireBudget(t *testing.T) {
+	unlocks := int32(0)
+	newMock := func() *mockCmdable {
+		return &mockCmdable{
+			eval: func(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+				atomic.AddInt32(&unlocks, 1)
+				return int64(1), ctx.Err()
+			},
+		}
+	}
+	hung := newMock()
+	hung.setNX = func(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+
+	// a hung instance is given the instance timeout rather than the ttl
+	lock := newMockRedLockWithOptions(t, []redisCmdable{newMock(), hung, newMock()},
+		WithInstanceTimeout(20*time.Millisecond))
+	start := time.Now()
+	validity, err := lock.Lock(context.Background(), "foo", 10*time.Second)
+	assert.Nil(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.Greater(t, int64(validity), int64(9*time.Second))
+	assert.Nil(t, lock.UnLock(context.Background(), "foo"))
+
+	// the deadline of ctx is the budget of acquisition, the instances locked
+	// in the last attempt are released with a detached context
+	lock = newMockRedLock(t, newMock(), hung, hung)
+	lock.SetRetryCount(100)
+	lock.SetRetryDelay(1)
+	atomic.StoreInt32(&unlocks, 0)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	_, err = lock.Lock(ctx, "foo", 10*time.Second)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&unlocks))
+	assert.Zero(t, lock.cache.Size())
+}
+
+func TestMockNoValidity(t *testing.T) {
+	ctx := context.Background()
+	attempts := int32(0)
+	slow := &mockCmdable{
+		setNX: func(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, error) {
+			atomic.AddInt32(&attempts, 1)
+			time.Sleep(ttl)
+			return true, nil
+		},
+	}
+
+	lock := newMockRedLock(t, slow, slow, slow)
+	WithExpvar()(lock)
+	lock.expvar.publish(lock)
+	lock.SetRetryCount(3)
+	lock.SetRetryDelay(1)
+	_, err := lock.Lock(ctx, "foo", 20*time.Millisecond)
+	assert.True(t, errors.Is(err, ErrAcquireLock))
+	assert.Equal(t, int32(9), atomic.LoadInt32(&attempts))
+	assert.Equal(t, int64(3), lock.expvar.noValidity.Value())
+
+	atomic.StoreInt32(&attempts, 0)
+	WithFailFastOnNoValidity()(lock)
+	_, err = lock.Lock(ctx, "foo", 20*time.Millisecond)
+	assert.True(t, errors.Is(err, ErrNoValidity))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	assert.Zero(t, lock.cache.Size())
+}
+
+func TestQuorumPercent(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		n       int
+		percent float64
+		quorum  int
+		valid   bool
+	}{
+		{3, 0, 2, true},
+		{5, 0, 3, true},
+		{5, 0.51, 3, true},
+		{5, 0.7, 4, true},
+		{5, 1, 5, true},
+		{7, 0.6, 5, true},
+		{5, 0.5, 0, false},
+		{5, 1.1, 0, false},
+	}
+	for _, tc := range testCases {
+		addrs := make([]string, 0, tc.n)
+		for i := 0; i < tc.n; i++ {
+			addrs = append(addrs, fmt.Sprintf("tcp://mock%d:6379", i))
+		}
+		opts := []Option{}
+		if tc.percent > 0 {
+			opts = append(opts, WithQuorumPercent(tc.percent))
+		}
+		lock, err := NewRedLock(ctx, addrs, opts...)
+		if !tc.valid {
+			assert.NotNil(t, err, "%+v", tc)
+			continue
+		}
+		assert.Nil(t, err, "%+v", tc)
+		assert.Equal(t, tc.quorum, lock.quorum, "%+v", tc)
+	}
+
+	// quorum of 100% requires all instances
+	faulty := &mockCmdable{
+		setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+			return false, nil
+		},
+	}
+	lock := newMockRedLockWithOptions(t, []redisCmdable{&mockCmdable{}, &mockCmdable{}, faulty}, WithQuorumPercent(1))
+	lock.SetRetryCount(1)
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	assert.True(t, errors.Is(err, ErrAcquireLock))
+}
+
+func TestMockErrorTypes(t *testing.T) {
+	ctx := context.Background()
+	errInjected := errors.New("injected error")
+	faulty := &mockCmdable{
+		setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+			return false, errInjected
+		},
+		eval: func(context.Context, string, []string, ...interface{}) (interface{}, error) {
+			return nil, errInjected
+		},
+	}
+
+	lock := newMockRedLock(t, &mockCmdable{}, faulty, faulty)
+	lock.SetRetryCount(1)
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	var qe *QuorumError
+	assert.True(t, errors.As(err, &qe))
+	assert.Equal(t, "foo", qe.Resource())
+	assert.Equal(t, 1, qe.Acquired)
+	assert.Equal(t, 2, qe.Quorum)
+	assert.Equal(t, []error{nil, errInjected, errInjected}, qe.Errs)
+	var re RedlockError
+	assert.True(t, errors.As(err, &re))
+
+	lock = newMockRedLockWithOptions(t, []redisCmdable{&mockCmdable{}, &mockCmdable{}, &mockCmdable{}}, WithFailFastOnNoValidity())
+	lock.driftFactor = 1
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	var ae *AcquireError
+	assert.True(t, errors.As(err, &ae))
+	assert.Equal(t, "foo", ae.Resource())
+	assert.Equal(t, ErrNoValidity, ae.Err)
+
+	// release fails on too many instances for others to reach quorum
+	lock = newMockRedLock(t, &mockCmdable{}, faulty, &mockCmdable{})
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+	lock = newMockRedLock(t, &mockCmdable{}, &mockCmdable{}, &mockCmdable{})
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	lock.clients[1].cli, lock.clients[2].cli = faulty, faulty
+	err = lock.UnLock(ctx, "foo")
+	var ue *UnlockError
+	assert.True(t, errors.As(err, &ue))
+	assert.Equal(t, "foo", ue.Resource())
+	assert.Equal(t, []error{nil, errInjected, errInjected}, ue.Errs)
+}
+
+func TestMockClusterRedirect(t *testing.T) {
+	ctx := context.Background()
+	unlocks := int32(0)
+	healthy := func() *mockCmdable {
+		return &mockCmdable{
+			eval: func(context.Context, string, []string, ...interface{}) (interface{}, error) {
+				atomic.AddInt32(&unlocks, 1)
+				return int64(1), nil
+			},
+		}
+	}
+	for _, reply := range []string{"MOVED 3999 127.0.0.1:6381", "ASK 3999 127.0.0.1:6381"} {
+		reply := reply
+		node := &mockCmdable{
+			setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+				return false, errors.New(reply)
+			},
+		}
+		atomic.StoreInt32(&unlocks, 0)
+		lock := newMockRedLock(t, healthy(), node, healthy())
+		lock.SetRetryCount(3)
+		lock.SetRetryDelay(1)
+		_, err := lock.Lock(ctx, "foo", time.Second)
+		assert.True(t, errors.Is(err, ErrClusterRedirect), reply)
+		var ae *AcquireError
+		assert.True(t, errors.As(err, &ae), reply)
+		// fails fast without retry, the locked instances are released
+		assert.Equal(t, int32(2), atomic.LoadInt32(&unlocks), reply)
+	}
+}
@@ -0,0 +1,125 @@
+package redlock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MultiClusterLock acquires a lock across several independent RedLock
+// clusters, such as one per region, and requires the lock on a quorum of the
+// clusters, a quorum of quorums.
+//
+// The clusters are locked concurrently, so the acquisition costs the latency
+// of the slowest cluster, usually a cross-region round trip, rather than the
+// sum of them. During a partition the side holding less than quorum of
+// clusters can't acquire the lock, and a partition that splits the clusters
+// evenly makes the lock unavailable on both sides.
+type MultiClusterLock struct {
+	clusters []*RedLock
+	quorum   int
+}
+
+// NewMultiClusterLock creates a MultiClusterLock on clusters, requiring the
+// lock on quorum of them. A zero quorum requires all clusters, otherwise it
+// must be at least a majority of clusters to preserve the safety property.
+func NewMultiClusterLock(clusters []*RedLock, quorum int) (*MultiClusterLock, error) {
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("error redis cluster list: %d", len(clusters))
+	}
+	if quorum == 0 {
+		quorum = len(clusters)
+	}
+	if quorum <= len(clusters)/2 || quorum > len(clusters) {
+		return nil, fmt.Errorf("invalid cluster quorum %d, must be in [%d, %d]",
+			quorum, len(clusters)/2+1, len(clusters))
+	}
+	return &MultiClusterLock{clusters: clusters, quorum: quorum}, nil
+}
+
+// Lock acquires resource on all clusters concurrently, it returns the minimum
+// remaining validity across the clusters that acquired the lock. If fewer
+// than quorum clusters acquired the lock, it is released everywhere and a
+// QuorumError is returned, whose Errs are the per-cluster errors. If the
+// acquisition took so long that no validity remains, it is released as well
+// with ErrNoValidity.
+func (m *MultiClusterLock) Lock(ctx context.Context, resource string, ttl time.Duration) (time.Duration, error) {
+	var (
+		wg    sync.WaitGroup
+		locks = make([]*Lock, len(m.clusters))
+		errs  = make([]error, len(m.clusters))
+	)
+	for idx, cluster := range m.clusters {
+		idx, cluster := idx, cluster
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			locks[idx], errs[idx] = cluster.Acquire(ctx, resource, ttl)
+		}()
+	}
+	wg.Wait()
+
+	acquired := 0
+	var expiresAt time.Time
+	for _, l := range locks {
+		if l == nil {
+			continue
+		}
+		acquired++
+		// validity of each cluster counts from its own successful attempt
+		if deadline := l.acquiredAt.Add(l.validity); expiresAt.IsZero() || deadline.Before(expiresAt) {
+			expiresAt = deadline
+		}
+	}
+	if acquired < m.quorum {
+		m.unlock(context.Background(), locks)
+		return 0, &QuorumError{resource: resource, Acquired: acquired, Quorum: m.quorum, Errs: errs}
+	}
+	validity := time.Until(expiresAt)
+	if validity <= 0 {
+		m.unlock(context.Background(), locks)
+		return 0, &AcquireError{resource: resource, Err: ErrNoValidity}
+	}
+	return validity, nil
+}
+
+// UnLock releases resource on all clusters, it returns the first error of
+// the clusters.
+func (m *MultiClusterLock) UnLock(ctx context.Context, resource string) error {
+	errs := make([]error, len(m.clusters))
+	var wg sync.WaitGroup
+	for idx, cluster := range m.clusters {
+		idx, cluster := idx, cluster
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[idx] = cluster.UnLock(ctx, resource)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unlock releases the locks acquired on clusters, it is used to clean up a
+// failed acquisition, each cluster bounds the release by its unlock timeout
+func (m *MultiClusterLock) unlock(ctx context.Context, locks []*Lock) {
+	var wg sync.WaitGroup
+	for _, l := range locks {
+		if l == nil {
+			continue
+		}
+		l := l
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Unlock(ctx) // nolint:errcheck
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,82 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewMultiClusterLock(t *testing.T) {
+	clusters := make([]*RedLock, 3)
+	for i := range clusters {
+		clusters[i] = newMockRedLock(t, &mockCmdable{}, &mockCmdable{}, &mockCmdable{})
+	}
+	m, err := NewMultiClusterLock(clusters, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, m.quorum)
+	m, err = NewMultiClusterLock(clusters, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, m.quorum)
+	for _, quorum := range []int{1, 4, -1} {
+		_, err = NewMultiClusterLock(clusters, quorum)
+		assert.NotNil(t, err, quorum)
+	}
+	_, err = NewMultiClusterLock(nil, 0)
+	assert.NotNil(t, err)
+}
+
+func TestMockMultiClusterLock(t *testing.T) {
+	ctx := context.Background()
+	unlocks := int32(0)
+	healthy := func() *mockCmdable {
+		return &mockCmdable{
+			eval: func(context.Context, string, []string, ...interface{}) (interface{}, error) {
+				atomic.AddInt32(&unlocks, 1)
+				return int64(1), nil
+			},
+		}
+	}
+	down := &mockCmdable{
+		setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+			return false, errors.New("connection refused")
+		},
+	}
+	newCluster := func(cli redisCmdable) *RedLock {
+		lock := newMockRedLock(t, cli, cli, cli)
+		lock.SetRetryCount(1)
+		return lock
+	}
+
+	// a minority of clusters is down
+	m, err := NewMultiClusterLock([]*RedLock{newCluster(healthy()), newCluster(down), newCluster(healthy())}, 2)
+	assert.Nil(t, err)
+	validity, err := m.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(0))
+	assert.Less(t, int64(validity), int64(time.Second))
+	assert.True(t, m.clusters[0].Owns("foo"))
+	assert.True(t, m.clusters[2].Owns("foo"))
+	assert.Nil(t, m.UnLock(ctx, "foo"))
+	assert.Equal(t, int32(6), atomic.LoadInt32(&unlocks))
+	assert.False(t, m.clusters[0].Owns("foo"))
+
+	// requiring all clusters, the acquired clusters are released
+	atomic.StoreInt32(&unlocks, 0)
+	m, err = NewMultiClusterLock(m.clusters, 0)
+	assert.Nil(t, err)
+	_, err = m.Lock(ctx, "foo", time.Second)
+	var qe *QuorumError
+	assert.True(t, errors.As(err, &qe))
+	assert.True(t, errors.Is(err, ErrAcquireLock))
+	assert.Equal(t, 2, qe.Acquired)
+	assert.Equal(t, 3, qe.Quorum)
+	assert.Nil(t, qe.Errs[0])
+	assert.NotNil(t, qe.Errs[1])
+	assert.Equal(t, int32(6), atomic.LoadInt32(&unlocks))
+	assert.False(t, m.clusters[0].Owns("foo"))
+	assert.False(t, m.clusters[2].Owns("foo"))
+}
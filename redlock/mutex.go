@@ -0,0 +1,77 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DistMutex is a distributed mutex bound to a fixed resource and ttl. It is a
+// close analog of sync.Locker, but every method takes a context since the
+// lock is acquired and released over network.
+type DistMutex struct {
+	r        *RedLock
+	resource string
+	ttl      time.Duration
+
+	mu   sync.Mutex
+	lock *Lock
+}
+
+// NewMutex creates a DistMutex on resource with ttl
+func (r *RedLock) NewMutex(resource string, ttl time.Duration) *DistMutex {
+	return &DistMutex{
+		r:        r,
+		resource: resource,
+		ttl:      ttl,
+	}
+}
+
+// Lock acquires the mutex, retries the same as RedLock.Lock
+func (m *DistMutex) Lock(ctx context.Context) error {
+	l, err := m.r.Acquire(ctx, m.resource, m.ttl)
+	if err != nil {
+		return err
+	}
+	m.setLock(l)
+	return nil
+}
+
+// TryLock tries to acquire the mutex exactly once without retry, returns
+// false with nil error if the mutex is held by others.
+func (m *DistMutex) TryLock(ctx context.Context) (bool, error) {
+	val, err := m.r.newValue(ctx, m.ttl)
+	if err != nil {
+		return false, err
+	}
+	l, err := m.r.acquire(ctx, m.resource, m.ttl, val, noRetry, func(ctx context.Context, cli *RedClient) lockResult {
+		return m.r.lockInstance(ctx, cli, m.resource, val, m.ttl)
+	})
+	if errors.Is(err, ErrAcquireLock) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	m.setLock(l)
+	return true, nil
+}
+
+// Unlock releases the mutex, returns ErrLockNotHeld if it is not locked
+func (m *DistMutex) Unlock(ctx context.Context) error {
+	m.mu.Lock()
+	l := m.lock
+	m.lock = nil
+	m.mu.Unlock()
+	if l == nil {
+		return ErrLockNotHeld
+	}
+	return l.Unlock(ctx)
+}
+
+func (m *DistMutex) setLock(l *Lock) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lock = l
+}
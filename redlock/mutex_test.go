@@ -0,0 +1,37 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistMutex(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	lock.SetRetryCount(2)
+	lock.SetRetryDelay(10)
+	lock2, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+
+	m := lock.NewMutex("foo", time.Second)
+	m2 := lock2.NewMutex("foo", time.Second)
+	assert.Equal(t, ErrLockNotHeld, m.Unlock(ctx))
+
+	assert.Nil(t, m.Lock(ctx))
+	ok, err := m2.TryLock(ctx)
+	assert.False(t, ok)
+	assert.Nil(t, err)
+	assert.Nil(t, m.Unlock(ctx))
+
+	ok, err = m2.TryLock(ctx)
+	assert.True(t, ok)
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(m.Lock(ctx), ErrAcquireLock))
+	assert.Nil(t, m2.Unlock(ctx))
+	assert.Equal(t, ErrLockNotHeld, m2.Unlock(ctx))
+}
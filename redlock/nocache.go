@@ -0,0 +1,64 @@
+package redlock
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// NoCache is a KVCache that keeps nothing, Set is a no-op and Get always
+// returns nil, it is used by WithNoCache.
+type NoCache struct{}
+
+// Set implements KVCache.Set, the element is returned but not kept
+func (NoCache) Set(key, val string, expiry int64) (*LockElem, error) {
+	return newLockElem(val, expiry), nil
+}
+
+// Get implements KVCache.Get
+func (NoCache) Get(key string) (*LockElem, error) {
+	return nil, nil
+}
+
+// Delete implements KVCache.Delete
+func (NoCache) Delete(key string) {}
+
+// Size implements KVCache.Size
+func (NoCache) Size() int {
+	return 0
+}
+
+// WithNoCache disables the local cache, which saves its memory and the gc
+// goroutine, it is the same as WithCacheType(CacheTypeNone). The value of a
+// lock is then never looked up by resource, so caller keeps it explicitly:
+// acquire by Acquire and release by the Unlock of its handle, or by
+// UnlockByValue with Lock.Value. The operations taking only a resource, such
+// as UnLock, Extend, Owns and ReleaseAll, find no lock held and do nothing,
+// and re-entrancy is not detected.
+func WithNoCache() CacheOption {
+	return WithCacheType(CacheTypeNone)
+}
+
+// UnlockByValue releases the lock of resource if it is held with val, no
+// matter whether the lock is in the local cache, which is removed if it has
+// the same value. It is how locks are released without cache, see
+// WithNoCache. Unlike UnLock, it doesn't count the local holders shared by
+// WithLocalSingleflight. The lock is released even if the local cache fails,
+// which is how a lock is released after UnLock returns a CacheError.
+func (r *RedLock) UnlockByValue(ctx context.Context, resource, val string) error {
+	if err := r.checkResource(resource); err != nil {
+		return err
+	}
+	// without the expiry, the background retries of unlock run to the end
+	expiresAt := time.Now().Add(unlockRetryDelay << unlockRetryAttempts)
+	elem, err := r.cache.Get(resource)
+	if err != nil {
+		log.Printf("redlock: failed to look up lock %s in cache, releasing it by value: %v", resource, err)
+	} else if elem != nil && elem.Val == val {
+		expiresAt = time.Now().Add(time.Duration(elem.remaining()))
+		defer r.holdCounts.clear(resource)
+		defer r.cache.Delete(resource)
+		defer r.heldTTLs.delete(resource)
+	}
+	return r.unlockValue(ctx, resource, val, expiresAt)
+}
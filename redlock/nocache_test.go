@@ -0,0 +1,59 @@
+package redlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoCache(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithNoCache(), WithRetryCount(1))
+	assert.Nil(t, err)
+	defer lock.Close()
+	assert.IsType(t, NoCache{}, lock.cache)
+
+	l, err := lock.Acquire(ctx, "nocache-foo", time.Second)
+	assert.Nil(t, err)
+	assert.False(t, lock.Owns("nocache-foo"))
+	assert.Equal(t, 0, lock.cache.Size())
+	// the lock is released only with its value
+	assert.Nil(t, lock.UnLock(ctx, "nocache-foo"))
+	_, err = lock.Acquire(ctx, "nocache-foo", time.Second)
+	assert.NotNil(t, err)
+	assert.Nil(t, l.Unlock(ctx))
+	values, err := lock.Inspect(ctx, "nocache-foo")
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+
+	l, err = lock.Acquire(ctx, "nocache-foo", time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnlockByValue(ctx, "nocache-foo", "others"))
+	values, err = lock.Inspect(ctx, "nocache-foo")
+	assert.Nil(t, err)
+	assert.Len(t, values, len(redisServers))
+	assert.Nil(t, lock.UnlockByValue(ctx, "nocache-foo", l.Value()))
+	values, err = lock.Inspect(ctx, "nocache-foo")
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+}
+
+func TestUnlockByValue(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	l, err := lock.Acquire(ctx, "unlock-by-value", time.Second)
+	assert.Nil(t, err)
+	// a different value leaves the lock and its cache entry
+	assert.Nil(t, lock.UnlockByValue(ctx, "unlock-by-value", "others"))
+	assert.True(t, lock.Owns("unlock-by-value"))
+	assert.Nil(t, lock.UnlockByValue(ctx, "unlock-by-value", l.Value()))
+	assert.False(t, lock.Owns("unlock-by-value"))
+	values, err := lock.Inspect(ctx, "unlock-by-value")
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+}
@@ -0,0 +1,95 @@
+package redlock
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// ExtendNoShortenScript is redis lua script to reset the ttl of a lock
+	// held by us only if the new ttl is longer than the remaining one, along
+	// with the lease metadata given as KEYS[2]. It returns the effective ttl
+	// in milliseconds, or -1 if the lock is not held by us.
+	ExtendNoShortenScript = `
+        if redis.call("get", KEYS[1]) ~= ARGV[1] then
+            return -1
+        end
+        local pttl = redis.call("pttl", KEYS[1])
+        if pttl >= tonumber(ARGV[2]) then
+            return pttl
+        end
+        if KEYS[2] and redis.call("hget", KEYS[2], "value") == ARGV[1] then
+            redis.call("hset", KEYS[2], "ttl", ARGV[2])
+            redis.call("pexpire", KEYS[2], ARGV[2])
+        end
+        redis.call("pexpire", KEYS[1], ARGV[2])
+        return tonumber(ARGV[2])
+        `
+)
+
+var extendNoShortenScript = newLuaScript(ExtendNoShortenScript)
+
+// ExtendNoShorten extends an acquired lock like Extend, except that the ttl
+// of each instance is reset only if ttl is longer than its remaining ttl, so
+// a late renewal with a smaller ttl never shortens the lock. The check and
+// the reset are executed atomically on each instance. It returns the
+// remaining valid duration by the effective ttl, which is ttl on the
+// instances extended, or the remaining ttl on the others, the lock is held
+// for it on quorum. A LockStore other than redis doesn't support it.
+func (r *RedLock) ExtendNoShorten(ctx context.Context, resource string, ttl time.Duration) (time.Duration, error) {
+	if err := r.checkResource(resource); err != nil {
+		return 0, err
+	}
+	if err := r.checkTTL(ttl); err != nil {
+		return 0, err
+	}
+	elem, err := r.cache.Get(resource)
+	if err != nil {
+		return 0, err
+	}
+	if elem == nil {
+		return 0, ErrLockNotHeld
+	}
+
+	start := time.Now()
+	cctx, cancel := context.WithTimeout(ctx, r.instanceWait(ttl))
+	defer cancel()
+	key := r.redisKey(resource)
+	keys := append([]string{key}, r.companionKeys(key)...)
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		ttls = make([]time.Duration, 0, len(r.clients))
+	)
+	for _, cli := range r.clients {
+		cli := cli
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ms, err := extendNoShortenScript.run(cctx, cli.cli, keys, elem.Val, formatMs(ttl)).Int64()
+			if err != nil || ms < 0 {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			ttls = append(ttls, time.Duration(ms)*time.Millisecond)
+		}()
+	}
+	wg.Wait()
+	if len(ttls) < r.quorum {
+		return 0, ErrExtendLock
+	}
+	// the lock lasts at least the quorum-th longest ttl on quorum
+	sort.Slice(ttls, func(i, j int) bool { return ttls[i] > ttls[j] })
+	effective := ttls[r.quorum-1]
+	validityTime := r.validity(effective, start)
+	if validityTime <= 0 {
+		return 0, ErrExtendLock
+	}
+	r.cache.Set(resource, elem.Val, validityTime)
+	r.heldTTLs.set(resource, effective)
+	r.emit(EventRenewed, resource, time.Duration(validityTime), nil)
+	return time.Duration(validityTime), nil
+}
@@ -0,0 +1,87 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtendNoShorten(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithLeaseMetadata())
+	assert.Nil(t, err)
+	defer lock.Close()
+	pttls := func() []time.Duration {
+		ttls := make([]time.Duration, 0, len(lock.clients))
+		for _, cli := range lock.clients {
+			ttls = append(ttls, rawClient(cli).PTTL(ctx, "noshorten-foo").Val())
+		}
+		return ttls
+	}
+
+	_, err = lock.ExtendNoShorten(ctx, "noshorten-foo", time.Second)
+	assert.Equal(t, ErrLockNotHeld, err)
+	_, err = lock.Lock(ctx, "noshorten-foo", 2*time.Second)
+	assert.Nil(t, err)
+	defer lock.UnLock(ctx, "noshorten-foo") // nolint:errcheck
+
+	// a longer ttl extends the lock
+	validity, err := lock.ExtendNoShorten(ctx, "noshorten-foo", 5*time.Second)
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(4*time.Second))
+	assert.LessOrEqual(t, int64(validity), int64(5*time.Second))
+	for _, ttl := range pttls() {
+		assert.Greater(t, int64(ttl), int64(4*time.Second))
+	}
+	leases, err := lock.InspectLease(ctx, "noshorten-foo")
+	assert.Nil(t, err)
+	for _, lease := range leases {
+		assert.Equal(t, 5*time.Second, lease.TTL)
+	}
+
+	// a shorter ttl is rejected, the remaining ttl is effective
+	validity, err = lock.ExtendNoShorten(ctx, "noshorten-foo", time.Second)
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(4*time.Second))
+	for _, ttl := range pttls() {
+		assert.Greater(t, int64(ttl), int64(4*time.Second))
+	}
+	leases, err = lock.InspectLease(ctx, "noshorten-foo")
+	assert.Nil(t, err)
+	for _, lease := range leases {
+		assert.Equal(t, 5*time.Second, lease.TTL)
+	}
+	elem, err := lock.cache.Get("noshorten-foo")
+	assert.Nil(t, err)
+	assert.Greater(t, elem.remaining(), int64(4*time.Second))
+}
+
+func TestMockExtendNoShorten(t *testing.T) {
+	ctx := context.Background()
+	reply := func(ms int64, err error) *mockCmdable {
+		return &mockCmdable{
+			eval: func(context.Context, string, []string, ...interface{}) (interface{}, error) {
+				return ms, err
+			},
+		}
+	}
+
+	// the quorum-th longest ttl is effective
+	lock := newMockRedLock(t, reply(9000, nil), reply(1000, nil), reply(3000, nil))
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	validity, err := lock.ExtendNoShorten(ctx, "foo", 2*time.Second)
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(2*time.Second))
+	assert.LessOrEqual(t, int64(validity), int64(3*time.Second))
+
+	// the lock not held on quorum is not extended
+	lock = newMockRedLock(t, reply(-1, nil), reply(3000, nil), reply(0, errors.New("injected error")))
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	_, err = lock.ExtendNoShorten(ctx, "foo", 2*time.Second)
+	assert.Equal(t, ErrExtendLock, err)
+}
@@ -0,0 +1,66 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrUpdateConflict means an optimistic update kept conflicting with
+// concurrent writes of the key after max retry time
+var ErrUpdateConflict = errors.New("optimistic update conflicted")
+
+// OptimisticUpdate updates key by fn without taking a lock. It WATCHes key,
+// passes its current value to fn, an empty string if key doesn't exist, and
+// sets key to the value returned by fn in a MULTI/EXEC transaction. If key is
+// modified by others before EXEC, the transaction is discarded and the update
+// is retried after a random delay, as the retry strategy of RedLock decides, then
+// ErrUpdateConflict is returned. An error returned by fn aborts the update
+// and is returned as is, so fn may be called several times and must be free
+// of side effects.
+//
+// It suits short updates of low contention, under high contention most
+// attempts are wasted and Lock is preferable. A transaction can't span
+// independent instances, so the update is applied on the first redis server
+// only, it has none of the fault tolerance of Redlock. The key is used as is,
+// without hash tag or key hasher, and any expiration of it is cleared.
+func (r *RedLock) OptimisticUpdate(ctx context.Context, key string, fn func(old string) (string, error)) error {
+	cli := r.clients[0]
+	txf := func(tx *redis.Tx) error {
+		old, err := tx.Get(ctx, key).Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		val, err := fn(old)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, val, 0)
+			return nil
+		})
+		return err
+	}
+	retry := r.retry()
+	begin := time.Now()
+	for i := 0; ; i++ {
+		err := cli.cli.Watch(ctx, txf, key)
+		if err != redis.TxFailedErr {
+			return err
+		}
+		delay, ok := retry.Next(i+1, time.Since(begin))
+		if !ok {
+			break
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return ErrUpdateConflict
+}
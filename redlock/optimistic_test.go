@@ -0,0 +1,61 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptimisticUpdate(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithRetryCount(1000))
+	assert.Nil(t, err)
+	key := "optimistic_counter"
+	cli := rawClient(lock.clients[0])
+	cli.Del(ctx, key)
+	defer cli.Del(ctx, key)
+
+	incr := func(old string) (string, error) {
+		n, _ := strconv.Atoi(old)
+		return strconv.Itoa(n + 1), nil
+	}
+	routines, inc := 5, 20
+	var wg sync.WaitGroup
+	for i := 0; i < routines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < inc; j++ {
+				assert.Nil(t, lock.OptimisticUpdate(ctx, key, incr))
+			}
+		}()
+	}
+	wg.Wait()
+	val, err := cli.Get(ctx, key).Result()
+	assert.Nil(t, err)
+	assert.Equal(t, strconv.Itoa(routines*inc), val)
+
+	// error of fn aborts the update
+	errAbort := errors.New("abort")
+	err = lock.OptimisticUpdate(ctx, key, func(old string) (string, error) {
+		return "", errAbort
+	})
+	assert.Equal(t, errAbort, err)
+	val, _ = cli.Get(ctx, key).Result()
+	assert.Equal(t, strconv.Itoa(routines*inc), val)
+
+	// key modified within every attempt always conflicts
+	lock.SetRetryCount(2)
+	attempts := 0
+	err = lock.OptimisticUpdate(ctx, key, func(old string) (string, error) {
+		attempts++
+		cli.Set(ctx, key, old+"x", 0)
+		return old, nil
+	})
+	assert.Equal(t, ErrUpdateConflict, err)
+	assert.Equal(t, 2, attempts)
+}
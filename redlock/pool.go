@@ -0,0 +1,125 @@
+package redlock
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// lockPool holds the Lock handles released by PooledLock.Unlock for reuse
+var lockPool = sync.Pool{
+	New: func() interface{} {
+		return new(Lock)
+	},
+}
+
+// newLock returns an empty Lock, reusing a released handle if any
+func newLock() *Lock {
+	return lockPool.Get().(*Lock)
+}
+
+// recycle clears l and puts it back to the pool, the generation is kept so
+// the stale PooledLock of l stays invalid
+func (l *Lock) recycle() {
+	gen := atomic.LoadUint64(&l.gen)
+	holders := l.holders[:0]
+	*l = Lock{}
+	l.gen = gen
+	l.holders = holders
+	lockPool.Put(l)
+}
+
+// PooledLock is a handle of an acquired lock backed by a pool, returned by
+// AcquirePooled. Unlike *Lock it is a value carrying the generation of the
+// underlying handle, which is put back to the pool and reused on Unlock, so
+// high churn acquisition doesn't allocate a handle each time. Once unlocked,
+// every copy of the PooledLock is invalid, its methods return zero values or
+// ErrLockNotHeld even after the underlying handle is reused by another lock.
+// A PooledLock must not be used concurrently with its Unlock.
+type PooledLock struct {
+	l   *Lock
+	gen uint64
+}
+
+// AcquirePooled acquires a lock like Acquire, the handle is returned to the
+// pool on Unlock. With WithLocalSingleflight the handle is shared by the
+// local holders of the lock, it is then never pooled and stays valid after
+// Unlock like *Lock.
+func (r *RedLock) AcquirePooled(ctx context.Context, resource string, ttl time.Duration) (PooledLock, error) {
+	l, err := r.Acquire(ctx, resource, ttl)
+	if err != nil {
+		return PooledLock{}, err
+	}
+	return PooledLock{l: l, gen: atomic.LoadUint64(&l.gen)}, nil
+}
+
+// lock returns the underlying handle, or nil if p is released
+func (p PooledLock) lock() *Lock {
+	if p.l == nil || atomic.LoadUint64(&p.l.gen) != p.gen {
+		return nil
+	}
+	return p.l
+}
+
+// Valid returns whether p is not released yet
+func (p PooledLock) Valid() bool {
+	return p.lock() != nil
+}
+
+// Resource returns the resource name of the lock, empty if p is released
+func (p PooledLock) Resource() string {
+	if l := p.lock(); l != nil {
+		return l.resource
+	}
+	return ""
+}
+
+// Value returns the random value of the lock, empty if p is released
+func (p PooledLock) Value() string {
+	if l := p.lock(); l != nil {
+		return l.val
+	}
+	return ""
+}
+
+// Validity returns the validity of the lock when acquired or last extended,
+// zero if p is released
+func (p PooledLock) Validity() time.Duration {
+	if l := p.lock(); l != nil {
+		return l.Validity()
+	}
+	return 0
+}
+
+// ExtendTo resets the ttl of the lock, see Lock.ExtendTo
+func (p PooledLock) ExtendTo(ctx context.Context, ttl time.Duration) (time.Duration, error) {
+	l := p.lock()
+	if l == nil {
+		return 0, ErrLockNotHeld
+	}
+	return l.ExtendTo(ctx, ttl)
+}
+
+// Unlock releases the lock and puts the handle back to the pool, it returns
+// ErrLockNotHeld if p is already released. If the release fails, the handle
+// is kept and p stays valid, so Unlock can be retried.
+func (p PooledLock) Unlock(ctx context.Context) error {
+	l := p.lock()
+	if l == nil {
+		return ErrLockNotHeld
+	}
+	r := l.r
+	if r.flight != nil {
+		return l.Unlock(ctx)
+	}
+	if !atomic.CompareAndSwapUint64(&l.gen, p.gen, p.gen+1) {
+		return ErrLockNotHeld
+	}
+	if err := l.Unlock(ctx); err != nil {
+		atomic.CompareAndSwapUint64(&l.gen, p.gen+1, p.gen)
+		return err
+	}
+	l.recycle()
+	return nil
+}
@@ -0,0 +1,80 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockPooledLock(t *testing.T) {
+	ctx := context.Background()
+	lock := newMockRedLock(t, &mockCmdable{}, &mockCmdable{}, &mockCmdable{})
+
+	p, err := lock.AcquirePooled(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.True(t, p.Valid())
+	assert.Equal(t, "foo", p.Resource())
+	assert.NotEmpty(t, p.Value())
+	assert.Greater(t, int64(p.Validity()), int64(0))
+	validity, err := p.ExtendTo(ctx, 2*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, validity, p.Validity())
+
+	stale := p
+	assert.Nil(t, p.Unlock(ctx))
+	assert.False(t, lock.Owns("foo"))
+
+	// every copy is invalid once unlocked, even if the handle is reused
+	for _, h := range []PooledLock{p, stale} {
+		assert.False(t, h.Valid())
+		assert.Empty(t, h.Resource())
+		assert.Empty(t, h.Value())
+		assert.Equal(t, time.Duration(0), h.Validity())
+		_, err = h.ExtendTo(ctx, time.Second)
+		assert.Equal(t, ErrLockNotHeld, err)
+		assert.Equal(t, ErrLockNotHeld, h.Unlock(ctx))
+	}
+	assert.Equal(t, p.gen+1, atomic.LoadUint64(&p.l.gen))
+	assert.False(t, PooledLock{}.Valid())
+
+	// with singleflight the shared handle is never pooled, it stays valid
+	// like *Lock
+	lock = newMockRedLockWithOptions(t, []redisCmdable{&mockCmdable{}}, WithLocalSingleflight())
+	p, err = lock.AcquirePooled(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, p.Unlock(ctx))
+	assert.True(t, p.Valid())
+	assert.False(t, lock.Owns("foo"))
+}
+
+func TestMockPooledLockRetryUnlock(t *testing.T) {
+	ctx := context.Background()
+	var failing int32 = 1
+	instance := func() *mockCmdable {
+		return &mockCmdable{
+			eval: func(context.Context, string, []string, ...interface{}) (interface{}, error) {
+				if atomic.LoadInt32(&failing) == 1 {
+					return nil, errors.New("injected error")
+				}
+				return int64(1), nil
+			},
+		}
+	}
+	lock := newMockRedLock(t, instance(), instance(), instance())
+
+	p, err := lock.AcquirePooled(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	var ue *UnlockError
+	assert.True(t, errors.As(p.Unlock(ctx), &ue))
+	// the handle is kept for the retry
+	assert.True(t, p.Valid())
+	assert.True(t, lock.Owns("foo"))
+	atomic.StoreInt32(&failing, 0)
+	assert.Nil(t, p.Unlock(ctx))
+	assert.False(t, p.Valid())
+	assert.False(t, lock.Owns("foo"))
+}
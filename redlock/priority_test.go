@@ -0,0 +1,95 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriorityOf(t *testing.T) {
+	testCases := []struct {
+		val      string
+		priority int
+		ok       bool
+	}{
+		{"token|3", 3, true},
+		{"owner:token|-1", -1, true},
+		{"token", 0, false},
+		{"token|", 0, false},
+		{"token|high", 0, false},
+	}
+	for _, tc := range testCases {
+		p, ok := PriorityOf(tc.val)
+		assert.Equal(t, tc.priority, p, tc.val)
+		assert.Equal(t, tc.ok, ok, tc.val)
+	}
+}
+
+func TestLockWithPriority(t *testing.T) {
+	ctx := context.Background()
+	low, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	high, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	high.SetRetryCount(1)
+
+	_, err = low.LockWithPriority(ctx, "foo", time.Second, 1)
+	assert.Nil(t, err)
+	// the same priority can't preempt
+	_, err = high.LockWithPriority(ctx, "foo", time.Second, 1)
+	assert.True(t, errors.Is(err, ErrAcquireLock))
+
+	// the higher priority preempts, the holder learns of it on Verify and renewal
+	validity, err := high.LockWithPriority(ctx, "foo", time.Second, 2)
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(0))
+	held, _, err := low.Verify(ctx, "foo")
+	assert.Nil(t, err)
+	assert.False(t, held)
+	_, err = low.Extend(ctx, "foo", time.Second)
+	assert.Equal(t, ErrExtendLock, err)
+	// the preempted holder can't release the lock of preemptor
+	assert.Nil(t, low.UnLock(ctx, "foo"))
+	held, _, err = high.Verify(ctx, "foo")
+	assert.Nil(t, err)
+	assert.True(t, held)
+	assert.Nil(t, high.UnLock(ctx, "foo"))
+
+	// a lock without priority is never preempted
+	_, err = low.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	_, err = high.LockWithPriority(ctx, "foo", time.Second, 100)
+	assert.True(t, errors.Is(err, ErrAcquireLock))
+	assert.Nil(t, low.UnLock(ctx, "foo"))
+}
+
+func TestMockLockWithPriority(t *testing.T) {
+	ctx := context.Background()
+	reply := func(n int64) *mockCmdable {
+		return &mockCmdable{
+			eval: func(_ context.Context, script string, _ []string, _ ...interface{}) (interface{}, error) {
+				if script == PriorityLockScript {
+					return n, nil
+				}
+				return int64(1), nil
+			},
+		}
+	}
+	// acquired on an instance and preempted on another
+	lock := newMockRedLock(t, reply(1), reply(2), reply(0))
+	_, err := lock.LockWithPriority(ctx, "foo", time.Second, 5)
+	assert.Nil(t, err)
+	elem, err := lock.cache.Get("foo")
+	assert.Nil(t, err)
+	p, ok := PriorityOf(elem.Val)
+	assert.True(t, ok)
+	assert.Equal(t, 5, p)
+
+	lock = newMockRedLock(t, reply(2), reply(0), reply(0))
+	lock.SetRetryCount(1)
+	_, err = lock.LockWithPriority(ctx, "foo", time.Second, 5)
+	assert.True(t, errors.Is(err, ErrAcquireLock))
+}
@@ -0,0 +1,85 @@
+package redlock
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrRateLimited means an acquisition attempt exceeded the rate of its resource
+var ErrRateLimited = errors.New("lock acquisition rate limited")
+
+// WithPerResourceRate limits how often each resource is attempted to be
+// locked on redis, with a token bucket of limit attempts per second and
+// burst per resource. Every attempt takes a token, including the retries, so
+// unlike retry backoff it bounds the total attempt frequency of a resource
+// however many Lock calls are issued. An acquisition fails fast with
+// ErrRateLimited when its attempt is not allowed. The limit is local to the
+// RedLock, it protects redis from a pathological caller of this process.
+func WithPerResourceRate(limit rate.Limit, burst int) LockOption {
+	return func(r *RedLock) {
+		if limit <= 0 || burst <= 0 {
+			r.setOptErr(fmt.Errorf("invalid per resource rate %v with burst %d, must be positive", limit, burst))
+			return
+		}
+		r.resourceRate = &resourceLimiters{
+			limit:    limit,
+			burst:    burst,
+			limiters: make(map[string]*resourceLimiter),
+		}
+	}
+}
+
+// resourceLimiter is the token bucket of a resource
+type resourceLimiter struct {
+	lim      *rate.Limiter
+	lastUsed time.Time
+}
+
+// resourceLimiters holds the token buckets of resources, a bucket is dropped
+// once it is idle long enough to be refilled, which is the same as a new one
+type resourceLimiters struct {
+	sync.Mutex
+	limit     rate.Limit
+	burst     int
+	limiters  map[string]*resourceLimiter
+	sweepSize int
+}
+
+// allow takes a token of resource, returns whether the attempt is allowed
+func (l *resourceLimiters) allow(resource string) bool {
+	if l == nil {
+		return true
+	}
+	l.Lock()
+	defer l.Unlock()
+	now := time.Now()
+	if len(l.limiters) > l.sweepSize {
+		l.sweep(now)
+	}
+	rl, ok := l.limiters[resource]
+	if !ok {
+		rl = &resourceLimiter{lim: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[resource] = rl
+	}
+	rl.lastUsed = now
+	return rl.lim.AllowN(now, 1)
+}
+
+// sweep drops the buckets idle long enough to be refilled, it is run when
+// the buckets double since last sweep, so its cost is amortized
+func (l *resourceLimiters) sweep(now time.Time) {
+	refill := time.Duration(float64(l.burst) / float64(l.limit) * float64(time.Second))
+	for resource, rl := range l.limiters {
+		if now.Sub(rl.lastUsed) >= refill {
+			delete(l.limiters, resource)
+		}
+	}
+	l.sweepSize = 2 * len(l.limiters)
+	if l.sweepSize < 64 {
+		l.sweepSize = 64
+	}
+}
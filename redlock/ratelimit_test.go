@@ -0,0 +1,79 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+func TestMockPerResourceRate(t *testing.T) {
+	ctx := context.Background()
+	_, err := NewRedLock(ctx, []string{"tcp://mock0:6379"}, WithPerResourceRate(0, 1))
+	assert.NotNil(t, err)
+	_, err = NewRedLock(ctx, []string{"tcp://mock0:6379"}, WithPerResourceRate(1, 0))
+	assert.NotNil(t, err)
+
+	var attempts int32
+	instance := func() *mockCmdable {
+		return &mockCmdable{
+			setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+				atomic.AddInt32(&attempts, 1)
+				return true, nil
+			},
+		}
+	}
+	lock := newMockRedLockWithOptions(t, []redisCmdable{instance(), instance(), instance()},
+		WithPerResourceRate(rate.Every(time.Hour), 2))
+	for i := 0; i < 2; i++ {
+		_, err = lock.Lock(ctx, "foo", time.Second)
+		assert.Nil(t, err)
+		assert.Nil(t, lock.UnLock(ctx, "foo"))
+	}
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	var ae *AcquireError
+	assert.True(t, errors.As(err, &ae))
+	assert.Equal(t, "foo", ae.Resource())
+	// the limited acquisition never reaches redis
+	assert.Equal(t, int32(6), atomic.LoadInt32(&attempts))
+
+	// other resources are limited separately
+	_, err = lock.Lock(ctx, "bar", time.Second)
+	assert.Nil(t, err)
+}
+
+func TestMockPerResourceRateRetries(t *testing.T) {
+	ctx := context.Background()
+	var attempts int32
+	held := &mockCmdable{
+		setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+			atomic.AddInt32(&attempts, 1)
+			return false, nil
+		},
+	}
+	lock := newMockRedLockWithOptions(t, []redisCmdable{held, held, &mockCmdable{}},
+		WithRetryCount(10), WithRetryDelay(1), WithPerResourceRate(rate.Every(time.Hour), 3))
+	// retries take tokens as well
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	assert.True(t, errors.Is(err, ErrRateLimited))
+	assert.Equal(t, int32(6), atomic.LoadInt32(&attempts))
+}
+
+func TestResourceLimitersSweep(t *testing.T) {
+	l := &resourceLimiters{limit: rate.Every(time.Millisecond), burst: 1, limiters: make(map[string]*resourceLimiter)}
+	for i := 0; i < 100; i++ {
+		assert.True(t, l.allow(string(rune('a'+i))))
+	}
+	assert.True(t, l.allow("foo"))
+	// idle buckets are refilled, dropping them is the same as keeping them
+	time.Sleep(5 * time.Millisecond)
+	l.sweep(time.Now())
+	assert.Empty(t, l.limiters)
+	assert.Equal(t, 64, l.sweepSize)
+	assert.True(t, l.allow("foo"))
+}
@@ -0,0 +1,112 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrLockHeld means the lock is held by others with a different value
+var ErrLockHeld = errors.New("lock is held by others")
+
+// ExtendOrReacquire extends the lock of resource held by r like Extend, and
+// on the instances where the lock is already gone, such as renewal ran later
+// than the ttl, it is set again with the same value, so a narrow renewal gap
+// doesn't lose the lock. Returns the new validity if the lock is extended or
+// reacquired on quorum, ErrLockHeld if the lock is held by others on any
+// instance and quorum is not reached, or ErrExtendLock otherwise. The value
+// is looked up in the local cache, which drops it once the validity passes,
+// use Lock.ExtendOrReacquire to recover a lock after that.
+func (r *RedLock) ExtendOrReacquire(ctx context.Context, resource string, ttl time.Duration) (time.Duration, error) {
+	if err := r.checkResource(resource); err != nil {
+		return 0, err
+	}
+	if err := r.checkTTL(ttl); err != nil {
+		return 0, err
+	}
+	elem, err := r.cache.Get(resource)
+	if err != nil {
+		return 0, err
+	}
+	if elem == nil {
+		return 0, ErrLockNotHeld
+	}
+	return r.extendOrReacquire(ctx, resource, elem.Val, ttl)
+}
+
+// ExtendOrReacquire extends the lock to ttl, or reacquires it with the same
+// value where it is gone, see RedLock.ExtendOrReacquire. Unlike RedLock's, it
+// works after the validity of the lock passed since the value is kept by l.
+func (l *Lock) ExtendOrReacquire(ctx context.Context, ttl time.Duration) (time.Duration, error) {
+	if err := l.r.checkTTL(ttl); err != nil {
+		return 0, err
+	}
+	validity, err := l.r.extendOrReacquire(ctx, l.resource, l.val, ttl)
+	if err != nil {
+		return 0, err
+	}
+	l.mu.Lock()
+	l.ttl = ttl
+	l.validity = validity
+	l.mu.Unlock()
+	return validity, nil
+}
+
+func (r *RedLock) extendOrReacquire(ctx context.Context, resource, val string, ttl time.Duration) (time.Duration, error) {
+	start := time.Now()
+	cctx, cancel := context.WithTimeout(ctx, r.instanceWait(ttl))
+	defer cancel()
+	var (
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		success    int
+		held       int
+		reacquired []*RedClient
+	)
+	for _, cli := range r.clients {
+		cli := cli
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if extended, err := r.extendInstance(cctx, cli, resource, val, ttl); err != nil {
+				return
+			} else if extended {
+				mu.Lock()
+				success++
+				mu.Unlock()
+				return
+			}
+			// the lock is not ours on this instance, it is set only if gone
+			res := r.lockInstance(cctx, cli, resource, val, ttl)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case res.locked:
+				success++
+				reacquired = append(reacquired, cli)
+			case res.err == nil:
+				held++
+			}
+		}()
+	}
+	wg.Wait()
+	validityTime := r.validity(ttl, start)
+	if success >= r.quorum && validityTime > 0 {
+		r.cache.Set(resource, val, validityTime)
+		r.heldTTLs.set(resource, ttl)
+		r.emit(EventRenewed, resource, time.Duration(validityTime), nil)
+		return time.Duration(validityTime), nil
+	}
+	// release the locks just set, the instances still held by us are left
+	// as Extend does
+	for _, cli := range reacquired {
+		uctx, ucancel := context.WithTimeout(context.Background(), r.unlockTimeout)
+		_, _ = r.unlockInstance(uctx, cli, resource, val)
+		ucancel()
+	}
+	if held > 0 {
+		return 0, ErrLockHeld
+	}
+	return 0, ErrExtendLock
+}
@@ -0,0 +1,88 @@
+package redlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtendOrReacquire(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	_, err = lock.ExtendOrReacquire(ctx, "reacquire", time.Second)
+	assert.Equal(t, ErrLockNotHeld, err)
+
+	// extended where the lock is still held
+	_, err = lock.Lock(ctx, "reacquire", time.Second)
+	assert.Nil(t, err)
+	validity, err := lock.ExtendOrReacquire(ctx, "reacquire", 3*time.Second)
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(time.Second))
+	for _, cli := range lock.clients {
+		pttl, err := rawClient(cli).PTTL(ctx, "reacquire").Result()
+		assert.Nil(t, err)
+		assert.Greater(t, int64(pttl), int64(time.Second))
+	}
+
+	// reacquired with the same value where the lock is gone
+	elem, err := lock.cache.Get("reacquire")
+	assert.Nil(t, err)
+	for _, cli := range lock.clients[:2] {
+		assert.Nil(t, rawClient(cli).Del(ctx, "reacquire").Err())
+	}
+	_, err = lock.ExtendOrReacquire(ctx, "reacquire", time.Second)
+	assert.Nil(t, err)
+	for _, cli := range lock.clients {
+		v, err := rawClient(cli).Get(ctx, "reacquire").Result()
+		assert.Nil(t, err)
+		assert.Equal(t, elem.Val, v)
+	}
+
+	// held by others with a different value
+	for _, cli := range lock.clients {
+		assert.Nil(t, rawClient(cli).Set(ctx, "reacquire", "others", time.Second).Err())
+	}
+	_, err = lock.ExtendOrReacquire(ctx, "reacquire", time.Second)
+	assert.Equal(t, ErrLockHeld, err)
+	for _, cli := range lock.clients {
+		assert.Nil(t, rawClient(cli).Del(ctx, "reacquire").Err())
+	}
+}
+
+func TestLockExtendOrReacquire(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	l, err := lock.Acquire(ctx, "reacquire-expired", 100*time.Millisecond)
+	assert.Nil(t, err)
+	time.Sleep(200 * time.Millisecond)
+	assert.False(t, lock.Owns("reacquire-expired"))
+
+	// the lock expired, it is recovered by the value kept in l
+	validity, err := l.ExtendOrReacquire(ctx, time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, validity, l.Validity())
+	assert.Equal(t, time.Second, l.TTL())
+	assert.True(t, lock.Owns("reacquire-expired"))
+
+	// the lock is held by others on a majority, the reacquired instance is
+	// released on failure
+	for _, cli := range lock.clients {
+		assert.Nil(t, rawClient(cli).Del(ctx, "reacquire-expired").Err())
+	}
+	for _, cli := range lock.clients[:2] {
+		assert.Nil(t, rawClient(cli).Set(ctx, "reacquire-expired", "others", time.Second).Err())
+	}
+	_, err = l.ExtendOrReacquire(ctx, time.Second)
+	assert.Equal(t, ErrLockHeld, err)
+	n, err := rawClient(lock.clients[2]).Exists(ctx, "reacquire-expired").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+}
@@ -0,0 +1,147 @@
+package redlock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// cacheRanger is implemented by the caches that can enumerate their elements
+type cacheRanger interface {
+	// rangeElems calls fn with each element not expired yet
+	rangeElems(fn func(key string, elem *LockElem))
+}
+
+// rangeElems implements cacheRanger
+func (sc *SimpleCache) rangeElems(fn func(key string, elem *LockElem)) {
+	sc.lock.RLock()
+	elems := make(map[string]*LockElem, len(sc.kvs))
+	for key, elem := range sc.kvs {
+		if !elem.expire() {
+			elems[key] = elem
+		}
+	}
+	sc.lock.RUnlock()
+	for key, elem := range elems {
+		fn(key, elem)
+	}
+}
+
+// rangeElems implements cacheRanger
+func (fc *FreeCache) rangeElems(fn func(key string, elem *LockElem)) {
+	it := fc.c.NewIterator()
+	for entry := it.Next(); entry != nil; entry = it.Next() {
+		elem := &LockElem{}
+		if err := fc.codec.Unmarshal(entry.Value, elem); err != nil || elem.expire() {
+			continue
+		}
+		fn(string(entry.Key), elem)
+	}
+}
+
+// rebalanceWorkers is the number of locks migrated concurrently by Rebalance
+const rebalanceWorkers = 16
+
+// instanceKey returns the canonical network address of the instance of addr,
+// which is either a connection string or the address of a redis client
+func instanceKey(addr string) string {
+	if opts, err := parseConnString(addr); err == nil && opts.Addr != "" {
+		return normalizeAddr(opts)
+	}
+	return normalizeAddr(&redis.Options{Addr: addr})
+}
+
+// Rebalance migrates the locks held by r to a new set of instances for an
+// online resize of the deployment, it returns a RedLock on addrs with the
+// settings of r. Each lock in the local cache is acquired on the new
+// instances with its value and remaining validity as ttl, extending it on
+// the instances it is already held on, then it is released on the instances
+// of r which are not in addrs. The returned RedLock shares the local cache of
+// r, so the migrated locks are owned, extended and released by it, while r
+// and the handles acquired by r should no longer be used and r should be
+// closed by caller. At most rebalanceWorkers locks are migrated concurrently.
+//
+// A lock that fails to be acquired on the new instances is lost, it is
+// removed from the cache, released on all the old instances, including the
+// ones in addrs, and reported by an
+// EventLost of r, its resource is returned among lost. Locks must not be
+// acquired or released by r during Rebalance, the renewals of r in progress
+// fail after their locks migrate.
+func (r *RedLock) Rebalance(ctx context.Context, addrs []string) (*RedLock, []string, error) {
+	ranger, ok := r.cache.(cacheRanger)
+	if !ok {
+		return nil, nil, fmt.Errorf("rebalance is not supported by cache %T", r.cache)
+	}
+	clients, err := newClients(addrs)
+	if err != nil {
+		return nil, nil, err
+	}
+	n, err := r.clone(clients, &sharedClients{owned: true})
+	if err == nil {
+		if n.instanceLatency {
+			addLatencyHooks(clients)
+		}
+		err = n.checkConnect(ctx)
+	}
+	if err != nil {
+		for _, cli := range clients {
+			cli.cli.Close() // nolint:errcheck
+		}
+		return nil, nil, err
+	}
+
+	kept := make(map[string]struct{}, len(addrs))
+	for _, addr := range addrs {
+		kept[instanceKey(addr)] = struct{}{}
+	}
+	retired := make([]*RedClient, 0, len(r.clients))
+	for _, cli := range r.clients {
+		if _, ok := kept[instanceKey(cli.addr)]; !ok {
+			retired = append(retired, cli)
+		}
+	}
+
+	var (
+		mu   sync.Mutex
+		lost []string
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, rebalanceWorkers)
+	)
+	ranger.rangeElems(func(resource string, elem *LockElem) {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			ttl := time.Duration(elem.remaining())
+			if _, err := n.extendOrReacquire(ctx, resource, elem.Val, ttl); err != nil {
+				r.cache.Delete(resource)
+				r.heldTTLs.delete(resource)
+				r.holdCounts.clear(resource)
+				r.emit(EventLost, resource, 0, err)
+				mu.Lock()
+				lost = append(lost, resource)
+				mu.Unlock()
+				r.unlockOn(r.clients, resource, elem.Val)
+				return
+			}
+			r.unlockOn(retired, resource, elem.Val)
+		}()
+	})
+	wg.Wait()
+	return n, lost, nil
+}
+
+// unlockOn releases the lock of resource on the given instances
+func (r *RedLock) unlockOn(clients []*RedClient, resource, val string) {
+	for _, cli := range clients {
+		ctx, cancel := context.WithTimeout(context.Background(), r.unlockTimeout)
+		r.unlockInstance(ctx, cli, resource, val) // nolint:errcheck
+		cancel()
+	}
+}
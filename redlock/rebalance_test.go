@@ -0,0 +1,70 @@
+package redlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebalance(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers[2:], WithEvents(4))
+	assert.Nil(t, err)
+	defer lock.Close()
+	other, err := NewRedLock(ctx, redisServers[:1])
+	assert.Nil(t, err)
+	defer other.Close()
+
+	_, err = lock.Lock(ctx, "rebalance-foo", 10*time.Second)
+	assert.Nil(t, err)
+	_, err = lock.Lock(ctx, "rebalance-bar", 10*time.Second)
+	assert.Nil(t, err)
+	foo, err := lock.cache.Get("rebalance-foo")
+	assert.Nil(t, err)
+	// the lock held by others on the new instance can't be migrated
+	_, err = other.Lock(ctx, "rebalance-bar", 10*time.Second)
+	assert.Nil(t, err)
+	defer other.UnLock(ctx, "rebalance-bar") // nolint:errcheck
+
+	moved, lost, err := lock.Rebalance(ctx, redisServers[:1])
+	assert.Nil(t, err)
+	defer moved.Close()
+	assert.Equal(t, []string{"rebalance-bar"}, lost)
+	ev := <-lock.Events()
+	for ev.Type != EventLost {
+		ev = <-lock.Events()
+	}
+	assert.Equal(t, "rebalance-bar", ev.Resource)
+	assert.Equal(t, ErrLockHeld, ev.Err)
+
+	assert.True(t, moved.Owns("rebalance-foo"))
+	assert.False(t, moved.Owns("rebalance-bar"))
+	assert.Equal(t, 1, moved.quorum)
+	val, err := rawClient(moved.clients[0]).Get(ctx, "rebalance-foo").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, foo.Val, val)
+	// both locks are released on the retired instance
+	for _, resource := range []string{"rebalance-foo", "rebalance-bar"} {
+		n, err := rawClient(lock.clients[0]).Exists(ctx, resource).Result()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), n)
+	}
+
+	// the lock is extended where it is held and acquired on the new instances
+	grown, lost, err := moved.Rebalance(ctx, redisServers)
+	assert.Nil(t, err)
+	defer grown.Close()
+	assert.Empty(t, lost)
+	values, err := grown.Inspect(ctx, "rebalance-foo")
+	assert.Nil(t, err)
+	assert.Len(t, values, len(redisServers))
+	for _, v := range values {
+		assert.Equal(t, foo.Val, v)
+	}
+	assert.Nil(t, grown.UnLock(ctx, "rebalance-foo"))
+
+	_, _, err = lock.Rebalance(ctx, redisServers[:2])
+	assert.NotNil(t, err)
+}
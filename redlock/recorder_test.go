@@ -0,0 +1,220 @@
+package redlock
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordedCmd is a command issued to a redis instance, name is the lower case
+// method of redisCmdable, keys and args are as passed to it
+type recordedCmd struct {
+	name string
+	keys []string
+	args []interface{}
+}
+
+// recordingCmdable wraps a redisCmdable and records every command issued to
+// it, including the commands queued in pipelines, for tests to assert what
+// the library sends to each instance.
+type recordingCmdable struct {
+	redisCmdable
+
+	mu   sync.Mutex
+	cmds []recordedCmd
+}
+
+func (c *recordingCmdable) record(name string, keys []string, args ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cmds = append(c.cmds, recordedCmd{name: name, keys: keys, args: args})
+}
+
+// recorded returns the commands recorded so far whose name is any of names,
+// or all of them if no name is given
+func (c *recordingCmdable) recorded(names ...string) []recordedCmd {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cmds := make([]recordedCmd, 0, len(c.cmds))
+	for _, cmd := range c.cmds {
+		if len(names) == 0 {
+			cmds = append(cmds, cmd)
+			continue
+		}
+		for _, name := range names {
+			if cmd.name == name {
+				cmds = append(cmds, cmd)
+				break
+			}
+		}
+	}
+	return cmds
+}
+
+// reset drops the commands recorded so far
+func (c *recordingCmdable) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cmds = nil
+}
+
+func (c *recordingCmdable) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	c.record("setnx", []string{key}, value, expiration)
+	return c.redisCmdable.SetNX(ctx, key, value, expiration)
+}
+
+func (c *recordingCmdable) Get(ctx context.Context, key string) *redis.StringCmd {
+	c.record("get", []string{key})
+	return c.redisCmdable.Get(ctx, key)
+}
+
+func (c *recordingCmdable) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
+	c.record("mget", keys)
+	return c.redisCmdable.MGet(ctx, keys...)
+}
+
+func (c *recordingCmdable) Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd {
+	c.record("scan", nil, cursor, match, count)
+	return c.redisCmdable.Scan(ctx, cursor, match, count)
+}
+
+func (c *recordingCmdable) Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	c.record("eval", keys, append([]interface{}{newLuaScript(script).sha}, args...)...)
+	return c.redisCmdable.Eval(ctx, script, keys, args...)
+}
+
+func (c *recordingCmdable) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	c.record("evalsha", keys, append([]interface{}{sha1}, args...)...)
+	return c.redisCmdable.EvalSha(ctx, sha1, keys, args...)
+}
+
+func (c *recordingCmdable) ScriptLoad(ctx context.Context, script string) *redis.StringCmd {
+	c.record("scriptload", nil, newLuaScript(script).sha)
+	return c.redisCmdable.ScriptLoad(ctx, script)
+}
+
+func (c *recordingCmdable) Wait(ctx context.Context, numSlaves int, timeout time.Duration) *redis.IntCmd {
+	c.record("wait", nil, numSlaves, timeout)
+	return c.redisCmdable.Wait(ctx, numSlaves, timeout)
+}
+
+func (c *recordingCmdable) Do(ctx context.Context, args ...interface{}) *redis.Cmd {
+	c.record("do", nil, args...)
+	return c.redisCmdable.Do(ctx, args...)
+}
+
+func (c *recordingCmdable) Watch(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error {
+	c.record("watch", keys)
+	return c.redisCmdable.Watch(ctx, fn, keys...)
+}
+
+func (c *recordingCmdable) Pipeline() redis.Pipeliner {
+	return &recordingPipeliner{Pipeliner: c.redisCmdable.(redisPipeliner).Pipeline(), c: c}
+}
+
+// recordingPipeliner records the scripts queued in a pipeline, which are the
+// only commands the library pipelines
+type recordingPipeliner struct {
+	redis.Pipeliner
+	c *recordingCmdable
+}
+
+func (p *recordingPipeliner) Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	p.c.record("eval", keys, append([]interface{}{newLuaScript(script).sha}, args...)...)
+	return p.Pipeliner.Eval(ctx, script, keys, args...)
+}
+
+func (p *recordingPipeliner) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	p.c.record("evalsha", keys, append([]interface{}{sha1}, args...)...)
+	return p.Pipeliner.EvalSha(ctx, sha1, keys, args...)
+}
+
+// newRecordingRedLock creates a RedLock on redisServers whose clients record
+// the commands issued to them
+func newRecordingRedLock(t *testing.T, opts ...Option) (*RedLock, []*recordingCmdable) {
+	lock, err := NewRedLock(context.Background(), redisServers, opts...)
+	assert.Nil(t, err)
+	recorders := make([]*recordingCmdable, 0, len(lock.clients))
+	for _, cli := range lock.clients {
+		rec := &recordingCmdable{redisCmdable: cli.cli}
+		cli.cli = rec
+		recorders = append(recorders, rec)
+	}
+	return lock, recorders
+}
+
+// isScript returns whether cmd runs script
+func (cmd recordedCmd) isScript(script *luaScript) bool {
+	return (cmd.name == "eval" || cmd.name == "evalsha") && len(cmd.args) > 0 && cmd.args[0] == script.sha
+}
+
+func TestRecordedCommands(t *testing.T) {
+	ctx := context.Background()
+	lock, recorders := newRecordingRedLock(t, WithRetryCount(1))
+	defer lock.Close()
+
+	// SETNX with the ttl is issued to all instances, and the lock is
+	// released by the unlock script on all of them
+	_, err := lock.Lock(ctx, "recorded-foo", 200*time.Millisecond)
+	assert.Nil(t, err)
+	elem, err := lock.cache.Get("recorded-foo")
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnLock(ctx, "recorded-foo"))
+	for _, rec := range recorders {
+		setnx := rec.recorded("setnx")
+		assert.Equal(t, []recordedCmd{{
+			name: "setnx", keys: []string{"recorded-foo"}, args: []interface{}{elem.Val, 200 * time.Millisecond},
+		}}, setnx)
+		unlock := rec.recorded("eval", "evalsha")
+		assert.NotEmpty(t, unlock)
+		for _, cmd := range unlock {
+			assert.True(t, cmd.isScript(unlockScript))
+			assert.Equal(t, []string{"recorded-foo"}, cmd.keys)
+			assert.Equal(t, elem.Val, cmd.args[1])
+		}
+		rec.reset()
+	}
+
+	// on failure the instances are released by the unlock script with the
+	// value just tried
+	for _, cli := range lock.clients[1:] {
+		assert.Nil(t, rawClient(cli).Set(ctx, "recorded-bar", "others", time.Second).Err())
+	}
+	_, err = lock.Lock(ctx, "recorded-bar", 200*time.Millisecond)
+	assert.NotNil(t, err)
+	val := recorders[0].recorded("setnx")[0].args[0]
+	for idx, rec := range recorders {
+		cmds := rec.recorded()
+		assert.Equal(t, "setnx", cmds[0].name, idx)
+		assert.Equal(t, val, cmds[0].args[0], idx)
+		var unlocked bool
+		for _, cmd := range cmds[1:] {
+			if cmd.isScript(unlockScript) {
+				unlocked = true
+				assert.Equal(t, val, cmd.args[1], idx)
+			}
+		}
+		assert.True(t, unlocked, idx)
+	}
+	for _, cli := range lock.clients[1:] {
+		assert.Equal(t, "others", rawClient(cli).Get(ctx, "recorded-bar").Val())
+		rawClient(cli).Del(ctx, "recorded-bar")
+	}
+
+	// the scripts in the pipeline of ReleaseAll are recorded as well
+	_, err = lock.Lock(ctx, "recorded-foo", 200*time.Millisecond)
+	assert.Nil(t, err)
+	for _, rec := range recorders {
+		rec.reset()
+	}
+	assert.Nil(t, lock.ReleaseAll(ctx, "recorded-foo"))
+	for _, rec := range recorders {
+		cmds := rec.recorded("eval", "evalsha")
+		assert.NotEmpty(t, cmds)
+		assert.True(t, cmds[0].isScript(unlockScript))
+	}
+}
@@ -2,12 +2,12 @@ package redlock
 
 import (
 	"context"
-	crand "crypto/rand"
-	"encoding/base64"
 	"errors"
 	"fmt"
-	"math/rand"
+	"math"
+	"net"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -24,13 +24,43 @@ const (
 	// DefaultRetryDelay is upper wait time in millisecond for lock acquire retry
 	DefaultRetryDelay = 200
 
+	// DefaultUnlockTimeout is the upper wait time for releasing lock on a single redis
+	DefaultUnlockTimeout = 500 * time.Millisecond
+
+	// DefaultInstanceTimeout is the upper wait time for setting or extending
+	// lock on a single redis
+	DefaultInstanceTimeout = 500 * time.Millisecond
+
 	// ClockDriftFactor is clock drift factor, more information refers to doc
 	ClockDriftFactor = 0.01
 
-	// UnlockScript is redis lua script to release a lock
+	// UnlockScript is redis lua script to release a lock, the companion keys
+	// of the lock in KEYS[2:] are deleted along with it
 	UnlockScript = `
         if redis.call("get", KEYS[1]) == ARGV[1] then
-            return redis.call("del", KEYS[1])
+            return redis.call("del", unpack(KEYS))
+        else
+            return 0
+        end
+        `
+
+	// ExtendScript is redis lua script to reset the ttl of a lock held by us
+	ExtendScript = `
+        if redis.call("get", KEYS[1]) == ARGV[1] then
+            return redis.call("pexpire", KEYS[1], ARGV[2])
+        else
+            return 0
+        end
+        `
+
+	// LockIfScript is redis lua script to acquire a lock only if the
+	// condition key holds the expected value
+	LockIfScript = `
+        if redis.call("get", KEYS[2]) ~= ARGV[3] then
+            return -1
+        end
+        if redis.call("set", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+            return 1
         else
             return 0
         end
@@ -39,28 +69,387 @@ const (
 
 var (
 	// ErrLockSingleRedis represents error when acquiring lock on a single redis
+	//
+	// Deprecated: a lock held by others on a single redis is not an error any
+	// more, this error is no longer returned.
 	ErrLockSingleRedis = errors.New("set lock on single redis failed")
 
 	// ErrAcquireLock means acquire lock failed after max retry time
 	ErrAcquireLock = errors.New("failed to require lock")
+
+	// ErrConditionFailed means the condition of a conditional lock didn't match on quorum
+	ErrConditionFailed = errors.New("lock condition failed")
+
+	// ErrCondKeySlot means the condition key of a conditional lock doesn't
+	// carry the hash tag of lock key
+	ErrCondKeySlot = errors.New("condition key is not in the hash slot of lock key")
+
+	// ErrLockNotHeld means the lock is not held by this RedLock
+	ErrLockNotHeld = errors.New("lock is not held")
+
+	// ErrExtendLock means extend lock failed on quorum
+	ErrExtendLock = errors.New("failed to extend lock")
+
+	// ErrTTLTooLong means the ttl exceeds the max ttl of RedLock
+	ErrTTLTooLong = errors.New("lock ttl exceeds max ttl")
+
+	// ErrNoValidity means quorum is reached but no validity remains since the
+	// acquisition took too long
+	ErrNoValidity = errors.New("lock acquired on quorum without remaining validity")
+
+	// ErrWaitReplicas means the lock key was not replicated to enough replicas
+	ErrWaitReplicas = errors.New("not enough replicas acknowledged the lock")
+
+	// ErrDeadlinePassed means the deadline of lock is already past
+	ErrDeadlinePassed = errors.New("lock deadline is already past")
+
+	// ErrClusterRedirect means an instance replied MOVED or ASK, it is a node
+	// of redis cluster that should be accessed by a cluster client
+	ErrClusterRedirect = errors.New("redis cluster redirection, use NewRedLockFromClusterClients for redis cluster")
+
+	// ErrAlreadyHeld means the lock is already held by this RedLock, which is
+	// not re-entrant by default
+	ErrAlreadyHeld = errors.New("lock is already held")
+
+	// ErrTTLMismatch means a re-entrant acquisition specifies a ttl other
+	// than the one of the held lock
+	ErrTTLMismatch = errors.New("lock ttl mismatches the held lock")
+
+	// ErrValueTooLarge means the lock value exceeds the max value bytes
+	ErrValueTooLarge = errors.New("lock value is too large")
+
+	// ErrDuplicateAddr means a redis server is listed more than once, which
+	// lets a single instance count toward quorum several times
+	ErrDuplicateAddr = errors.New("duplicate redis server address")
 )
 
 // RedLock holds the redis lock
 type RedLock struct {
-	retryCount  int
-	retryDelay  int
-	driftFactor float64
+	// inflight and pendingUnlocks are accessed atomically, keep them 64-bit
+	// aligned
+	inflight       int64
+	pendingUnlocks int64
+	inflightRes    *inflightResources
+	flight         *localFlight
+	events         *lockEvents
+
+	retryCount    int
+	retryDelay    int
+	connRetries   int
+	backoffMin    time.Duration
+	backoffMax    time.Duration
+	driftFactor   float64
+	absoluteDrift time.Duration
+
+	clients       []*RedClient
+	shared        *sharedClients
+	closed        int32
+	quorum        int
+	quorumPercent float64
+	connectPolicy ConnectPolicy
+
+	// optErr is the first error of invalid options
+	optErr    error
+	envConfig bool
+
+	maxTTL          time.Duration
+	maxValueBytes   int
+	retryStrategy   RetryStrategy
+	retryBudget     *retryBudget
+	resourceRate    *resourceLimiters
+	hedgeDelay      time.Duration
+	setNXGet        bool
+	informedBackoff bool
+	leaseMetadata   bool
+	hashTag         string
+	keyHasher       func(resource string) string
+
+	validateResource func(resource string) error
+
+	failFastNoValidity bool
+	requireDeadline    bool
+	reentrant          bool
+	reentrantTTL       ReentrantTTLPolicy
+	holdCounts         *holdCounts
+	heldTTLs           *heldTTLs
+
+	unlockTimeout   time.Duration
+	unlockRetry     bool
+	instanceTimeout time.Duration
+
+	resourceTTLs map[string]time.Duration
+	defaultTTL   time.Duration
+
+	longHoldFraction float64
+
+	waitReplicas int
+	waitTimeout  time.Duration
+
+	auditHook     ContextAuditHook
+	ownerProvider OwnerProvider
+	valuePrefix   string
 
-	clients []*RedClient
-	quorum  int
+	deadlockDetection bool
+	ulidValue         bool
+	expiryInValue     bool
+	identity          string
+	entropyPolicy     EntropyPolicy
+	expvar            *expvarStats
+	matcher           ValueMatcher
+
+	instanceLatency bool
+	instanceClasses map[string]int
+	cacheStats      *cacheCounters
+	diag            diagnostics
 
 	cache KVCache
+	// cancelCache stops the cache created by Clone
+	cancelCache context.CancelFunc
+}
+
+// Option configures a RedLock, both CacheOption and LockOption satisfy it, so
+// the cache and the lock behavior are all configured at construction.
+type Option interface {
+	isOption()
+}
+
+func (CacheOption) isOption() {}
+
+// LockOption alias to the function that can be used to configure RedLock
+type LockOption func(*RedLock)
+
+func (LockOption) isOption() {}
+
+// WithRetryCount sets the max retry times for lock acquire, it is the same as
+// SetRetryCount but set at construction.
+func WithRetryCount(count int) LockOption {
+	return func(r *RedLock) {
+		if count <= 0 {
+			r.setOptErr(fmt.Errorf("invalid retry count %d, must be positive", count))
+			return
+		}
+		r.retryCount = count
+	}
+}
+
+// WithRetryDelay sets the upper wait time in millisecond for lock acquire
+// retry, it is the same as SetRetryDelay but set at construction. It is
+// ignored if WithRetryBackoff is set.
+func WithRetryDelay(delay int) LockOption {
+	return func(r *RedLock) {
+		if delay <= 0 {
+			r.setOptErr(fmt.Errorf("invalid retry delay %d, must be positive", delay))
+			return
+		}
+		r.retryDelay = delay
+	}
+}
+
+// WithDriftFactor sets the clock drift factor, which must be in [0, 1)
+func WithDriftFactor(factor float64) LockOption {
+	return func(r *RedLock) {
+		if factor < 0 || factor >= 1 {
+			r.setOptErr(fmt.Errorf("invalid clock drift factor %v, must be in [0, 1)", factor))
+			return
+		}
+		r.driftFactor = factor
+	}
+}
+
+// WithAbsoluteDrift sets a clock drift deducted from validity regardless of
+// ttl, on top of the drift proportional to ttl by the drift factor. Clock
+// drift bounded by NTP hardly depends on lock duration, so short locks are
+// better modeled by an absolute drift, use WithDriftFactor(0) to deduct the
+// absolute drift only.
+func WithAbsoluteDrift(drift time.Duration) LockOption {
+	return func(r *RedLock) {
+		if drift < 0 {
+			r.setOptErr(fmt.Errorf("invalid absolute clock drift %s, must not be negative", drift))
+			return
+		}
+		r.absoluteDrift = drift
+	}
+}
+
+// WithMaxTTL sets the max ttl that Lock and Extend accept, it is the same as
+// SetMaxTTL but set at construction.
+func WithMaxTTL(ttl time.Duration) LockOption {
+	return func(r *RedLock) {
+		if ttl < 0 {
+			r.setOptErr(fmt.Errorf("invalid max ttl %s, must not be negative", ttl))
+			return
+		}
+		r.maxTTL = ttl
+	}
+}
+
+// WithInstanceTimeout sets the upper wait time for setting or extending lock
+// on each instance, it should be small compared to the ttl, so an instance
+// that doesn't reply can't eat the validity of lock. It is never longer than
+// the ttl, since a lock set after its ttl has no validity. It is unrelated to
+// the budget of acquisition, which is the deadline of ctx passed to Lock.
+func WithInstanceTimeout(timeout time.Duration) LockOption {
+	return func(r *RedLock) {
+		if timeout > 0 {
+			r.instanceTimeout = timeout
+		}
+	}
+}
+
+// WithUnlockTimeout sets the upper wait time for releasing lock on each instance,
+// an instance that doesn't reply in time won't block UnLock.
+func WithUnlockTimeout(timeout time.Duration) LockOption {
+	return func(r *RedLock) {
+		if timeout > 0 {
+			r.unlockTimeout = timeout
+		}
+	}
+}
+
+// WithSetNXGet makes lock acquisition use `SET key val PX ttl NX GET`, which
+// reports the value of current holder on contention in the same round trip.
+// It requires redis 7.0, older servers fall back to plain SETNX.
+func WithSetNXGet() LockOption {
+	return func(r *RedLock) {
+		r.setNXGet = true
+	}
+}
+
+// WithQuorumPercent sets the quorum as a percentage of instances, the quorum
+// is ceil(p * N) and always at least majority. p must be in range (0.5, 1]
+// to preserve the safety property.
+func WithQuorumPercent(p float64) LockOption {
+	return func(r *RedLock) {
+		if p <= 0.5 || p > 1 {
+			r.setOptErr(fmt.Errorf("invalid quorum percent %v, must be in (0.5, 1]", p))
+			return
+		}
+		r.quorumPercent = p
+	}
+}
+
+func (r *RedLock) setOptErr(err error) {
+	if r.optErr == nil {
+		r.optErr = err
+	}
+}
+
+// WithFailFastOnNoValidity makes acquisition fail with ErrNoValidity, instead
+// of retrying, when quorum is reached but no validity remains. Retrying with
+// the same ttl under the same latency would likely end up the same.
+func WithFailFastOnNoValidity() LockOption {
+	return func(r *RedLock) {
+		r.failFastNoValidity = true
+	}
+}
+
+// WithReentrant makes acquiring a lock that is already held by this RedLock,
+// according to the local cache, return the held lock with its remaining
+// validity immediately, instead of failing with ErrAlreadyHeld. A differing
+// ttl is reconciled by WithReentrantTTLPolicy. Note the lock is not
+// reference counted, a single unlock releases it, see WithCountingReentrant.
+func WithReentrant() LockOption {
+	return func(r *RedLock) {
+		r.reentrant = true
+		if r.heldTTLs == nil {
+			r.heldTTLs = newHeldTTLs()
+		}
+	}
+}
+
+// WithHashTag wraps resource names with a hash tag before sending to redis,
+// lock key of resource becomes `{tag}:resource`, so that all lock keys land
+// in the same hash slot on redis cluster. Note it also means all locks are
+// served by a single cluster node. The local cache and public API keep the
+// original resource name.
+func WithHashTag(tag string) LockOption {
+	return func(r *RedLock) {
+		r.hashTag = tag
+	}
+}
+
+// WithKeyHasher sets a function that maps resource to the key sent to redis,
+// such as a sha256 hex digest, to bound the key length and avoid exposing
+// the resource name in redis. Two resources mustn't collide after hashing,
+// so a strong hash should be used. The local cache and public API keep the
+// original resource name, the hash tag is prepended after hashing.
+func WithKeyHasher(hasher func(resource string) string) LockOption {
+	return func(r *RedLock) {
+		r.keyHasher = hasher
+	}
+}
+
+// redisKey returns the key in redis of resource
+func (r *RedLock) redisKey(resource string) string {
+	if r.keyHasher != nil {
+		resource = r.keyHasher(resource)
+	}
+	if r.hashTag != "" {
+		return "{" + r.hashTag + "}:" + resource
+	}
+	return resource
+}
+
+// hashTagOf returns the hash tag of key that decides its slot on redis
+// cluster, which is the content between the first `{` and the first `}`
+// after it if not empty, or empty string if key has none
+func hashTagOf(key string) string {
+	start := strings.Index(key, "{")
+	if start < 0 {
+		return ""
+	}
+	end := strings.Index(key[start+1:], "}")
+	if end <= 0 {
+		return ""
+	}
+	return key[start+1 : start+1+end]
+}
+
+// WithWaitReplicas makes each instance issue `WAIT n timeout` after a
+// successful SETNX, the instance is counted toward quorum only if at least
+// n replicas acknowledged the lock key within timeout.
+func WithWaitReplicas(n int, timeout time.Duration) LockOption {
+	return func(r *RedLock) {
+		r.waitReplicas = n
+		r.waitTimeout = timeout
+	}
+}
+
+// redisCmdable is the subset of redis commands used by RedLock
+type redisCmdable interface {
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	Get(ctx context.Context, key string) *redis.StringCmd
+	MGet(ctx context.Context, keys ...string) *redis.SliceCmd
+	Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd
+	EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd
+	ScriptLoad(ctx context.Context, script string) *redis.StringCmd
+	Wait(ctx context.Context, numSlaves int, timeout time.Duration) *redis.IntCmd
+	Do(ctx context.Context, args ...interface{}) *redis.Cmd
+	Watch(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error
+	Ping(ctx context.Context) *redis.StatusCmd
+	Close() error
+}
+
+// redisPipeliner is implemented by the redis clients that support pipelining,
+// the commands of other instances are sent one by one
+type redisPipeliner interface {
+	Pipeline() redis.Pipeliner
 }
 
 // RedClient holds client to redis
 type RedClient struct {
 	addr string
-	cli  *redis.Client
+	cli  redisCmdable
+
+	// noSetNXGet is set if the redis server doesn't support SET NX GET
+	noSetNXGet int32
+
+	// latency records the command latency if WithInstanceLatency is set
+	latency *latencyHook
+
+	// store is set if the instance is a LockStore rather than redis
+	store LockStore
 }
 
 func parseConnString(addr string) (*redis.Options, error) {
@@ -118,30 +507,187 @@ func parseConnString(addr string) (*redis.Options, error) {
 
 // NewRedLock creates a RedLock
 func NewRedLock(
-	ctx context.Context, addrs []string, opts ...CacheOption,
+	ctx context.Context, addrs []string, opts ...Option,
 ) (*RedLock, error) {
+	clients, err := newClients(addrs)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newRedLock(ctx, clients, opts...)
+	if err != nil {
+		return nil, err
+	}
+	r.shared.owned = true
+	return r, nil
+}
+
+// newClients creates the redis clients of addrs
+func newClients(addrs []string) ([]*RedClient, error) {
 	if len(addrs)%2 == 0 {
 		return nil, fmt.Errorf("error redis server list: %d", len(addrs))
 	}
 
-	clients := []*RedClient{}
+	connOpts := make([]*redis.Options, 0, len(addrs))
 	for _, addr := range addrs {
 		opts, err := parseConnString(addr)
 		if err != nil {
 			return nil, err
 		}
-		cli := redis.NewClient(opts)
-		clients = append(clients, &RedClient{addr, cli})
+		connOpts = append(connOpts, opts)
+	}
+	if err := checkDuplicateAddrs(addrs, connOpts); err != nil {
+		return nil, err
+	}
+	clients := []*RedClient{}
+	for idx, addr := range addrs {
+		cli := redis.NewClient(connOpts[idx])
+		clients = append(clients, &RedClient{addr: addr, cli: cli})
+	}
+	return clients, nil
+}
+
+// NewRedLockFromClients creates a RedLock on the given redis clients, which
+// allows using clients configured by caller.
+func NewRedLockFromClients(
+	ctx context.Context, clis []*redis.Client, opts ...Option,
+) (*RedLock, error) {
+	if len(clis)%2 == 0 {
+		return nil, fmt.Errorf("error redis server list: %d", len(clis))
+	}
+	addrs := make([]string, 0, len(clis))
+	connOpts := make([]*redis.Options, 0, len(clis))
+	for _, cli := range clis {
+		addrs = append(addrs, cli.Options().Addr)
+		connOpts = append(connOpts, cli.Options())
+	}
+	if err := checkDuplicateAddrs(addrs, connOpts); err != nil {
+		return nil, err
+	}
+	clients := make([]*RedClient, 0, len(clis))
+	for _, cli := range clis {
+		clients = append(clients, &RedClient{addr: cli.Options().Addr, cli: cli})
+	}
+	return newRedLock(ctx, clients, opts...)
+}
+
+// checkDuplicateAddrs returns an error wrapping ErrDuplicateAddr if any two
+// addrs point to the same redis server. Databases of a server are not
+// independent instances, so the db is ignored.
+func checkDuplicateAddrs(addrs []string, connOpts []*redis.Options) error {
+	seen := make(map[string]string, len(addrs))
+	for idx, opts := range connOpts {
+		key := normalizeAddr(opts)
+		if prev, ok := seen[key]; ok {
+			return fmt.Errorf("%w: %s and %s", ErrDuplicateAddr, prev, addrs[idx])
+		}
+		seen[key] = addrs[idx]
 	}
+	return nil
+}
 
-	return &RedLock{
-		retryCount:  DefaultRetryCount,
-		retryDelay:  DefaultRetryDelay,
-		driftFactor: ClockDriftFactor,
-		quorum:      len(addrs)/2 + 1,
-		clients:     clients,
-		cache:       NewCacheImpl(ctx, opts...),
-	}, nil
+// normalizeAddr returns the network address of a redis server in canonical
+// form, with lower case host, localhost resolved to 127.0.0.1 and the default
+// network and port filled in
+func normalizeAddr(opts *redis.Options) string {
+	network := opts.Network
+	if network == "" {
+		network = "tcp"
+	}
+	if network == "unix" {
+		return network + ":" + opts.Addr
+	}
+	host, port, err := net.SplitHostPort(opts.Addr)
+	if err != nil {
+		host, port = opts.Addr, "6379"
+	}
+	host = strings.ToLower(host)
+	if host == "" || host == "localhost" {
+		host = "127.0.0.1"
+	}
+	if port == "" {
+		port = "6379"
+	}
+	return network + ":" + net.JoinHostPort(host, port)
+}
+
+// NewRedLockFromClusterClients creates a RedLock on the given redis cluster
+// clients, each cluster is counted as a single instance toward quorum.
+func NewRedLockFromClusterClients(
+	ctx context.Context, clis []*redis.ClusterClient, opts ...Option,
+) (*RedLock, error) {
+	if len(clis)%2 == 0 {
+		return nil, fmt.Errorf("error redis server list: %d", len(clis))
+	}
+	clients := make([]*RedClient, 0, len(clis))
+	for _, cli := range clis {
+		addr := strings.Join(cli.Options().Addrs, ",")
+		clients = append(clients, &RedClient{addr: addr, cli: cli})
+	}
+	return newRedLock(ctx, clients, opts...)
+}
+
+func newRedLock(ctx context.Context, clients []*RedClient, opts ...Option) (*RedLock, error) {
+	r := &RedLock{
+		retryCount:      DefaultRetryCount,
+		retryDelay:      DefaultRetryDelay,
+		connRetries:     DefaultConnRetries,
+		driftFactor:     ClockDriftFactor,
+		unlockTimeout:   DefaultUnlockTimeout,
+		instanceTimeout: DefaultInstanceTimeout,
+		maxValueBytes:   DefaultMaxValueBytes,
+		matcher:         ExactMatch(),
+		clients:         clients,
+		shared:          &sharedClients{refs: 1},
+		cacheStats:      &cacheCounters{},
+	}
+	cacheOpts := make([]CacheOption, 0, len(opts))
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case CacheOption:
+			cacheOpts = append(cacheOpts, o)
+		case LockOption:
+			o(r)
+		}
+	}
+	if r.optErr != nil {
+		return nil, r.optErr
+	}
+	cacheOptions := newCacheOptions(cacheOpts...)
+	if err := cacheOptions.Validate(); err != nil {
+		return nil, err
+	}
+	if r.envConfig {
+		if err := r.applyEnvConfig(); err != nil {
+			return nil, err
+		}
+	}
+	r.quorum = r.computeQuorum(len(clients))
+	if r.instanceLatency {
+		addLatencyHooks(clients)
+	}
+	if err := r.checkConnect(ctx); err != nil {
+		return nil, err
+	}
+	r.cache = newCache(ctx, cacheOptions)
+	if r.expvar != nil {
+		r.expvar.publish(r)
+	}
+	return r, nil
+}
+
+// computeQuorum returns the quorum of n instances, which is at least majority
+func (r *RedLock) computeQuorum(n int) int {
+	quorum := n/2 + 1
+	if r.quorumPercent > 0 {
+		q := int(math.Ceil(r.quorumPercent * float64(n)))
+		if q > quorum {
+			quorum = q
+		}
+	}
+	if quorum > n {
+		quorum = n
+	}
+	return quorum
 }
 
 // SetRetryCount sets acquire lock retry count
@@ -160,107 +706,803 @@ func (r *RedLock) SetRetryDelay(delay int) {
 	r.retryDelay = delay
 }
 
-func getRandStr() string {
-	b := make([]byte, 16)
-	crand.Read(b)
-	return base64.StdEncoding.EncodeToString(b)
+// SetInstanceTimeout sets the upper wait time for setting or extending lock on
+// each instance, see WithInstanceTimeout
+func (r *RedLock) SetInstanceTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	r.instanceTimeout = timeout
 }
 
-func lockInstance(ctx context.Context, client *RedClient, resource string, val string, ttl time.Duration) (bool, error) {
-	reply := client.cli.SetNX(ctx, resource, val, ttl)
-	if reply.Err() != nil {
-		return false, reply.Err()
+// instanceWait returns the upper wait time of each instance for a lock with
+// ttl
+func (r *RedLock) instanceWait(ttl time.Duration) time.Duration {
+	if r.instanceTimeout > 0 && r.instanceTimeout < ttl {
+		return r.instanceTimeout
 	}
-	if !reply.Val() {
-		return false, ErrLockSingleRedis
+	return ttl
+}
+
+// formatMs converts ttl to milliseconds used by PX, the same as go-redis does
+func formatMs(ttl time.Duration) int64 {
+	if ttl > 0 && ttl < time.Millisecond {
+		return 1
 	}
-	return true, nil
+	return int64(ttl / time.Millisecond)
+}
+
+// SetMaxTTL sets the max ttl that Lock and Extend accept, zero means no limit
+func (r *RedLock) SetMaxTTL(ttl time.Duration) {
+	if ttl < 0 {
+		return
+	}
+	r.maxTTL = ttl
+}
+
+// WithResourceValidator sets a validator of resource names, such as one
+// enforcing a namespace convention. It is called at the start of acquiring,
+// extending and releasing a lock, the error it returns is returned to the
+// caller as is. There is no validation by default.
+func WithResourceValidator(validate func(resource string) error) LockOption {
+	return func(r *RedLock) {
+		r.validateResource = validate
+	}
+}
+
+func (r *RedLock) checkResource(resource string) error {
+	if r.validateResource == nil {
+		return nil
+	}
+	return r.validateResource(resource)
+}
+
+func (r *RedLock) checkTTL(ttl time.Duration) error {
+	if r.maxTTL > 0 && ttl > r.maxTTL {
+		return fmt.Errorf("%w: %s > %s", ErrTTLTooLong, ttl, r.maxTTL)
+	}
+	return nil
+}
+
+// grantedTTL returns the ttl that redis actually grants, PX is in millisecond
+// resolution and sub-millisecond part of ttl is truncated.
+func grantedTTL(ttl time.Duration) time.Duration {
+	if granted := ttl.Truncate(time.Millisecond); granted > 0 {
+		return granted
+	}
+	return ttl
+}
+
+// validity returns the remaining valid time in nanoseconds of a lock with ttl,
+// which is acquired from start. It is computed from the ttl granted by redis,
+// so the local cache never claims validity longer than redis does.
+func (r *RedLock) validity(ttl time.Duration, start time.Time) int64 {
+	return r.validityAt(ttl, start, time.Now())
+}
+
+// validityAt returns the validity of a lock set since start, counted from at
+func (r *RedLock) validityAt(ttl time.Duration, start, at time.Time) int64 {
+	drift := int64(float64(ttl)*r.driftFactor) + int64(r.absoluteDrift) + 2
+	costTime := at.Sub(start).Nanoseconds()
+	return int64(grantedTTL(ttl)) - costTime - drift
 }
 
-func unlockInstance(ctx context.Context, client *RedClient, resource string, val string) (bool, error) {
-	reply := client.cli.Eval(ctx, UnlockScript, []string{resource}, val)
+func (r *RedLock) lockInstance(ctx context.Context, client *RedClient, resource string, val string, ttl time.Duration) lockResult {
+	return r.lockInstanceWith(ctx, client, resource, val, ttl, r.setNXGet)
+}
+
+// lockInstanceWith is the same as lockInstance, setNXGet decides whether to
+// try `SET NX GET` regardless of the option of RedLock
+func (r *RedLock) lockInstanceWith(
+	ctx context.Context, client *RedClient, resource string, val string, ttl time.Duration, setNXGet bool,
+) lockResult {
+	key := r.redisKey(resource)
+	res := r.setInstanceRetrying(ctx, client, key, val, func() lockResult {
+		switch {
+		case r.leaseMetadata:
+			return r.leaseLockInstance(ctx, client, key, val, ttl)
+		case r.informedBackoff:
+			return informedLockInstance(ctx, client, key, val, ttl)
+		case setNXGet && atomic.LoadInt32(&client.noSetNXGet) == 0:
+			return setNXGetInstance(ctx, client, key, val, ttl)
+		default:
+			locked, err := r.store(client).SetNX(ctx, key, val, ttl)
+			return lockResult{locked: locked, err: err}
+		}
+	})
+	// the key exists, lock is held by others
+	if res.err != nil || !res.locked {
+		return res
+	}
+	if r.waitReplicas > 0 {
+		acked, err := client.cli.Wait(ctx, r.waitReplicas, r.waitTimeout).Result()
+		if err != nil {
+			return lockResult{err: err}
+		}
+		if int(acked) < r.waitReplicas {
+			return lockResult{err: ErrWaitReplicas}
+		}
+	}
+	return res
+}
+
+// setNXGetInstance sets the lock with `SET key val PX ttl NX GET`, which
+// returns the value of holder if lock is held by others. It falls back to
+// SETNX on redis servers older than 7.0 that reject the combination.
+func setNXGetInstance(ctx context.Context, client *RedClient, key string, val string, ttl time.Duration) lockResult {
+	holder, err := client.cli.Do(ctx, "set", key, val, "px", formatMs(ttl), "nx", "get").Text()
+	switch {
+	case err == redis.Nil:
+		return lockResult{locked: true}
+	case err == nil:
+		return lockResult{holder: holder}
+	case strings.Contains(err.Error(), "syntax error"):
+		atomic.StoreInt32(&client.noSetNXGet, 1)
+		reply := client.cli.SetNX(ctx, key, val, ttl)
+		return lockResult{locked: reply.Val(), err: reply.Err()}
+	default:
+		return lockResult{err: err}
+	}
+}
+
+func (r *RedLock) lockIfInstance(
+	ctx context.Context, client *RedClient, resource, val string, ttl time.Duration, condKey, condVal string,
+) lockResult {
+	reply := lockIfScript.run(ctx, client.cli, []string{r.redisKey(resource), condKey}, val, formatMs(ttl), condVal)
 	if reply.Err() != nil {
-		return false, reply.Err()
+		return lockResult{err: reply.Err()}
+	}
+	switch reply.Val() {
+	case int64(1):
+		return lockResult{locked: true}
+	case int64(-1):
+		return lockResult{err: ErrConditionFailed}
+	default:
+		return lockResult{}
+	}
+}
+
+func (r *RedLock) extendInstance(ctx context.Context, client *RedClient, resource string, val string, ttl time.Duration) (bool, error) {
+	ext, ok := r.store(client).(StoreExtender)
+	if !ok {
+		return false, ErrStoreUnsupported
+	}
+	return ext.Extend(ctx, r.redisKey(resource), val, ttl)
+}
+
+func (r *RedLock) unlockInstance(ctx context.Context, client *RedClient, resource string, val string) (bool, error) {
+	if err := r.store(client).Unlock(ctx, r.redisKey(resource), val); err != nil {
+		return false, err
 	}
 	return true, nil
 }
 
+// unlockArgs returns the keys and args of the unlock script to release the
+// lock of key with val
+func (r *RedLock) unlockArgs(key, val string) ([]string, []interface{}) {
+	keys := append([]string{key}, r.companionKeys(key)...)
+	args := append([]interface{}{val}, r.matcher.args...)
+	return keys, args
+}
+
+// companionKeys returns the keys that belong to the lock of key and are
+// deleted atomically with it on release, such as the lease metadata
+func (r *RedLock) companionKeys(key string) []string {
+	if r.leaseMetadata {
+		return []string{key + leaseSuffix}
+	}
+	return nil
+}
+
+// lockResult is the result of setting the lock on a single redis instance
+type lockResult struct {
+	locked bool
+	// holder is the value of lock held by others, it is available only if
+	// the instance reports it
+	holder string
+	// pttl is the remaining ttl of the lock held by others, it is available
+	// only with WithInformedBackoff
+	pttl time.Duration
+	err  error
+	// at is the time the instance replied
+	at time.Time
+}
+
+// lockFunc tries to set the lock on a single redis instance
+type lockFunc func(ctx context.Context, client *RedClient) lockResult
+
 // Lock acquires a distribute lock, returns
-// - the remaining valid duration that lock is guaranted
-// - error if acquire lock fails
+// - the remaining valid duration that lock is guaranted, counted from the time
+// the lock was set on quorum instances rather than when the slowest instance
+// replied, so time spent waiting for instances beyond quorum is not deducted
+// - error if acquire lock fails, which is a QuorumError if quorum is not
+// reached after max retry time, or an AcquireError with the cause otherwise
+//
+// The ttl is only how long the lock is held, while the deadline of ctx is the
+// budget of acquisition, such as a ctx with a timeout of 500ms spends at most
+// 500ms trying to acquire a lock of 10s. Once the deadline passes, the retries
+// stop and ctx.Err() is returned. Each instance is given at most the instance
+// timeout to reply, see WithInstanceTimeout.
 func (r *RedLock) Lock(ctx context.Context, resource string, ttl time.Duration) (time.Duration, error) {
-	val := getRandStr()
-	for i := 0; i < r.retryCount; i++ {
+	l, err := r.Acquire(ctx, resource, ttl)
+	if err != nil {
+		return 0, err
+	}
+	return l.validity, nil
+}
+
+// LockCountingRetries acquires a distribute lock the same as Lock, besides it
+// returns how many attempts the acquisition took, an attempt count above one
+// means the lock was contended or instances failed, which callers may respond
+// to such as by shedding load. A re-entrant acquisition takes no attempt.
+func (r *RedLock) LockCountingRetries(ctx context.Context, resource string, ttl time.Duration) (time.Duration, int, error) {
+	l, err := r.Acquire(ctx, resource, ttl)
+	if err != nil {
+		return 0, 0, err
+	}
+	return l.validity, l.attempts, nil
+}
+
+// LockForDeadline acquires a distribute lock the same as Lock, with the ttl
+// from now to deadline. ErrDeadlinePassed is returned if deadline is past.
+func (r *RedLock) LockForDeadline(ctx context.Context, resource string, deadline time.Time) (time.Duration, error) {
+	ttl := time.Until(deadline)
+	if ttl <= 0 {
+		return 0, ErrDeadlinePassed
+	}
+	return r.Lock(ctx, resource, ttl)
+}
+
+// LockGrant describes an acquired lock in absolute time
+type LockGrant struct {
+	// AcquiredAt is the time that quorum was reached in the successful
+	// attempt, validity is counted from it
+	AcquiredAt time.Time
+	// ExpiresAt is the time that the validity of lock ends
+	ExpiresAt time.Time
+	// Validity is the valid duration from AcquiredAt, the same as Lock returns
+	Validity time.Duration
+}
+
+// LockAt acquires a distribute lock the same as Lock, and returns when the
+// validity of lock expires in absolute time, callers needn't add the validity
+// to time.Now() which is skewed by the time elapsed since acquisition.
+func (r *RedLock) LockAt(ctx context.Context, resource string, ttl time.Duration) (LockGrant, error) {
+	l, err := r.Acquire(ctx, resource, ttl)
+	if err != nil {
+		return LockGrant{}, err
+	}
+	return LockGrant{
+		AcquiredAt: l.acquiredAt,
+		ExpiresAt:  l.acquiredAt.Add(l.validity),
+		Validity:   l.validity,
+	}, nil
+}
+
+// LockWithContext acquires a lock like LockAt, and returns a child context of
+// ctx whose deadline is the ExpiresAt of the grant, so work done with it stops
+// once the validity of lock passes. The cancel func releases the resources of
+// the context only, the lock is still released by UnLock. The deadline is
+// fixed at acquisition, extending the lock doesn't move it, use WithLock to
+// bound work by a lock renewed in background instead.
+func (r *RedLock) LockWithContext(
+	ctx context.Context, resource string, ttl time.Duration,
+) (context.Context, context.CancelFunc, error) {
+	grant, err := r.LockAt(ctx, resource, ttl)
+	if err != nil {
+		return nil, nil, err
+	}
+	lockCtx, cancel := context.WithDeadline(ctx, grant.ExpiresAt)
+	return lockCtx, cancel, nil
+}
+
+// Acquire acquires a distribute lock the same as Lock, and returns a handle
+// carrying the details of the acquisition.
+//
+// ctx only bounds the SETNX round trips of acquisition, the returned handle
+// is not tied to it, each ExtendTo and Unlock of the handle is bounded by the
+// ctx passed to that call. So a lock acquired with a request-scoped ctx can
+// still be extended and released after the request is done.
+func (r *RedLock) Acquire(ctx context.Context, resource string, ttl time.Duration) (*Lock, error) {
+	if r.flight != nil {
+		return r.flight.acquire(r, resource, func() (*Lock, error) {
+			return r.acquireNew(ctx, resource, ttl)
+		})
+	}
+	return r.acquireNew(ctx, resource, ttl)
+}
+
+// acquireNew acquires a distribute lock with a new value
+func (r *RedLock) acquireNew(ctx context.Context, resource string, ttl time.Duration) (*Lock, error) {
+	val, err := r.newValue(ctx, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return r.acquire(ctx, resource, ttl, val, r.retry(), func(ctx context.Context, cli *RedClient) lockResult {
+		return r.lockInstance(ctx, cli, resource, val, ttl)
+	})
+}
+
+// LockIf acquires a distribute lock only if the value of condKey equals to
+// condVal, the check and the SETNX are executed atomically on each instance.
+// ErrConditionFailed is returned if the condition fails on a quorum.
+//
+// condKey is sent to redis as is, on redis cluster it must be in the hash
+// slot of the lock key. With WithHashTag, condKey must carry the same hash
+// tag, such as `{tag}:version`, otherwise it fails with ErrCondKeySlot
+// before sending anything.
+func (r *RedLock) LockIf(
+	ctx context.Context, resource string, ttl time.Duration, condKey, condVal string,
+) (time.Duration, error) {
+	if r.hashTag != "" && hashTagOf(condKey) != r.hashTag {
+		return 0, &AcquireError{resource: resource, Err: fmt.Errorf("%w: %s", ErrCondKeySlot, condKey)}
+	}
+	val, err := r.newValue(ctx, ttl)
+	if err != nil {
+		return 0, err
+	}
+	l, err := r.acquire(ctx, resource, ttl, val, r.retry(), func(ctx context.Context, cli *RedClient) lockResult {
+		return r.lockIfInstance(ctx, cli, resource, val, ttl, condKey, condVal)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return l.validity, nil
+}
+
+// acquire tries to acquire the lock, retrying by the retry strategy
+func (r *RedLock) acquire(
+	ctx context.Context, resource string, ttl time.Duration, val string, retry RetryStrategy, lockFn lockFunc,
+) (*Lock, error) {
+	l, _, err := r.acquireWithResults(ctx, resource, ttl, val, retry, lockFn)
+	return l, err
+}
+
+// acquireWithResults is the same as acquire, besides it returns the
+// per-instance results of the last attempt
+func (r *RedLock) acquireWithResults(
+	ctx context.Context, resource string, ttl time.Duration, val string, retry RetryStrategy, lockFn lockFunc,
+) (*Lock, []lockResult, error) {
+	l, results, err := r.tryAcquire(ctx, resource, ttl, val, retry, lockFn)
+	if err != nil {
+		r.emit(EventAcquireFailed, resource, 0, err)
+	} else {
+		r.heldTTLs.set(resource, l.ttl)
+		r.emit(EventAcquired, resource, l.validity, nil)
+	}
+	return l, results, err
+}
+
+func (r *RedLock) tryAcquire(
+	ctx context.Context, resource string, ttl time.Duration, val string, retry RetryStrategy, lockFn lockFunc,
+) (*Lock, []lockResult, error) {
+	if err := r.checkResource(resource); err != nil {
+		return nil, nil, err
+	}
+	if err := r.checkTTL(ttl); err != nil {
+		return nil, nil, err
+	}
+	if err := r.checkValue(val); err != nil {
+		return nil, nil, err
+	}
+	if err := r.checkDeadline(ctx, resource, retry); err != nil {
+		return nil, nil, err
+	}
+	// acquiring a lock held by ourselves would fail on every attempt
+	elem, err := r.cache.Get(resource)
+	if err != nil {
+		return nil, nil, err
+	}
+	if elem != nil {
+		if !r.reentrant {
+			return nil, nil, &AcquireError{resource: resource, Err: ErrAlreadyHeld}
+		}
+		if r.holdCounts == nil {
+			l, err := r.reenter(ctx, resource, ttl, elem)
+			return l, nil, err
+		}
+		// the lock released meanwhile is acquired afresh
+		if r.holdCounts.enter(r, resource) {
+			l, err := r.reenter(ctx, resource, ttl, elem)
+			if err != nil {
+				r.UnLock(ctx, resource) // nolint:errcheck
+			}
+			return l, nil, err
+		}
+	}
+	defer r.enterInFlight(resource)()
+	if r.retryBudget != nil {
+		r.retryBudget.deposit()
+	}
+	lockFn = r.diagnose(resource, hedgeLock(lockFn, r.hedgeDelay))
+	waiting := r.newWaitFor(resource, val)
+	defer waiting.done()
+	var results []lockResult
+	begin := time.Now()
+	for i := 0; ; i++ {
+		if i > 0 && r.retryBudget != nil && !r.retryBudget.withdraw() {
+			r.countFailure()
+			return nil, results, &AcquireError{resource: resource, Err: ErrRetryBudgetExhausted}
+		}
+		if !r.resourceRate.allow(resource) {
+			r.countFailure()
+			return nil, results, &AcquireError{resource: resource, Err: ErrRateLimited}
+		}
+		if i > 0 && r.expvar != nil {
+			r.expvar.retries.Add(1)
+		}
 		start := time.Now()
-		ctxCancel := int32(0)
-		success := int32(0)
-		cctx, cancel := context.WithTimeout(ctx, ttl)
-		var wg sync.WaitGroup
-		for _, cli := range r.clients {
-			cli := cli
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				locked, err := lockInstance(cctx, cli, resource, val, ttl) // nolint:errcheck
-				if err == context.Canceled {
-					atomic.AddInt32(&ctxCancel, 1)
+		results = r.lockAll(ctx, ttl, lockFn)
+		success, condFailed := 0, 0
+		for _, res := range results {
+			// fast fail, terminate acquiring lock if context is canceled
+			if res.err == context.Canceled {
+				r.countFailure()
+				// the parent context is canceled, release the instances
+				// that were locked with a detached context
+				r.unlockAll(context.Background(), resource, val, r.unlockTimeout)
+				return nil, results, context.Canceled
+			}
+			// a misconfigured cluster node won't succeed by retrying
+			if isClusterRedirect(res.err) {
+				r.countFailure()
+				r.unlockAll(ctx, resource, val, r.unlockTimeout)
+				return nil, results, &AcquireError{
+					resource: resource,
+					Err:      fmt.Errorf("%w: %v", ErrClusterRedirect, res.err),
+				}
+			}
+			if res.err == ErrConditionFailed {
+				condFailed++
+			}
+			if res.locked {
+				success++
+			}
+		}
+
+		validityTime := r.validity(ttl, start)
+		if success >= r.quorum && validityTime > 0 {
+			// the cache counts from now so it never outlasts redis, while the
+			// validity counts from when quorum was reached, which is not
+			// shrunk by slow instances replying after quorum
+			r.cache.Set(resource, val, validityTime)
+			r.holdCounts.acquired(resource)
+			quorumAt := quorumTime(results, r.quorum)
+			validity := time.Duration(r.validityAt(ttl, start, quorumAt))
+			if r.auditHook != nil {
+				r.auditHook.OnAcquired(ctx, resource, val, validity)
+			}
+			if r.expvar != nil {
+				r.expvar.acquires.Add(1)
+				if success == r.quorum {
+					r.expvar.zeroMargin.Add(1)
 				}
-				if locked {
-					atomic.AddInt32(&success, 1)
+			}
+			l := newLock()
+			if l.holders == nil {
+				l.holders = make([]string, 0, success)
+			}
+			for idx, res := range results {
+				if res.locked {
+					l.holders = append(l.holders, r.clients[idx].addr)
 				}
-			}()
+			}
+			l.r = r
+			l.resource = resource
+			l.val = val
+			l.ttl = ttl
+			l.validity = validity
+			l.acquiredAt = quorumAt
+			l.margin = success - r.quorum
+			l.attempts = i + 1
+			return l, results, nil
+		}
+		// the budget of acquisition ran out during the attempt
+		if err := ctx.Err(); err != nil {
+			r.countFailure()
+			r.unlockAll(context.Background(), resource, val, r.unlockTimeout)
+			return nil, results, err
 		}
-		wg.Wait()
-		cancel()
-		// fast fail, terminate acquiring lock if context is canceled
-		if atomic.LoadInt32(&ctxCancel) > int32(0) {
-			return 0, context.Canceled
+		r.unlockAll(ctx, resource, val, r.unlockTimeout)
+		if success >= r.quorum {
+			if r.expvar != nil {
+				r.expvar.noValidity.Add(1)
+			}
+			if r.failFastNoValidity {
+				r.countFailure()
+				return nil, results, &AcquireError{resource: resource, Err: ErrNoValidity}
+			}
 		}
+		// the condition won't change by retrying, fail fast
+		if condFailed >= r.quorum {
+			r.countFailure()
+			return nil, results, &AcquireError{resource: resource, Err: ErrConditionFailed}
+		}
+		delay, ok := retry.Next(i+1, time.Since(begin))
+		if !ok {
+			break
+		}
+		if r.informedBackoff {
+			if wait, known := informedWait(results, r.quorum); known && wait < delay {
+				delay = wait
+			}
+		}
+		if waiting.deadlocked(ctx, delay) {
+			r.countFailure()
+			return nil, results, &AcquireError{resource: resource, Err: ErrPotentialDeadlock}
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			r.countFailure()
+			return nil, results, ctx.Err()
+		case <-timer.C:
+		}
+	}
 
-		drift := int(float64(ttl)*r.driftFactor) + 2
-		costTime := time.Since(start).Nanoseconds()
-		validityTime := int64(ttl) - costTime - int64(drift)
-		if int(success) >= r.quorum && validityTime > 0 {
-			r.cache.Set(resource, val, validityTime)
-			return time.Duration(validityTime), nil
+	r.countFailure()
+	return nil, results, newQuorumError(resource, results, r.quorum)
+}
+
+func newQuorumError(resource string, results []lockResult, quorum int) *QuorumError {
+	qe := &QuorumError{resource: resource, Quorum: quorum, Errs: make([]error, len(results))}
+	for idx, res := range results {
+		if res.locked {
+			qe.Acquired++
 		}
-		cctx, cancel = context.WithTimeout(ctx, ttl)
-		for _, cli := range r.clients {
-			cli := cli
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				unlockInstance(cctx, cli, resource, val) // nolint:errcheck
-			}()
+		qe.Errs[idx] = res.err
+	}
+	return qe
+}
+
+// isClusterRedirect checks whether err is a MOVED or ASK reply
+func isClusterRedirect(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.HasPrefix(msg, "MOVED ") || strings.HasPrefix(msg, "ASK ")
+}
+
+func (r *RedLock) countFailure() {
+	if r.expvar != nil {
+		r.expvar.failures.Add(1)
+	}
+}
+
+// lockAll runs lockFn on all instances concurrently, returns the results in
+// the same order as clients
+func (r *RedLock) lockAll(ctx context.Context, ttl time.Duration, lockFn lockFunc) []lockResult {
+	if r.instanceClasses != nil {
+		return r.lockAllByClass(ctx, ttl, lockFn)
+	}
+	results := make([]lockResult, len(r.clients))
+	cctx, cancel := context.WithTimeout(ctx, r.instanceWait(ttl))
+	defer cancel()
+	var wg sync.WaitGroup
+	for idx, cli := range r.clients {
+		idx, cli := idx, cli
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := lockFn(cctx, cli)
+			res.at = time.Now()
+			results[idx] = res
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// quorumTime returns the time that the quorum-th instance was locked
+func quorumTime(results []lockResult, quorum int) time.Time {
+	locked := make([]time.Time, 0, len(results))
+	for _, res := range results {
+		if res.locked {
+			locked = append(locked, res.at)
 		}
-		wg.Wait()
-		cancel()
-		// Wait a random delay before to retry
-		time.Sleep(time.Duration(rand.Intn(r.retryDelay)) * time.Millisecond)
 	}
+	sort.Slice(locked, func(i, j int) bool { return locked[i].Before(locked[j]) })
+	return locked[quorum-1]
+}
 
-	return 0, ErrAcquireLock
+// unlockAll releases the lock on all instances within timeout, it is used to
+// clean up a failed acquisition
+func (r *RedLock) unlockAll(ctx context.Context, resource, val string, timeout time.Duration) {
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, cli := range r.clients {
+		cli := cli
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.unlockInstance(cctx, cli, resource, val) // nolint:errcheck
+		}()
+	}
+	wg.Wait()
 }
 
-// UnLock releases an acquired lock
-func (r *RedLock) UnLock(ctx context.Context, resource string) error {
+// Extend resets the ttl of an acquired lock, returns the new remaining valid
+// duration, the lock is kept in local cache only if it is extended on quorum.
+func (r *RedLock) Extend(ctx context.Context, resource string, ttl time.Duration) (time.Duration, error) {
+	if err := r.checkResource(resource); err != nil {
+		return 0, err
+	}
+	if err := r.checkTTL(ttl); err != nil {
+		return 0, err
+	}
 	elem, err := r.cache.Get(resource)
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if elem == nil {
-		return nil
+		return 0, ErrLockNotHeld
 	}
-	defer r.cache.Delete(resource)
+	return r.extend(ctx, resource, elem.Val, ttl)
+}
+
+func (r *RedLock) extend(ctx context.Context, resource, val string, ttl time.Duration) (time.Duration, error) {
+	start := time.Now()
+	success := int32(0)
+	cctx, cancel := context.WithTimeout(ctx, r.instanceWait(ttl))
+	defer cancel()
 	var wg sync.WaitGroup
 	for _, cli := range r.clients {
 		cli := cli
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			unlockInstance(ctx, cli, resource, elem.Val) //nolint:errcheck
+			extended, _ := r.extendInstance(cctx, cli, resource, val, ttl)
+			if extended {
+				atomic.AddInt32(&success, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	validityTime := r.validity(ttl, start)
+	if int(success) >= r.quorum && validityTime > 0 {
+		r.cache.Set(resource, val, validityTime)
+		r.heldTTLs.set(resource, ttl)
+		r.emit(EventRenewed, resource, time.Duration(validityTime), nil)
+		return time.Duration(validityTime), nil
+	}
+	return 0, ErrExtendLock
+}
+
+// Owns checks whether the lock of resource is held by this RedLock and not
+// expired according to the local cache, redis is not queried.
+func (r *RedLock) Owns(resource string) bool {
+	elem, err := r.cache.Get(resource)
+	return err == nil && elem != nil
+}
+
+// Inspect reads the raw value of resource from every instance, returns a map
+// from the address of instance to the value, instances without the lock are
+// omitted. The first error of instances is returned along with the values
+// read from the others.
+func (r *RedLock) Inspect(ctx context.Context, resource string) (map[string]string, error) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		values   = make(map[string]string, len(r.clients))
+		firstErr error
+	)
+	for _, cli := range r.clients {
+		cli := cli
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			val, err := cli.cli.Get(ctx, r.redisKey(resource)).Result()
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				values[cli.addr] = val
+			case err != redis.Nil && firstErr == nil:
+				firstErr = err
+			}
+		}()
+	}
+	wg.Wait()
+	return values, firstErr
+}
+
+// UnLock releases an acquired lock, each instance is given at most the unlock
+// timeout to reply, so a hung instance can't stall the release. It returns an
+// UnlockError if the release failed on too many instances for others to
+// acquire the lock on quorum before it expires, the lock is kept in the local
+// cache then, so UnLock can be retried. If the local cache fails to
+// look up the lock, the value of lock is unknown and nothing is released, it
+// returns a CacheError, which is distinct from the lock not held where it
+// returns nil.
+func (r *RedLock) UnLock(ctx context.Context, resource string) error {
+	if err := r.checkResource(resource); err != nil {
+		return err
+	}
+	elem, err := r.releasing(resource)
+	if err != nil || elem == nil {
+		return err
+	}
+	err = r.unlockValue(ctx, resource, elem.Val, time.Now().Add(time.Duration(elem.remaining())))
+	r.releaseDone(resource, err == nil)
+	return err
+}
+
+// releasing returns the lock of resource to be released on redis, or nil if
+// the lock is not held or still used by other local holders
+func (r *RedLock) releasing(resource string) (*LockElem, error) {
+	elem, err := r.releaseLookup(resource)
+	if err != nil || elem == nil {
+		return nil, err
+	}
+	// the lock is still held by the outer re-entrant acquisitions
+	if !r.holdCounts.leave(resource) {
+		return nil, nil
+	}
+	// other local holders of a shared lock are still using it
+	if r.flight != nil && !r.flight.unref(resource) {
+		r.holdCounts.clear(resource)
+		return nil, nil
+	}
+	r.checkHold(resource, elem)
+	return elem, nil
+}
+
+// releaseDone ends the release of resource started by releasing, the lock is
+// removed from cache if released, or kept so the release can be retried
+func (r *RedLock) releaseDone(resource string, released bool) {
+	if released {
+		r.cache.Delete(resource)
+		r.heldTTLs.delete(resource)
+	}
+	r.flight.done(resource, released)
+	r.holdCounts.clear(resource)
+}
+
+// unlockValue releases the lock of resource with val on all instances, the
+// lock expires at expiresAt
+func (r *RedLock) unlockValue(ctx context.Context, resource, val string, expiresAt time.Time) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(r.clients))
+	for idx, cli := range r.clients {
+		idx, cli := idx, cli
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, r.unlockTimeout)
+			defer cancel()
+			_, errs[idx] = r.unlockInstance(cctx, cli, resource, val)
 		}()
 	}
 	wg.Wait()
+	return r.released(ctx, resource, val, expiresAt, errs)
+}
+
+// released reports the release of the lock of resource by the call with
+// ctx, with per-instance errors errs, the failed instances are retried if
+// enabled. It returns an UnlockError if the release failed on too many
+// instances.
+func (r *RedLock) released(ctx context.Context, resource, val string, expiresAt time.Time, errs []error) error {
+	failed := 0
+	for idx, err := range errs {
+		if err != nil {
+			failed++
+			if r.unlockRetry {
+				r.retryUnlock(r.clients[idx], resource, val, expiresAt)
+			}
+		}
+	}
+	// others can still acquire the lock on quorum of the released instances
+	if failed > len(r.clients)-r.quorum {
+		err := &UnlockError{resource: resource, Errs: errs}
+		r.emit(EventReleaseFailed, resource, 0, err)
+		return err
+	}
+	if r.auditHook != nil {
+		r.auditHook.OnReleased(ctx, resource, val)
+	}
+	r.emit(EventReleased, resource, 0, nil)
 	return nil
 }
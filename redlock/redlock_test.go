@@ -2,12 +2,18 @@ package redlock
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -21,6 +27,10 @@ var redisServers = []string{
 	"tcp://127.0.0.1:6381",
 }
 
+// inProcessRedis is set if the redis servers run in the test process, whose
+// goroutines are then counted by runtime.NumGoroutine
+var inProcessRedis bool
+
 func TestBasicLock(t *testing.T) {
 	ctx := context.Background()
 	lock, err := NewRedLock(ctx, redisServers)
@@ -178,6 +188,29 @@ func TestNewRedLockError(t *testing.T) {
 	}
 }
 
+func TestNewRedLockDuplicateAddr(t *testing.T) {
+	ctx := context.Background()
+	testCases := [][]string{
+		{"tcp://127.0.0.1:6379", "tcp://127.0.0.1:6380", "tcp://127.0.0.1:6379"},
+		{"tcp://127.0.0.1:6379", "tcp://127.0.0.1:6380", "tcp://127.0.0.1:6379/0"},
+		{"tcp://127.0.0.1:6379/1", "tcp://127.0.0.1:6380", "tcp://127.0.0.1:6379/2"},
+		{"tcp://localhost:6379", "tcp://127.0.0.1:6380", "tcp://127.0.0.1:6379"},
+		{"tcp://Redis-A:6379", "tcp://redis-a:6379", "tcp://redis-b:6379"},
+		{"tcp://:pass@redis-a:6379", "tcp://redis-a:6379?DialTimeout=1000", "tcp://redis-b:6379"},
+	}
+	for _, addrs := range testCases {
+		_, err := NewRedLock(ctx, addrs)
+		assert.True(t, errors.Is(err, ErrDuplicateAddr), "%v", addrs)
+	}
+
+	cli := redis.NewClient(&redis.Options{Addr: "127.0.0.1:6379"})
+	defer cli.Close()
+	_, err := NewRedLockFromClients(ctx, []*redis.Client{
+		cli, redis.NewClient(&redis.Options{Addr: "127.0.0.1:6380"}), cli,
+	})
+	assert.True(t, errors.Is(err, ErrDuplicateAddr))
+}
+
 func TestRedlockSetter(t *testing.T) {
 	ctx := context.Background()
 	lock, err := NewRedLock(ctx, redisServers)
@@ -196,6 +229,66 @@ func TestRedlockSetter(t *testing.T) {
 	assert.Equal(t, retryDelay+100, lock.retryDelay)
 }
 
+func TestLockOptions(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers,
+		WithRetryCount(3),
+		WithRetryDelay(50),
+		WithDriftFactor(0.02),
+		WithMaxTTL(time.Minute),
+		WithCacheType(CacheTypeFreeCache),
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, lock.retryCount)
+	assert.Equal(t, 50, lock.retryDelay)
+	assert.Equal(t, 0.02, lock.driftFactor)
+	assert.Equal(t, time.Minute, lock.maxTTL)
+	assert.IsType(t, &FreeCache{}, lock.cache)
+
+	for _, opt := range []Option{
+		WithRetryCount(0),
+		WithRetryDelay(-1),
+		WithDriftFactor(1),
+		WithAbsoluteDrift(-time.Millisecond),
+		WithMaxTTL(-time.Second),
+		WithGCInterval(0),
+		CacheOption(func(o *CacheOptions) {
+			o.CacheType = CacheTypeFreeCache
+			o.CacheSize = 0
+		}),
+	} {
+		_, err = NewRedLock(ctx, redisServers, opt)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestMockAbsoluteDrift(t *testing.T) {
+	start := time.Now()
+	short, long := 100*time.Millisecond, 10*time.Second
+	drift := func(lock *RedLock, ttl time.Duration) time.Duration {
+		return ttl - time.Duration(lock.validityAt(ttl, start, start))
+	}
+
+	// the drift by factor is proportional to ttl
+	lock := newMockRedLockWithOptions(t, []redisCmdable{&mockCmdable{}}, WithDriftFactor(0.01))
+	assert.Equal(t, time.Millisecond+2, drift(lock, short))
+	assert.Equal(t, 100*time.Millisecond+2, drift(lock, long))
+
+	// the absolute drift is the same regardless of ttl
+	lock = newMockRedLockWithOptions(t, []redisCmdable{&mockCmdable{}}, WithDriftFactor(0), WithAbsoluteDrift(5*time.Millisecond))
+	assert.Equal(t, 5*time.Millisecond+2, drift(lock, short))
+	assert.Equal(t, 5*time.Millisecond+2, drift(lock, long))
+
+	// combined
+	lock = newMockRedLockWithOptions(t, []redisCmdable{&mockCmdable{}}, WithDriftFactor(0.01), WithAbsoluteDrift(5*time.Millisecond))
+	assert.Equal(t, 6*time.Millisecond+2, drift(lock, short))
+	assert.Equal(t, 105*time.Millisecond+2, drift(lock, long))
+
+	validity, err := lock.Lock(context.Background(), "foo", short)
+	assert.Nil(t, err)
+	assert.Less(t, int64(validity), int64(short-6*time.Millisecond))
+}
+
 func TestAcquireLockFailed(t *testing.T) {
 	ctx := context.Background()
 	servers := make([]string, 0, len(redisServers))
@@ -258,7 +351,7 @@ func TestLockContext(t *testing.T) {
 	wg.Wait()
 }
 
-func testKVCacheWrap(t *testing.T, opts ...CacheOption) {
+func testKVCacheWrap(t *testing.T, opts ...Option) {
 	ctx := context.Background()
 	var wg sync.WaitGroup
 	for i := 0; i < 4; i++ {
@@ -290,3 +383,590 @@ func TestKVCache(t *testing.T) {
 		WithCacheSize(50*1024*1024),
 	)
 }
+
+func TestWaitReplicas(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithWaitReplicas(1, 10*time.Millisecond))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, lock.waitReplicas)
+	assert.Equal(t, 10*time.Millisecond, lock.waitTimeout)
+	lock.SetRetryCount(1)
+
+	// test redis servers run without replica, WAIT never confirms
+	_, err = lock.Lock(ctx, "foo", 200*time.Millisecond)
+	assert.True(t, errors.Is(err, ErrAcquireLock))
+}
+
+func TestLockIf(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+
+	clis := make([]*redis.Client, 0, len(redisServers))
+	for _, server := range redisServers {
+		opts, err := parseConnString(server)
+		assert.Nil(t, err)
+		cli := redis.NewClient(opts)
+		assert.Nil(t, cli.Set(ctx, "foo_version", "1", 0).Err())
+		clis = append(clis, cli)
+	}
+	defer func() {
+		for _, cli := range clis {
+			cli.Del(ctx, "foo_version")
+		}
+	}()
+
+	_, err = lock.LockIf(ctx, "foo", 200*time.Millisecond, "foo_version", "2")
+	assert.True(t, errors.Is(err, ErrConditionFailed))
+	assert.Zero(t, lock.cache.Size())
+
+	_, err = lock.LockIf(ctx, "foo", 200*time.Millisecond, "foo_version", "1")
+	assert.Nil(t, err)
+	err = lock.UnLock(ctx, "foo")
+	assert.Nil(t, err)
+
+	// the condition key must carry the hash tag of lock key
+	tagged, err := NewRedLock(ctx, redisServers, WithHashTag("locks"))
+	assert.Nil(t, err)
+	defer tagged.Close()
+	for _, key := range []string{"foo_version", "{other}:foo_version", "{}:{locks}"} {
+		_, err = tagged.LockIf(ctx, "foo", 200*time.Millisecond, key, "1")
+		assert.True(t, errors.Is(err, ErrCondKeySlot), key)
+	}
+	for _, cli := range clis {
+		assert.Nil(t, cli.Set(ctx, "{locks}:foo_version", "1", 0).Err())
+		defer cli.Del(ctx, "{locks}:foo_version")
+	}
+	_, err = tagged.LockIf(ctx, "foo", 200*time.Millisecond, "{locks}:foo_version", "1")
+	assert.Nil(t, err)
+	assert.Nil(t, tagged.UnLock(ctx, "foo"))
+}
+
+// newHungServer starts a tcp server that accepts connections but never replies
+func newHungServer(t *testing.T) (string, func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	var conns []net.Conn
+	var mu sync.Mutex
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+	return ln.Addr().String(), func() {
+		ln.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	}
+}
+
+func TestUnLockTimeout(t *testing.T) {
+	ctx := context.Background()
+	timeout := 100 * time.Millisecond
+	lock, err := NewRedLock(ctx, redisServers, WithUnlockTimeout(timeout))
+	assert.Nil(t, err)
+
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+
+	addr, stop := newHungServer(t)
+	defer stop()
+	hung := lock.clients[0]
+	lock.clients[0] = &RedClient{addr: addr, cli: redis.NewClient(&redis.Options{
+		Addr: addr, ReadTimeout: time.Minute, WriteTimeout: time.Minute,
+	})}
+	defer func() {
+		lock.clients[0] = hung
+		rawClient(hung).Del(ctx, "foo")
+	}()
+
+	start := time.Now()
+	err = lock.UnLock(ctx, "foo")
+	assert.Nil(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(timeout+500*time.Millisecond))
+	assert.Zero(t, lock.cache.Size())
+}
+
+func TestExtend(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+
+	_, err = lock.Extend(ctx, "foo", time.Second)
+	assert.Equal(t, ErrLockNotHeld, err)
+
+	_, err = lock.Lock(ctx, "foo", 100*time.Millisecond)
+	assert.Nil(t, err)
+	validity, err := lock.Extend(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(500*time.Millisecond))
+	time.Sleep(150 * time.Millisecond)
+	for _, cli := range lock.clients {
+		pttl, err := rawClient(cli).PTTL(ctx, "foo").Result()
+		assert.Nil(t, err)
+		assert.Greater(t, int64(pttl), int64(500*time.Millisecond))
+	}
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+}
+
+func TestMaxTTL(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+
+	lock.SetMaxTTL(-time.Second)
+	assert.Zero(t, lock.maxTTL)
+	lock.SetMaxTTL(time.Second)
+	assert.Equal(t, time.Second, lock.maxTTL)
+
+	_, err = lock.Lock(ctx, "foo", time.Minute)
+	assert.True(t, errors.Is(err, ErrTTLTooLong))
+	assert.Zero(t, lock.cache.Size())
+
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	_, err = lock.Extend(ctx, "foo", time.Minute)
+	assert.True(t, errors.Is(err, ErrTTLTooLong))
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+}
+
+func TestLockInstance(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	cli := lock.clients[0]
+	defer rawClient(cli).Del(ctx, "foo")
+
+	res := lock.lockInstance(ctx, cli, "foo", "val", time.Second)
+	assert.Equal(t, lockResult{locked: true}, res)
+
+	// contention is not an error
+	res = lock.lockInstance(ctx, cli, "foo", "val2", time.Second)
+	assert.Equal(t, lockResult{}, res)
+
+	// real fault is propagated
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	res = lock.lockInstance(cctx, cli, "bar", "val", time.Second)
+	assert.Equal(t, lockResult{err: context.Canceled}, res)
+}
+
+func TestSetNXGet(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithSetNXGet())
+	assert.Nil(t, err)
+	cli := lock.clients[0]
+	defer rawClient(cli).Del(ctx, "foo")
+
+	res := lock.lockInstance(ctx, cli, "foo", "val", time.Second)
+	assert.Equal(t, lockResult{locked: true}, res)
+	res = lock.lockInstance(ctx, cli, "foo", "val2", time.Second)
+	assert.False(t, res.locked)
+	assert.Nil(t, res.err)
+	if atomic.LoadInt32(&cli.noSetNXGet) == 0 {
+		assert.Equal(t, "val", res.holder)
+	}
+}
+
+func TestLockRepeatedCancel(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	holder, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	_, err = holder.Lock(ctx, "foo", 5*time.Second)
+	assert.Nil(t, err)
+	defer holder.UnLock(ctx, "foo")
+
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		cctx, cancel := context.WithCancel(ctx)
+		go func(d time.Duration) {
+			time.Sleep(d)
+			cancel()
+		}(time.Duration(i) * time.Millisecond)
+		start := time.Now()
+		_, err = lock.Lock(cctx, "foo", time.Second)
+		assert.Equal(t, context.Canceled, err)
+		// the retry delay is interrupted by cancellation
+		assert.Less(t, int64(time.Since(start)), int64(100*time.Millisecond))
+	}
+	if inProcessRedis {
+		return
+	}
+	// all goroutines spawned by Lock are exited
+	for i := 0; i < 50 && runtime.NumGoroutine() > goroutines; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines)
+}
+
+func TestHashTag(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithHashTag("locks"))
+	assert.Nil(t, err)
+	assert.Equal(t, "{locks}:foo", lock.redisKey("foo"))
+
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	for _, cli := range lock.clients {
+		exists, err := rawClient(cli).Exists(ctx, "{locks}:foo").Result()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), exists)
+	}
+	_, err = lock.Extend(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+	for _, cli := range lock.clients {
+		exists, err := rawClient(cli).Exists(ctx, "{locks}:foo").Result()
+		assert.Nil(t, err)
+		assert.Zero(t, exists)
+	}
+}
+
+func TestLockForDeadline(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+
+	_, err = lock.LockForDeadline(ctx, "foo", time.Now().Add(-time.Millisecond))
+	assert.Equal(t, ErrDeadlinePassed, err)
+
+	ttl := 500 * time.Millisecond
+	validity, err := lock.LockForDeadline(ctx, "foo", time.Now().Add(ttl))
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(0))
+	assert.Less(t, int64(validity), int64(ttl))
+	for _, cli := range lock.clients {
+		pttl, err := rawClient(cli).PTTL(ctx, "foo").Result()
+		assert.Nil(t, err)
+		assert.LessOrEqual(t, int64(pttl), int64(ttl))
+	}
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+}
+
+func TestMockLockAt(t *testing.T) {
+	ctx := context.Background()
+	lock := newMockRedLock(t, &mockCmdable{}, &mockCmdable{}, &mockCmdable{})
+
+	ttl := time.Second
+	before := time.Now()
+	grant, err := lock.LockAt(ctx, "foo", ttl)
+	after := time.Now()
+	assert.Nil(t, err)
+	assert.False(t, grant.AcquiredAt.Before(before))
+	assert.False(t, grant.AcquiredAt.After(after))
+	assert.Equal(t, grant.Validity, grant.ExpiresAt.Sub(grant.AcquiredAt))
+	assert.Greater(t, int64(grant.Validity), int64(0))
+	assert.Less(t, int64(grant.Validity), int64(ttl))
+
+	failed := &mockCmdable{
+		setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+			return false, nil
+		},
+	}
+	lock = newMockRedLock(t, failed, failed, &mockCmdable{})
+	lock.SetRetryCount(1)
+	grant, err = lock.LockAt(ctx, "foo", ttl)
+	assert.NotNil(t, err)
+	assert.Equal(t, LockGrant{}, grant)
+}
+
+func TestMockLockWithContext(t *testing.T) {
+	ctx := context.Background()
+	lock := newMockRedLock(t, &mockCmdable{}, &mockCmdable{}, &mockCmdable{})
+
+	ttl := 100 * time.Millisecond
+	before := time.Now()
+	lockCtx, cancel, err := lock.LockWithContext(ctx, "foo", ttl)
+	assert.Nil(t, err)
+	defer cancel()
+	deadline, ok := lockCtx.Deadline()
+	assert.True(t, ok)
+	assert.True(t, deadline.After(before))
+	assert.False(t, deadline.After(before.Add(ttl)))
+	select {
+	case <-lockCtx.Done():
+		assert.Equal(t, context.DeadlineExceeded, lockCtx.Err())
+		assert.False(t, time.Now().Before(deadline))
+	case <-time.After(time.Second):
+		t.Fatal("context is not canceled when the validity passes")
+	}
+
+	// the parent cancels the child
+	parent, parentCancel := context.WithCancel(ctx)
+	lockCtx, cancel, err = lock.LockWithContext(parent, "bar", time.Second)
+	assert.Nil(t, err)
+	defer cancel()
+	parentCancel()
+	<-lockCtx.Done()
+	assert.Equal(t, context.Canceled, lockCtx.Err())
+
+	failed := &mockCmdable{
+		setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+			return false, nil
+		},
+	}
+	lock = newMockRedLock(t, failed, failed, &mockCmdable{})
+	lock.SetRetryCount(1)
+	lockCtx, cancel, err = lock.LockWithContext(ctx, "foo", ttl)
+	assert.NotNil(t, err)
+	assert.Nil(t, lockCtx)
+	assert.Nil(t, cancel)
+}
+
+func TestMockValidityFromQuorum(t *testing.T) {
+	ctx := context.Background()
+	delayed := func(delay time.Duration) *mockCmdable {
+		return &mockCmdable{
+			setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+				time.Sleep(delay)
+				return true, nil
+			},
+		}
+	}
+	slow := 300 * time.Millisecond
+	lock := newMockRedLock(t, delayed(0), delayed(10*time.Millisecond), delayed(slow))
+
+	ttl := time.Second
+	start := time.Now()
+	l, err := lock.Acquire(ctx, "foo", ttl)
+	cost := time.Since(start)
+	assert.Nil(t, err)
+	assert.GreaterOrEqual(t, int64(cost), int64(slow))
+	// the slow instance replying after quorum doesn't shrink validity
+	assert.Greater(t, int64(l.Validity()), int64(ttl-slow))
+	assert.Less(t, int64(l.Validity()), int64(ttl))
+	assert.True(t, l.acquiredAt.Before(start.Add(slow)))
+	assert.False(t, l.acquiredAt.Add(l.Validity()).After(start.Add(ttl)))
+	// the local cache counts from now, it never outlasts redis
+	elem, err := lock.cache.Get("foo")
+	assert.Nil(t, err)
+	assert.LessOrEqual(t, elem.remaining(), int64(ttl-cost))
+}
+
+func TestMockLockCountingRetries(t *testing.T) {
+	ctx := context.Background()
+	contended := func() *mockCmdable {
+		var calls int32
+		return &mockCmdable{
+			setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+				// held by others in the first two attempts
+				return atomic.AddInt32(&calls, 1) > 2, nil
+			},
+		}
+	}
+	lock := newMockRedLockWithOptions(t, []redisCmdable{contended(), contended(), &mockCmdable{}},
+		WithRetryDelay(1), WithReentrant())
+	validity, attempts, err := lock.LockCountingRetries(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(0))
+	assert.Equal(t, 3, attempts)
+
+	// a re-entrant acquisition takes no attempt
+	l, err := lock.Acquire(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, l.Attempts())
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+
+	l, err = lock.Acquire(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, l.Attempts())
+}
+
+func TestMockResourceValidator(t *testing.T) {
+	ctx := context.Background()
+	errNoNamespace := errors.New("resource must be namespaced")
+	var attempts int32
+	instance := func() *mockCmdable {
+		return &mockCmdable{
+			setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+				atomic.AddInt32(&attempts, 1)
+				return true, nil
+			},
+		}
+	}
+	lock := newMockRedLockWithOptions(t, []redisCmdable{instance(), instance(), instance()},
+		WithResourceValidator(func(resource string) error {
+			if !strings.Contains(resource, ":") {
+				return errNoNamespace
+			}
+			return nil
+		}))
+
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	assert.Equal(t, errNoNamespace, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&attempts))
+	_, err = lock.Extend(ctx, "foo", time.Second)
+	assert.Equal(t, errNoNamespace, err)
+	assert.Equal(t, errNoNamespace, lock.UnLock(ctx, "foo"))
+
+	_, err = lock.Lock(ctx, "team:foo", time.Second)
+	assert.Nil(t, err)
+	_, err = lock.Extend(ctx, "team:foo", time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnLock(ctx, "team:foo"))
+}
+
+func TestMockReentrant(t *testing.T) {
+	ctx := context.Background()
+	attempts := int32(0)
+	instance := func() *mockCmdable {
+		return &mockCmdable{
+			setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+				atomic.AddInt32(&attempts, 1)
+				return true, nil
+			},
+		}
+	}
+
+	lock := newMockRedLock(t, instance(), instance(), instance())
+	assert.False(t, lock.Owns("foo"))
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.True(t, lock.Owns("foo"))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+
+	// not re-entrant by default, fails without touching redis
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.True(t, errors.Is(err, ErrAlreadyHeld))
+	var ae *AcquireError
+	assert.True(t, errors.As(err, &ae))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+	assert.False(t, lock.Owns("foo"))
+
+	atomic.StoreInt32(&attempts, 0)
+	lock = newMockRedLockWithOptions(t, []redisCmdable{instance(), instance(), instance()}, WithReentrant())
+	l, err := lock.Acquire(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	// the held lock is returned with its remaining validity
+	l2, err := lock.Acquire(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+	assert.Equal(t, l.Value(), l2.Value())
+	assert.Greater(t, int64(l2.Validity()), int64(0))
+	assert.LessOrEqual(t, int64(l2.Validity()), int64(l.Validity()))
+	assert.Nil(t, l2.Unlock(ctx))
+	assert.False(t, lock.Owns("foo"))
+}
+
+func TestNewRedLockFromClusterClients(t *testing.T) {
+	ctx := context.Background()
+	clis := make([]*redis.ClusterClient, 0, 3)
+	for i := 0; i < 3; i++ {
+		clis = append(clis, redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs: []string{fmt.Sprintf("10.0.%d.1:6379", i), fmt.Sprintf("10.0.%d.2:6379", i)},
+		}))
+	}
+	_, err := NewRedLockFromClusterClients(ctx, clis[:2])
+	assert.NotNil(t, err)
+	lock, err := NewRedLockFromClusterClients(ctx, clis)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, lock.quorum)
+	assert.Equal(t, "10.0.1.1:6379,10.0.1.2:6379", lock.clients[1].addr)
+}
+
+func TestKeyHasher(t *testing.T) {
+	ctx := context.Background()
+	hasher := func(resource string) string {
+		h := sha256.Sum256([]byte(resource))
+		return hex.EncodeToString(h[:])
+	}
+	resource := "https://example.com/a/very/long/resource?with=sensitive&query=1"
+	key := hasher(resource)
+	lock, err := NewRedLock(ctx, redisServers, WithKeyHasher(hasher), WithHashTag("locks"))
+	assert.Nil(t, err)
+	assert.Equal(t, "{locks}:"+key, lock.redisKey(resource))
+
+	l, err := lock.Acquire(ctx, resource, time.Second)
+	assert.Nil(t, err)
+	values, err := lock.Inspect(ctx, resource)
+	assert.Nil(t, err)
+	assert.Len(t, values, len(redisServers))
+	for _, cli := range lock.clients {
+		exists, err := rawClient(cli).Exists(ctx, "{locks}:"+key).Result()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), exists)
+	}
+	// the cache keeps the original resource name
+	elem, err := lock.cache.Get(resource)
+	assert.Nil(t, err)
+	assert.Equal(t, l.val, elem.Val)
+	_, err = lock.Extend(ctx, resource, time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnLock(ctx, resource))
+	values, err = lock.Inspect(ctx, resource)
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+}
+
+func TestNewRedLockFromClients(t *testing.T) {
+	ctx := context.Background()
+	clis := make([]*redis.Client, 0, len(redisServers))
+	for _, server := range redisServers {
+		opts, err := parseConnString(server)
+		assert.Nil(t, err)
+		clis = append(clis, redis.NewClient(opts))
+	}
+	_, err := NewRedLockFromClients(ctx, clis[:2])
+	assert.NotNil(t, err)
+
+	lock, err := NewRedLockFromClients(ctx, clis)
+	assert.Nil(t, err)
+	l, err := lock.Acquire(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"127.0.0.1:6379", "127.0.0.1:6380", "127.0.0.1:6381"}, l.Holders())
+	assert.Nil(t, l.Unlock(ctx))
+}
+
+func TestCacheNeverOutlastsRedis(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, 200*time.Millisecond, grantedTTL(200*time.Millisecond+999*time.Microsecond))
+	assert.Equal(t, 500*time.Microsecond, grantedTTL(500*time.Microsecond))
+
+	for _, cacheType := range []string{CacheTypeSimple, CacheTypeFreeCache} {
+		lock, err := NewRedLock(ctx, redisServers, WithCacheType(cacheType))
+		assert.Nil(t, err)
+		// The deadline below is bounded from the start of the test, while the
+		// cache counts from the start of the acquisition attempt and stamps
+		// its entry a little after the validity is computed, the drift must
+		// cover both gaps, which take a few hundred microseconds under the
+		// race detector. So the drift is 500us, rather than a factor of ttl
+		// that gives only tens of microseconds on a few seconds ttl, while it
+		// is still smaller than the truncated sub-millisecond part of ttl,
+		// so a cache counting from the untruncated ttl would outlast redis.
+		for _, ttl := range []time.Duration{
+			9*time.Second + 999*time.Microsecond,
+			10*time.Second + 999*time.Microsecond,
+			time.Second,
+		} {
+			lock.driftFactor = float64(500*time.Microsecond) / float64(ttl)
+			start := time.Now()
+			_, err = lock.Lock(ctx, "foo", ttl)
+			assert.Nil(t, err)
+			elem, err := lock.cache.Get("foo")
+			assert.Nil(t, err)
+			assert.NotNil(t, elem)
+			// every redis key is set after start with PX of ttl in millisecond,
+			// cache must expire no later than the earliest possible redis expiry.
+			deadline := elem.Ts.Add(time.Duration(elem.Expiry))
+			assert.False(t, deadline.After(start.Add(grantedTTL(ttl))), cacheType)
+			for _, cli := range lock.clients {
+				pttl, err := rawClient(cli).PTTL(ctx, "foo").Result()
+				assert.Nil(t, err)
+				assert.LessOrEqual(t, int64(pttl), int64(grantedTTL(ttl)), cacheType)
+			}
+			assert.Nil(t, lock.UnLock(ctx, "foo"))
+		}
+	}
+}
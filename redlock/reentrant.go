@@ -0,0 +1,125 @@
+package redlock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ReentrantTTLPolicy decides how a re-entrant acquisition reconciles its ttl
+// with the lock already held
+type ReentrantTTLPolicy int
+
+// re-entrant ttl policies
+const (
+	// ReentrantTTLKeep returns the held lock unchanged, ignoring the ttl
+	ReentrantTTLKeep ReentrantTTLPolicy = iota
+	// ReentrantTTLReject fails with ErrTTLMismatch if the ttl differs from
+	// the one the lock was acquired or last extended with, a lock whose ttl
+	// is unknown, such as one imported by ImportCache, is not rejected
+	ReentrantTTLReject
+	// ReentrantTTLMin shortens the lock to the ttl if the ttl is shorter than
+	// the remaining validity, which is the safest choice
+	ReentrantTTLMin
+	// ReentrantTTLMax extends the lock to the ttl if the ttl is longer than
+	// the remaining validity
+	ReentrantTTLMax
+)
+
+// WithReentrantTTLPolicy sets how a re-entrant acquisition with a ttl that
+// differs from the held lock is handled, it is effective with WithReentrant.
+// ReentrantTTLKeep is used by default.
+func WithReentrantTTLPolicy(policy ReentrantTTLPolicy) LockOption {
+	return func(r *RedLock) {
+		r.reentrantTTL = policy
+		if policy == ReentrantTTLReject && r.heldTTLs == nil {
+			r.heldTTLs = newHeldTTLs()
+		}
+	}
+}
+
+// heldTTLs records the ttl that each held lock was acquired or last extended
+// with, which is not kept by the cache
+type heldTTLs struct {
+	sync.Mutex
+	ttls map[string]time.Duration
+}
+
+func newHeldTTLs() *heldTTLs {
+	return &heldTTLs{ttls: make(map[string]time.Duration)}
+}
+
+// tracksHeldTTLs returns whether r records the held ttls, which is needed by
+// the re-entrant acquisitions
+func (r *RedLock) tracksHeldTTLs() bool {
+	return r.reentrant || r.reentrantTTL == ReentrantTTLReject
+}
+
+func (h *heldTTLs) set(resource string, ttl time.Duration) {
+	if h == nil {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	h.ttls[resource] = ttl
+}
+
+// get returns the held ttl of resource, or zero if it is unknown
+func (h *heldTTLs) get(resource string) time.Duration {
+	if h == nil {
+		return 0
+	}
+	h.Lock()
+	defer h.Unlock()
+	return h.ttls[resource]
+}
+
+func (h *heldTTLs) delete(resource string) {
+	if h == nil {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+	delete(h.ttls, resource)
+}
+
+// reenter returns the held lock elem of resource for a re-entrant
+// acquisition with ttl, reconciling the ttl by the re-entrant ttl policy. The
+// returned Lock carries the ttl the lock is held with, which is ttl only if
+// the lock is extended to it, or unknown, such as a lock imported to cache.
+func (r *RedLock) reenter(ctx context.Context, resource string, ttl time.Duration, elem *LockElem) (*Lock, error) {
+	validity := time.Duration(elem.remaining())
+	held := r.heldTTLs.get(resource)
+	extend := false
+	switch r.reentrantTTL {
+	case ReentrantTTLReject:
+		// the lock without a known ttl is not rejected
+		if held != 0 && held != ttl {
+			return nil, &AcquireError{resource: resource, Err: fmt.Errorf("%w: %s != %s", ErrTTLMismatch, ttl, held)}
+		}
+	case ReentrantTTLMin:
+		extend = ttl < validity
+	case ReentrantTTLMax:
+		extend = ttl > validity
+	}
+	if extend {
+		var err error
+		validity, err = r.extend(ctx, resource, elem.Val, ttl)
+		if err != nil {
+			return nil, &AcquireError{resource: resource, Err: err}
+		}
+		held = ttl
+	}
+	if held == 0 {
+		held = ttl
+	}
+	return &Lock{
+		r:          r,
+		resource:   resource,
+		val:        elem.Val,
+		ttl:        held,
+		validity:   validity,
+		acquiredAt: time.Now(),
+	}, nil
+}
@@ -0,0 +1,94 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockReentrantTTLPolicy(t *testing.T) {
+	ctx := context.Background()
+	// extended is the last ttl in millisecond the lock is extended to
+	extended := int64(0)
+	instance := func() *mockCmdable {
+		return &mockCmdable{
+			eval: func(_ context.Context, script string, _ []string, args ...interface{}) (interface{}, error) {
+				if strings.Contains(script, "pexpire") {
+					atomic.StoreInt64(&extended, args[1].(int64))
+				}
+				return int64(1), nil
+			},
+		}
+	}
+	newLock := func(policy ReentrantTTLPolicy) *RedLock {
+		atomic.StoreInt64(&extended, 0)
+		return newMockRedLockWithOptions(t, []redisCmdable{instance(), instance(), instance()},
+			WithReentrant(), WithReentrantTTLPolicy(policy))
+	}
+
+	// keep ignores the ttl
+	lock := newLock(ReentrantTTLKeep)
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	validity, err := lock.Lock(ctx, "foo", 100*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(100*time.Millisecond))
+	l, err := lock.Acquire(ctx, "foo", 2*time.Second)
+	assert.Nil(t, err)
+	assert.Zero(t, atomic.LoadInt64(&extended))
+	// the handle carries the held ttl rather than the ignored one
+	assert.Equal(t, time.Second, l.TTL())
+
+	// reject fails on a ttl other than the held one, which is updated by extend
+	lock = newLock(ReentrantTTLReject)
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	_, err = lock.Lock(ctx, "foo", 2*time.Second)
+	assert.True(t, errors.Is(err, ErrTTLMismatch))
+	_, err = lock.Extend(ctx, "foo", 2*time.Second)
+	assert.Nil(t, err)
+	_, err = lock.Lock(ctx, "foo", 2*time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+	assert.Empty(t, lock.heldTTLs.ttls)
+	// the lock without a known ttl, such as an imported one, is not rejected
+	_, err = lock.cache.Set("bar", "imported", int64(time.Second))
+	assert.Nil(t, err)
+	l, err = lock.Acquire(ctx, "bar", 2*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, 2*time.Second, l.TTL())
+
+	// min shortens the lock to a shorter ttl only
+	lock = newLock(ReentrantTTLMin)
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	_, err = lock.Lock(ctx, "foo", 2*time.Second)
+	assert.Nil(t, err)
+	assert.Zero(t, atomic.LoadInt64(&extended))
+	validity, err = lock.Lock(ctx, "foo", 100*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Less(t, int64(validity), int64(100*time.Millisecond))
+	assert.Equal(t, int64(100), atomic.LoadInt64(&extended))
+
+	// max extends the lock to a longer ttl only
+	lock = newLock(ReentrantTTLMax)
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	l, err = lock.Acquire(ctx, "foo", 100*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Zero(t, atomic.LoadInt64(&extended))
+	assert.Equal(t, time.Second, l.TTL())
+	l, err = lock.Acquire(ctx, "foo", 2*time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, 2*time.Second, l.TTL())
+	validity = l.Validity()
+	assert.Greater(t, int64(validity), int64(time.Second))
+	assert.Equal(t, int64(2000), atomic.LoadInt64(&extended))
+}
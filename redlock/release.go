@@ -0,0 +1,100 @@
+package redlock
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// heldLock is a lock to be released by ReleaseAll
+type heldLock struct {
+	resource  string
+	val       string
+	expiresAt time.Time
+}
+
+// ReleaseAll releases the locks of resources held by r, the resources not
+// held are skipped. An invalid resource fails it before any lock is released. The unlock scripts of all resources are sent to each
+// instance in a single pipeline, so releasing many locks costs one round trip
+// per instance rather than one per lock, and each instance is given the
+// unlock timeout for the whole pipeline. A LockStore other than redis is
+// unlocked key by key within the timeout. The locks are released
+// independently, it returns the error of the first resource that failed to
+// be released, which is an UnlockError if the release failed on too many
+// instances, or a CacheError if the lock failed to be looked up in cache. A
+// lock failed to be released is kept in the local cache, so it can be
+// released again.
+func (r *RedLock) ReleaseAll(ctx context.Context, resources ...string) error {
+	// all resources are checked before any lock is left
+	for _, resource := range resources {
+		if err := r.checkResource(resource); err != nil {
+			return err
+		}
+	}
+	held := make([]heldLock, 0, len(resources))
+	// the first lock failed to be looked up in cache, the others are still
+	// released
+	var cacheErr error
+	for _, resource := range resources {
+		elem, err := r.releasing(resource)
+		if err != nil {
+			if cacheErr == nil {
+				cacheErr = err
+			}
+			continue
+		}
+		if elem == nil {
+			continue
+		}
+		held = append(held, heldLock{
+			resource:  resource,
+			val:       elem.Val,
+			expiresAt: time.Now().Add(time.Duration(elem.remaining())),
+		})
+	}
+	if len(held) == 0 {
+		return cacheErr
+	}
+	keys := make([][]string, len(held))
+	args := make([][]interface{}, len(held))
+	for idx, l := range held {
+		keys[idx], args[idx] = r.unlockArgs(r.redisKey(l.resource), l.val)
+	}
+
+	// errs[i][j] is the error of releasing held[i] on instance j
+	errs := make([][]error, len(held))
+	for idx := range errs {
+		errs[idx] = make([]error, len(r.clients))
+	}
+	var wg sync.WaitGroup
+	for idx, cli := range r.clients {
+		idx, cli := idx, cli
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cctx, cancel := context.WithTimeout(ctx, r.unlockTimeout)
+			defer cancel()
+			pipeliner, ok := cli.cli.(redisPipeliner)
+			if !ok {
+				for i, l := range held {
+					_, errs[i][idx] = r.unlockInstance(cctx, cli, l.resource, l.val)
+				}
+				return
+			}
+			for i, cmd := range r.matcher.script.runPipelined(cctx, pipeliner, keys, args) {
+				errs[i][idx] = cmd.Err()
+			}
+		}()
+	}
+	wg.Wait()
+
+	firstErr := cacheErr
+	for idx, l := range held {
+		err := r.released(ctx, l.resource, l.val, l.expiresAt, errs[idx])
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		r.releaseDone(l.resource, err == nil)
+	}
+	return firstErr
+}
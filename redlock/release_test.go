@@ -0,0 +1,148 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseAll(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithLeaseMetadata())
+	assert.Nil(t, err)
+	other, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+
+	resources := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		resource := fmt.Sprintf("release_all_%d", i)
+		_, err = lock.Lock(ctx, resource, 5*time.Second)
+		assert.Nil(t, err)
+		resources = append(resources, resource)
+	}
+	_, err = other.Lock(ctx, "release_all_other", 5*time.Second)
+	assert.Nil(t, err)
+	defer other.UnLock(ctx, "release_all_other") // nolint:errcheck
+
+	// the first round misses the script cache and falls back to EVAL
+	assert.Nil(t, lock.ReleaseAll(ctx, resources[:5]...))
+	assert.Nil(t, lock.ReleaseAll(ctx, append(resources[5:], "release_all_other", "not_held")...))
+	assert.Equal(t, 0, lock.cache.Size())
+	for _, cli := range lock.clients {
+		for _, resource := range resources {
+			n, err := rawClient(cli).Exists(ctx, resource, resource+leaseSuffix).Result()
+			assert.Nil(t, err)
+			assert.Equal(t, int64(0), n, resource)
+		}
+		// the lock held by others is kept
+		n, err := rawClient(cli).Exists(ctx, "release_all_other").Result()
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), n)
+	}
+
+	assert.Nil(t, lock.ReleaseAll(ctx))
+}
+
+// failingCache is a KVCache whose Get fails on the keys in failing
+type failingCache struct {
+	KVCache
+	failing map[string]bool
+}
+
+func (c *failingCache) Get(key string) (*LockElem, error) {
+	if c.failing[key] {
+		return nil, errors.New("injected cache error")
+	}
+	return c.KVCache.Get(key)
+}
+
+func TestReleaseCacheFailure(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	defer lock.Close()
+	cache := &failingCache{KVCache: lock.cache, failing: make(map[string]bool)}
+	lock.cache = cache
+
+	held := func(resource string) bool {
+		values, err := lock.Inspect(ctx, resource)
+		assert.Nil(t, err)
+		return len(values) > 0
+	}
+
+	// a cache failure is not taken as the lock not held
+	_, err = lock.Lock(ctx, "cache-failure-foo", time.Second)
+	assert.Nil(t, err)
+	cache.failing["cache-failure-foo"] = true
+	err = lock.UnLock(ctx, "cache-failure-foo")
+	assert.True(t, errors.Is(err, ErrCacheUnavailable))
+	var cacheErr *CacheError
+	assert.True(t, errors.As(err, &cacheErr))
+	assert.Equal(t, "cache-failure-foo", cacheErr.Resource())
+	assert.True(t, held("cache-failure-foo"))
+	cache.failing["cache-failure-foo"] = false
+	assert.Nil(t, lock.UnLock(ctx, "cache-failure-foo"))
+	assert.False(t, held("cache-failure-foo"))
+	// the lock not held is still not an error
+	assert.Nil(t, lock.UnLock(ctx, "cache-failure-foo"))
+
+	// a handle releases the lock by its value
+	l, err := lock.Acquire(ctx, "cache-failure-foo", time.Second)
+	assert.Nil(t, err)
+	cache.failing["cache-failure-foo"] = true
+	assert.Nil(t, l.Unlock(ctx))
+	assert.False(t, held("cache-failure-foo"))
+	cache.failing["cache-failure-foo"] = false
+	assert.False(t, lock.Owns("cache-failure-foo"))
+
+	// the other locks of ReleaseAll are released despite the cache failure
+	_, err = lock.Lock(ctx, "cache-failure-foo", time.Second)
+	assert.Nil(t, err)
+	_, err = lock.Lock(ctx, "cache-failure-bar", time.Second)
+	assert.Nil(t, err)
+	cache.failing["cache-failure-foo"] = true
+	err = lock.ReleaseAll(ctx, "cache-failure-foo", "cache-failure-bar")
+	assert.True(t, errors.Is(err, ErrCacheUnavailable))
+	assert.True(t, held("cache-failure-foo"))
+	assert.False(t, held("cache-failure-bar"))
+	cache.failing["cache-failure-foo"] = false
+	assert.Nil(t, lock.ReleaseAll(ctx, "cache-failure-foo", "cache-failure-bar"))
+	assert.False(t, held("cache-failure-foo"))
+}
+
+func TestMockReleaseAllInvalidResource(t *testing.T) {
+	ctx := context.Background()
+	var unlocks int32
+	instance := func() *mockCmdable {
+		return &mockCmdable{
+			eval: func(context.Context, string, []string, ...interface{}) (interface{}, error) {
+				atomic.AddInt32(&unlocks, 1)
+				return int64(1), nil
+			},
+		}
+	}
+	errBad := errors.New("bad resource")
+	lock := newMockRedLockWithOptions(t, []redisCmdable{instance(), instance(), instance()}, WithCountingReentrant(),
+		WithResourceValidator(func(resource string) error {
+			if resource == "bad" {
+				return errBad
+			}
+			return nil
+		}))
+
+	_, err := lock.Lock(ctx, "a", time.Second)
+	assert.Nil(t, err)
+	assert.True(t, errors.Is(lock.ReleaseAll(ctx, "a", "bad"), errBad))
+	// the valid resource is untouched
+	assert.True(t, lock.Owns("a"))
+	assert.Equal(t, 1, lock.holdCounts.count("a"))
+	assert.Zero(t, atomic.LoadInt32(&unlocks))
+	assert.Nil(t, lock.UnLock(ctx, "a"))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&unlocks))
+	assert.False(t, lock.Owns("a"))
+}
@@ -0,0 +1,116 @@
+package redlock
+
+import (
+	"context"
+	"time"
+)
+
+// renewalRatio decides the renewal interval of a lock, which is ttl/renewalRatio
+const renewalRatio = 3
+
+// keepAlive extends the lock with its ttl periodically until the lock is
+// lost or ctx is done. onRenew is called with the new validity after each
+// successful renewal. It returns the error that the lock is lost with, or
+// ctx.Err() if ctx is done.
+func (l *Lock) keepAlive(ctx context.Context, onRenew func(validity time.Duration)) error {
+	for {
+		ttl := l.TTL()
+		timer := time.NewTimer(ttl / renewalRatio)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		validity, err := l.ExtendTo(ctx, ttl)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			l.r.emit(EventLost, l.resource, 0, err)
+			return err
+		}
+		if onRenew != nil {
+			onRenew(validity)
+		}
+	}
+}
+
+// LockStream acquires a lock and keeps renewing it in background, the
+// returned channel emits the remaining validity after acquisition and after
+// each successful renewal. The channel is closed when the lock is lost or ctx
+// is canceled, in the latter case the lock is released.
+//
+// The channel has a buffer of one validity, if the receiver falls behind the
+// stale validity is replaced by the latest one, so renewal is never blocked
+// by a slow receiver.
+func (r *RedLock) LockStream(ctx context.Context, resource string, ttl time.Duration) (<-chan time.Duration, error) {
+	return r.LockStreamWithLifetime(ctx, ctx, resource, ttl)
+}
+
+// LockStreamWithLifetime is the same as LockStream, except that ctx only
+// bounds the SETNX round trips of acquisition, while lifetime bounds the
+// renewal of the acquired lock. Canceling ctx after acquisition doesn't affect
+// the lock, the channel is closed and the lock is released once lifetime is
+// canceled. This allows acquiring with a short request-scoped ctx a lock that
+// outlives the request.
+func (r *RedLock) LockStreamWithLifetime(
+	ctx, lifetime context.Context, resource string, ttl time.Duration,
+) (<-chan time.Duration, error) {
+	l, err := r.Acquire(ctx, resource, ttl)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan time.Duration, 1)
+	emit := func(validity time.Duration) {
+		for {
+			select {
+			case ch <- validity:
+				return
+			default:
+			}
+			// drop the stale validity
+			select {
+			case <-ch:
+			default:
+			}
+		}
+	}
+	emit(l.Validity())
+	go func() {
+		defer close(ch)
+		if err := l.keepAlive(lifetime, emit); err == lifetime.Err() {
+			cctx, cancel := context.WithTimeout(context.Background(), r.unlockTimeout)
+			defer cancel()
+			l.Unlock(cctx) // nolint:errcheck
+		}
+	}()
+	return ch, nil
+}
+
+// WithLock acquires the lock, runs fn while holding it and releases the lock
+// afterward, even if fn panics. The lock is renewed in background while fn is
+// running, the context passed to fn is canceled if the lock is lost. It
+// returns the error of acquisition or fn.
+func (r *RedLock) WithLock(ctx context.Context, resource string, ttl time.Duration, fn func(ctx context.Context) error) error {
+	l, err := r.Acquire(ctx, resource, ttl)
+	if err != nil {
+		return err
+	}
+	fnCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		l.keepAlive(fnCtx, nil) // nolint:errcheck
+		cancel()
+	}()
+	// deferred so the lock is released before a panic of fn propagates
+	defer func() {
+		cancel()
+		<-done
+		uctx, ucancel := context.WithTimeout(context.Background(), r.unlockTimeout)
+		defer ucancel()
+		l.Unlock(uctx) // nolint:errcheck
+	}()
+	return fn(fnCtx)
+}
@@ -0,0 +1,191 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+
+	ttl := 150 * time.Millisecond
+	ch, err := lock.LockStream(ctx, "foo", ttl)
+	assert.Nil(t, err)
+	// the lock is renewed several times and never expires
+	for i := 0; i < 5; i++ {
+		validity, ok := <-ch
+		assert.True(t, ok)
+		assert.Greater(t, int64(validity), int64(0))
+		assert.Less(t, int64(validity), int64(ttl))
+	}
+	time.Sleep(ttl)
+	values, err := lock.Inspect(ctx, "foo")
+	assert.Nil(t, err)
+	assert.Len(t, values, len(redisServers))
+
+	// channel is closed and lock is released after cancellation
+	cancel()
+	for range ch {
+	}
+	values, err = lock.Inspect(context.Background(), "foo")
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+}
+
+func TestLockStreamLost(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+
+	ch, err := lock.LockStream(ctx, "foo", 150*time.Millisecond)
+	assert.Nil(t, err)
+	<-ch
+	// the lock is taken away
+	for _, cli := range lock.clients {
+		assert.Nil(t, rawClient(cli).Set(ctx, "foo", "others", time.Second).Err())
+	}
+	defer func() {
+		for _, cli := range lock.clients {
+			rawClient(cli).Del(ctx, "foo")
+		}
+	}()
+	select {
+	case _, ok := <-ch:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("stream is not closed after lock lost")
+	}
+}
+
+func TestMockLockStreamWithLifetime(t *testing.T) {
+	var extends, unlocks int32
+	// the mock fails like redis client does once its ctx is done
+	instance := func() *mockCmdable {
+		return &mockCmdable{
+			setNX: func(ctx context.Context, _ string, _ interface{}, _ time.Duration) (bool, error) {
+				return ctx.Err() == nil, ctx.Err()
+			},
+			eval: func(ctx context.Context, script string, _ []string, _ ...interface{}) (interface{}, error) {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				if strings.Contains(script, "pexpire") {
+					atomic.AddInt32(&extends, 1)
+				} else {
+					atomic.AddInt32(&unlocks, 1)
+				}
+				return int64(1), nil
+			},
+		}
+	}
+	lock := newMockRedLock(t, instance(), instance(), instance())
+	lock.SetRetryCount(1)
+
+	// acquisition is bounded by ctx
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := lock.LockStreamWithLifetime(canceled, context.Background(), "foo", time.Second)
+	assert.Equal(t, context.Canceled, err)
+	atomic.StoreInt32(&unlocks, 0)
+
+	ctx, cancelAcquire := context.WithCancel(context.Background())
+	lifetime, cancelLifetime := context.WithCancel(context.Background())
+	ch, err := lock.LockStreamWithLifetime(ctx, lifetime, "foo", 60*time.Millisecond)
+	assert.Nil(t, err)
+	<-ch
+	// renewal outlives the acquisition ctx
+	cancelAcquire()
+	for i := 0; i < 3; i++ {
+		select {
+		case validity, ok := <-ch:
+			assert.True(t, ok)
+			assert.Greater(t, int64(validity), int64(0))
+		case <-time.After(time.Second):
+			t.Fatal("lock is not renewed after acquisition ctx canceled")
+		}
+	}
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&extends), int32(3*3))
+	assert.Zero(t, atomic.LoadInt32(&unlocks))
+
+	// the lock is released once lifetime is canceled
+	cancelLifetime()
+	for range ch {
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&unlocks))
+}
+
+func TestWithLock(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+
+	ttl := 150 * time.Millisecond
+	errFn := errors.New("fn failed")
+	err = lock.WithLock(ctx, "foo", ttl, func(ctx context.Context) error {
+		// the lock outlives its ttl by renewal
+		time.Sleep(2 * ttl)
+		values, err := lock.Inspect(ctx, "foo")
+		assert.Nil(t, err)
+		assert.Len(t, values, len(redisServers))
+		assert.Nil(t, ctx.Err())
+		return errFn
+	})
+	assert.Equal(t, errFn, err)
+	values, err := lock.Inspect(ctx, "foo")
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+
+	// lock is released on panic
+	assert.Panics(t, func() {
+		lock.WithLock(ctx, "foo", ttl, func(ctx context.Context) error { // nolint:errcheck
+			panic("boom")
+		})
+	})
+	values, err = lock.Inspect(ctx, "foo")
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+
+	// acquisition error is returned without calling fn
+	holder, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	_, err = holder.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	defer holder.UnLock(ctx, "foo") // nolint:errcheck
+	lock.SetRetryCount(1)
+	err = lock.WithLock(ctx, "foo", ttl, func(ctx context.Context) error {
+		t.Fatal("fn is called without holding the lock")
+		return nil
+	})
+	assert.True(t, errors.Is(err, ErrAcquireLock))
+}
+
+func TestWithLockLost(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+
+	err = lock.WithLock(ctx, "foo", 150*time.Millisecond, func(fnCtx context.Context) error {
+		for _, cli := range lock.clients {
+			assert.Nil(t, rawClient(cli).Set(ctx, "foo", "others", time.Second).Err())
+		}
+		select {
+		case <-fnCtx.Done():
+			return fnCtx.Err()
+		case <-time.After(time.Second):
+			t.Fatal("fn context is not canceled after lock lost")
+		}
+		return nil
+	})
+	assert.Equal(t, context.Canceled, err)
+	for _, cli := range lock.clients {
+		rawClient(cli).Del(ctx, "foo")
+	}
+}
@@ -0,0 +1,117 @@
+package redlock
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// RetryStrategy decides whether and when a failed acquisition is retried
+type RetryStrategy interface {
+	// Next is called after the attempt-th failed attempt, attempt starts
+	// from 1, elapsed is the time since the first attempt started. It
+	// returns the delay before the next attempt and whether to retry.
+	Next(attempt int, elapsed time.Duration) (delay time.Duration, retry bool)
+}
+
+// WithRetryStrategy delegates all retry decisions of acquisition to s, the
+// retry count, retry delay and retry backoff settings are ignored then.
+func WithRetryStrategy(s RetryStrategy) LockOption {
+	return func(r *RedLock) {
+		if s == nil {
+			r.setOptErr(fmt.Errorf("invalid nil retry strategy"))
+			return
+		}
+		r.retryStrategy = s
+	}
+}
+
+// FixedRetry makes at most count attempts, waiting delay between them
+func FixedRetry(count int, delay time.Duration) RetryStrategy {
+	return fixedRetry{count: count, delay: delay}
+}
+
+type fixedRetry struct {
+	count int
+	delay time.Duration
+}
+
+func (s fixedRetry) Next(attempt int, elapsed time.Duration) (time.Duration, bool) {
+	return s.delay, attempt < s.count
+}
+
+// ExponentialJitterRetry makes at most count attempts, the n-th retry waits
+// a random delay in [min, min*2^n] capped by max, so retries never spin
+// without sleep and keep backing off until max.
+func ExponentialJitterRetry(count int, min, max time.Duration) RetryStrategy {
+	return exponentialJitterRetry{count: count, min: min, max: max}
+}
+
+type exponentialJitterRetry struct {
+	count    int
+	min, max time.Duration
+}
+
+func (s exponentialJitterRetry) Next(attempt int, elapsed time.Duration) (time.Duration, bool) {
+	if attempt >= s.count {
+		return 0, false
+	}
+	if s.min >= s.max {
+		return s.min, true
+	}
+	ceil := s.max
+	// the shift is bounded to avoid overflow, a ceil beyond max is capped anyway
+	if attempt <= 32 {
+		if grown := s.min << uint(attempt); grown > 0 && grown < ceil {
+			ceil = grown
+		}
+	}
+	return s.min + time.Duration(rand.Int63n(int64(ceil-s.min)+1)), true
+}
+
+// DeadlineRetry retries as s does, but gives up once the next attempt would
+// start later than maxWait since the first attempt, which bounds the total
+// time spent on retrying regardless of the attempt count.
+func DeadlineRetry(maxWait time.Duration, s RetryStrategy) RetryStrategy {
+	return deadlineRetry{maxWait: maxWait, s: s}
+}
+
+type deadlineRetry struct {
+	maxWait time.Duration
+	s       RetryStrategy
+}
+
+func (s deadlineRetry) Next(attempt int, elapsed time.Duration) (time.Duration, bool) {
+	delay, retry := s.s.Next(attempt, elapsed)
+	if !retry || elapsed+delay > s.maxWait {
+		return 0, false
+	}
+	return delay, true
+}
+
+// randomRetry is the default strategy, it makes at most count attempts and
+// waits a random delay up to delay milliseconds between them
+type randomRetry struct {
+	count int
+	delay int
+}
+
+func (s randomRetry) Next(attempt int, elapsed time.Duration) (time.Duration, bool) {
+	return time.Duration(rand.Intn(s.delay)) * time.Millisecond, attempt < s.count
+}
+
+// noRetry makes a single attempt
+var noRetry = FixedRetry(1, 0)
+
+// retry returns the retry strategy of acquisition, which is the one set by
+// WithRetryStrategy, or the default one configured by the retry count, retry
+// delay and retry backoff settings
+func (r *RedLock) retry() RetryStrategy {
+	if r.retryStrategy != nil {
+		return r.retryStrategy
+	}
+	if r.backoffMin > 0 {
+		return ExponentialJitterRetry(r.retryCount, r.backoffMin, r.backoffMax)
+	}
+	return randomRetry{count: r.retryCount, delay: r.retryDelay}
+}
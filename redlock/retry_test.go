@@ -0,0 +1,77 @@
+package redlock
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryStrategies(t *testing.T) {
+	fixed := FixedRetry(3, 10*time.Millisecond)
+	for attempt := 1; attempt < 3; attempt++ {
+		delay, ok := fixed.Next(attempt, 0)
+		assert.True(t, ok)
+		assert.Equal(t, 10*time.Millisecond, delay)
+	}
+	_, ok := fixed.Next(3, 0)
+	assert.False(t, ok)
+
+	jitter := ExponentialJitterRetry(100, time.Millisecond, 8*time.Millisecond)
+	for attempt := 1; attempt < 100; attempt++ {
+		delay, ok := jitter.Next(attempt, 0)
+		assert.True(t, ok)
+		assert.GreaterOrEqual(t, int64(delay), int64(time.Millisecond))
+		assert.LessOrEqual(t, int64(delay), int64(8*time.Millisecond))
+	}
+	_, ok = jitter.Next(100, 0)
+	assert.False(t, ok)
+
+	deadline := DeadlineRetry(time.Second, FixedRetry(100, 300*time.Millisecond))
+	_, ok = deadline.Next(1, 600*time.Millisecond)
+	assert.True(t, ok)
+	_, ok = deadline.Next(2, 800*time.Millisecond)
+	assert.False(t, ok)
+	_, ok = DeadlineRetry(time.Hour, FixedRetry(1, 0)).Next(1, 0)
+	assert.False(t, ok)
+}
+
+func TestRetryStrategyOption(t *testing.T) {
+	_, err := NewRedLock(context.Background(), []string{"tcp://mock0:6379"}, WithRetryStrategy(nil))
+	assert.NotNil(t, err)
+}
+
+type recordRetry struct {
+	calls []int
+	max   int
+}
+
+func (s *recordRetry) Next(attempt int, elapsed time.Duration) (time.Duration, bool) {
+	s.calls = append(s.calls, attempt)
+	return 0, attempt < s.max
+}
+
+func TestMockRetryStrategy(t *testing.T) {
+	ctx := context.Background()
+	var attempts int32
+	held := func(context.Context, string, interface{}, time.Duration) (bool, error) {
+		atomic.AddInt32(&attempts, 1)
+		return false, nil
+	}
+	strategy := &recordRetry{max: 5}
+	lock := newMockRedLockWithOptions(t, []redisCmdable{&mockCmdable{setNX: held}},
+		WithRetryCount(2), WithRetryStrategy(strategy))
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&attempts))
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, strategy.calls)
+
+	// TryLock makes a single attempt whatever the strategy is
+	atomic.StoreInt32(&attempts, 0)
+	ok, err := lock.NewMutex("foo", time.Second).TryLock(ctx)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
@@ -0,0 +1,95 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// scanCount is the COUNT hint of each SCAN iteration
+const scanCount = 100
+
+// ErrNoKeyPrefix means the lock keys have no common prefix to scan, which is
+// set by WithHashTag
+var ErrNoKeyPrefix = errors.New("lock keys have no prefix, set it by WithHashTag")
+
+// globEscaper escapes the special characters of redis glob-style pattern
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// ScanLocks enumerates the locks held in redis under the key prefix set by
+// WithHashTag, including the locks held by other processes. It returns a map
+// from the address of instance to the locked resources and their values. Each
+// instance is iterated by SCAN cursor, so a large keyspace doesn't block
+// redis, the result is not a snapshot, locks acquired or released during the
+// scan may be missed. Resources are reported as stored in redis, hashed if
+// WithKeyHasher is set. ErrNoKeyPrefix is returned if there is no hash tag.
+// The first error of instances is returned along with the locks scanned from
+// the others.
+func (r *RedLock) ScanLocks(ctx context.Context) (map[string]map[string]string, error) {
+	if r.hashTag == "" {
+		return nil, ErrNoKeyPrefix
+	}
+	prefix := "{" + r.hashTag + "}:"
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		locks    = make(map[string]map[string]string, len(r.clients))
+		firstErr error
+	)
+	for _, cli := range r.clients {
+		cli := cli
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			held, err := r.scanInstance(ctx, cli, prefix)
+			mu.Lock()
+			defer mu.Unlock()
+			locks[cli.addr] = held
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("%s: %w", cli.addr, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return locks, firstErr
+}
+
+// scanInstance scans the locks under prefix on a single instance
+func (r *RedLock) scanInstance(ctx context.Context, cli *RedClient, prefix string) (map[string]string, error) {
+	held := make(map[string]string)
+	match := globEscaper.Replace(prefix) + "*"
+	var cursor uint64
+	for {
+		keys, next, err := cli.cli.Scan(ctx, cursor, match, scanCount).Result()
+		if err != nil {
+			return held, err
+		}
+		if r.leaseMetadata {
+			filtered := keys[:0]
+			for _, key := range keys {
+				if !strings.HasSuffix(key, leaseSuffix) {
+					filtered = append(filtered, key)
+				}
+			}
+			keys = filtered
+		}
+		if len(keys) > 0 {
+			vals, err := cli.cli.MGet(ctx, keys...).Result()
+			if err != nil {
+				return held, err
+			}
+			for idx, val := range vals {
+				// the lock expires or is released after scanned
+				if s, ok := val.(string); ok {
+					held[strings.TrimPrefix(keys[idx], prefix)] = s
+				}
+			}
+		}
+		if next == 0 {
+			return held, nil
+		}
+		cursor = next
+	}
+}
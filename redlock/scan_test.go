@@ -0,0 +1,49 @@
+package redlock
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanLocks(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	_, err = lock.ScanLocks(ctx)
+	assert.Equal(t, ErrNoKeyPrefix, err)
+
+	lock, err = NewRedLock(ctx, redisServers, WithHashTag("scan"), WithLeaseMetadata())
+	assert.Nil(t, err)
+	other, err := NewRedLock(ctx, redisServers, WithHashTag("scan"))
+	assert.Nil(t, err)
+	// more locks than a single SCAN iteration
+	expected := make(map[string]string)
+	for i := 0; i < 2*scanCount; i++ {
+		resource := fmt.Sprintf("res%d", i)
+		holder := lock
+		if i%2 == 1 {
+			holder = other
+		}
+		_, err = holder.Lock(ctx, resource, 5*time.Second)
+		assert.Nil(t, err)
+		elem, err := holder.cache.Get(resource)
+		assert.Nil(t, err)
+		expected[resource] = elem.Val
+		defer holder.UnLock(ctx, resource) // nolint:errcheck
+	}
+	// keys out of the prefix are not locks
+	cli := rawClient(lock.clients[0])
+	assert.Nil(t, cli.Set(ctx, "res0", "not a lock", time.Second).Err())
+	defer cli.Del(ctx, "res0")
+
+	locks, err := lock.ScanLocks(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, locks, len(redisServers))
+	for _, server := range redisServers {
+		assert.Equal(t, expected, locks[server])
+	}
+}
@@ -0,0 +1,129 @@
+package redlock
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// luaScript is a lua script run by EVALSHA, which falls back to EVAL if the
+// script is not cached by redis yet
+type luaScript struct {
+	src string
+	sha string
+}
+
+func newLuaScript(src string) *luaScript {
+	h := sha1.Sum([]byte(src))
+	return &luaScript{src: src, sha: hex.EncodeToString(h[:])}
+}
+
+var (
+	unlockScript       = newLuaScript(UnlockScript)
+	prefixUnlockScript = newLuaScript(PrefixUnlockScript)
+	fieldUnlockScript  = newLuaScript(FieldUnlockScript)
+	extendScript       = newLuaScript(ExtendScript)
+	lockIfScript       = newLuaScript(LockIfScript)
+)
+
+func (s *luaScript) run(ctx context.Context, cli redisCmdable, keys []string, args ...interface{}) *redis.Cmd {
+	reply := cli.EvalSha(ctx, s.sha, keys, args...)
+	if err := reply.Err(); err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		return cli.Eval(ctx, s.src, keys, args...)
+	}
+	return reply
+}
+
+// runPipelined runs the script once for each of keys and args in a single
+// pipeline, the runs that miss the script cache are retried by EVAL in a
+// second pipeline. It returns the replies in the same order as keys.
+func (s *luaScript) runPipelined(
+	ctx context.Context, cli redisPipeliner, keys [][]string, args [][]interface{},
+) []*redis.Cmd {
+	cmds := make([]*redis.Cmd, len(keys))
+	pipe := cli.Pipeline()
+	for idx := range keys {
+		cmds[idx] = pipe.EvalSha(ctx, s.sha, keys[idx], args[idx]...)
+	}
+	pipe.Exec(ctx) // nolint:errcheck
+	var missed []int
+	for idx, cmd := range cmds {
+		if err := cmd.Err(); err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+			missed = append(missed, idx)
+		}
+	}
+	if len(missed) == 0 {
+		return cmds
+	}
+	pipe = cli.Pipeline()
+	for _, idx := range missed {
+		cmds[idx] = pipe.Eval(ctx, s.src, keys[idx], args[idx]...)
+	}
+	pipe.Exec(ctx) // nolint:errcheck
+	return cmds
+}
+
+// LoadScriptsError holds the per-instance errors of LoadScripts, in the same
+// order as the redis servers, nil for the instances that loaded all scripts.
+type LoadScriptsError struct {
+	Errs []error
+}
+
+func (e *LoadScriptsError) Error() string {
+	msgs := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		if err != nil {
+			msgs = append(msgs, err.Error())
+		}
+	}
+	return "failed to load scripts: " + strings.Join(msgs, "; ")
+}
+
+// LoadScripts loads the unlock, extend, no-shorten extend, verify, conditional
+// and priority lock scripts, and the lease scripts or the informed lock script
+// if enabled, on every instance with SCRIPT LOAD, so the first Lock, UnLock or
+// Extend doesn't pay the cost of sending the script. The SHA returned by each instance is
+// verified, it returns a LoadScriptsError if any instance fails.
+func (r *RedLock) LoadScripts(ctx context.Context) error {
+	scripts := []*luaScript{
+		r.matcher.script, extendScript, extendNoShortenScript, verifyScript, lockIfScript, priorityLockScript,
+	}
+	if r.leaseMetadata {
+		scripts = append(scripts, leaseLockScript, leaseExtendScript, leaseInspectScript)
+	}
+	if r.informedBackoff {
+		scripts = append(scripts, informedLockScript)
+	}
+	errs := make([]error, len(r.clients))
+	var wg sync.WaitGroup
+	for idx, cli := range r.clients {
+		idx, cli := idx, cli
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, s := range scripts {
+				sha, err := cli.cli.ScriptLoad(ctx, s.src).Result()
+				if err != nil {
+					errs[idx] = fmt.Errorf("%s: %w", cli.addr, err)
+					return
+				}
+				if sha != s.sha {
+					errs[idx] = fmt.Errorf("%s: script sha mismatch, %s != %s", cli.addr, sha, s.sha)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return &LoadScriptsError{Errs: errs}
+		}
+	}
+	return nil
+}
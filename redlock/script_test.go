@@ -0,0 +1,72 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadScripts(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithValueMatcher(PrefixMatch(4)))
+	assert.Nil(t, err)
+	for _, cli := range lock.clients {
+		assert.Nil(t, rawClient(cli).ScriptFlush(ctx).Err())
+	}
+	assert.Nil(t, lock.LoadScripts(ctx))
+	for _, cli := range lock.clients {
+		exists, err := rawClient(cli).ScriptExists(ctx, prefixUnlockScript.sha, extendScript.sha, lockIfScript.sha).Result()
+		assert.Nil(t, err)
+		assert.Equal(t, []bool{true, true, true}, exists)
+	}
+
+	// scripts fall back to EVAL after the script cache is flushed
+	for _, cli := range lock.clients {
+		assert.Nil(t, rawClient(cli).ScriptFlush(ctx).Err())
+	}
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	_, err = lock.Extend(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+	values, err := lock.Inspect(ctx, "foo")
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+
+	// the informed lock script is loaded with WithInformedBackoff
+	informed, err := NewRedLock(ctx, redisServers, WithInformedBackoff())
+	assert.Nil(t, err)
+	defer informed.Close()
+	assert.Nil(t, informed.LoadScripts(ctx))
+	for _, cli := range informed.clients {
+		exists, err := rawClient(cli).ScriptExists(ctx, informedLockScript.sha).Result()
+		assert.Nil(t, err)
+		assert.Equal(t, []bool{true}, exists)
+	}
+}
+
+func TestMockLoadScripts(t *testing.T) {
+	ctx := context.Background()
+	errInjected := errors.New("injected error")
+	rejecting := &mockCmdable{
+		scriptLoad: func(context.Context, string) (string, error) {
+			return "", errInjected
+		},
+	}
+	mismatched := &mockCmdable{
+		scriptLoad: func(context.Context, string) (string, error) {
+			return "0000", nil
+		},
+	}
+	lock := newMockRedLock(t, &mockCmdable{}, rejecting, mismatched)
+	err := lock.LoadScripts(ctx)
+	var le *LoadScriptsError
+	assert.True(t, errors.As(err, &le))
+	assert.Len(t, le.Errs, 3)
+	assert.Nil(t, le.Errs[0])
+	assert.True(t, errors.Is(le.Errs[1], errInjected))
+	assert.NotNil(t, le.Errs[2])
+}
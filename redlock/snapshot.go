@@ -0,0 +1,129 @@
+package redlock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// snapshotVersion is the version of the format written by Export
+const snapshotVersion = 1
+
+// ErrExportUnsupported means the cache of a RedLock can't be exported or
+// imported, such as a custom KVCache not implementing CacheExporter
+var ErrExportUnsupported = errors.New("cache doesn't support export")
+
+// CacheExporter is implemented by the caches whose locks can be saved and
+// restored across restarts of the process, SimpleCache and FreeCache
+// implement it.
+type CacheExporter interface {
+	// Export serializes the elements not expired yet
+	Export() ([]byte, error)
+
+	// Import restores the elements serialized by Export, the ones expired
+	// meanwhile are skipped
+	Import(data []byte) error
+}
+
+var (
+	_ CacheExporter = &SimpleCache{}
+	_ CacheExporter = &FreeCache{}
+)
+
+// cacheSnapshot is the serialized form of the elements of a cache, the
+// expiry of each is a wall clock time, since the monotonic clock of LockElem
+// doesn't survive a restart
+type cacheSnapshot struct {
+	Version int            `json:"version"`
+	Elems   []snapshotElem `json:"elems"`
+}
+
+type snapshotElem struct {
+	Key       string    `json:"key"`
+	Val       string    `json:"val"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Export implements CacheExporter.Export
+func (sc *SimpleCache) Export() ([]byte, error) {
+	return exportElems(sc)
+}
+
+// Import implements CacheExporter.Import
+func (sc *SimpleCache) Import(data []byte) error {
+	return importElems(sc, data)
+}
+
+// Export implements CacheExporter.Export
+func (fc *FreeCache) Export() ([]byte, error) {
+	return exportElems(fc)
+}
+
+// Import implements CacheExporter.Import
+func (fc *FreeCache) Import(data []byte) error {
+	return importElems(fc, data)
+}
+
+// exportElems serializes the elements of ranger not expired yet
+func exportElems(ranger cacheRanger) ([]byte, error) {
+	snapshot := cacheSnapshot{Version: snapshotVersion, Elems: []snapshotElem{}}
+	now := time.Now()
+	ranger.rangeElems(func(key string, elem *LockElem) {
+		snapshot.Elems = append(snapshot.Elems, snapshotElem{
+			Key:       key,
+			Val:       elem.Val,
+			ExpiresAt: now.Add(time.Duration(elem.remaining())).Round(0),
+		})
+	})
+	return json.Marshal(snapshot)
+}
+
+// importElems sets the elements serialized by exportElems to cache with the
+// validity remaining by wall clock
+func importElems(cache KVCache, data []byte) error {
+	var snapshot cacheSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return err
+	}
+	if snapshot.Version != snapshotVersion {
+		return fmt.Errorf("unsupported cache snapshot version %d", snapshot.Version)
+	}
+	for _, elem := range snapshot.Elems {
+		remaining := time.Until(elem.ExpiresAt)
+		if remaining <= 0 {
+			continue
+		}
+		if _, err := cache.Set(elem.Key, elem.Val, int64(remaining)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportCache serializes the locks held by r according to its local cache,
+// so a process restarting, such as during a deploy, can resume managing
+// them by ImportCache. It returns ErrExportUnsupported if the cache doesn't
+// implement CacheExporter.
+func (r *RedLock) ExportCache() ([]byte, error) {
+	exporter, ok := r.cache.(CacheExporter)
+	if !ok {
+		return nil, ErrExportUnsupported
+	}
+	return exporter.Export()
+}
+
+// ImportCache restores the locks serialized by ExportCache into the local
+// cache of r, the locks expired meanwhile are skipped. The validity of each
+// lock is restored by wall clock, so a clock step during the restart is not
+// accounted for, and the lock may have been lost on redis, such as by a
+// failover. An imported lock is only a claim of the previous process, verify
+// it by Verify before relying on it, then extend or release it. The ttl of
+// the locks is not restored, extend them with an explicit ttl.
+func (r *RedLock) ImportCache(data []byte) error {
+	exporter, ok := r.cache.(CacheExporter)
+	if !ok {
+		return ErrExportUnsupported
+	}
+	return exporter.Import(data)
+}
@@ -0,0 +1,74 @@
+package redlock
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportImportCache(t *testing.T) {
+	ctx := context.Background()
+	for _, tp := range []string{CacheTypeSimple, CacheTypeFreeCache} {
+		lock, err := NewRedLock(ctx, redisServers, WithCacheType(tp))
+		assert.Nil(t, err)
+		_, err = lock.Lock(ctx, "snapshot-foo", 5*time.Second)
+		assert.Nil(t, err)
+		lock.cache.Set("snapshot-expired", "val", int64(time.Millisecond))
+		time.Sleep(2 * time.Millisecond)
+		data, err := lock.ExportCache()
+		assert.Nil(t, err)
+		held, err := lock.cache.Get("snapshot-foo")
+		assert.Nil(t, err)
+		assert.Nil(t, lock.Close())
+
+		// the restarted process resumes the lock
+		restarted, err := NewRedLock(ctx, redisServers, WithCacheType(tp))
+		assert.Nil(t, err)
+		assert.Nil(t, restarted.ImportCache(data))
+		elem, err := restarted.cache.Get("snapshot-foo")
+		assert.Nil(t, err)
+		assert.NotNil(t, elem)
+		assert.Equal(t, held.Val, elem.Val)
+		assert.InDelta(t, float64(held.remaining()), float64(elem.remaining()), float64(time.Second))
+		elem, err = restarted.cache.Get("snapshot-expired")
+		assert.Nil(t, err)
+		assert.Nil(t, elem)
+		ok, _, err := restarted.Verify(ctx, "snapshot-foo")
+		assert.Nil(t, err)
+		assert.True(t, ok)
+		assert.Nil(t, restarted.UnLock(ctx, "snapshot-foo"))
+		values, err := restarted.Inspect(ctx, "snapshot-foo")
+		assert.Nil(t, err)
+		assert.Empty(t, values)
+		assert.Nil(t, restarted.Close())
+	}
+}
+
+func TestImportCacheInvalid(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	assert.NotNil(t, lock.ImportCache([]byte("not json")))
+	data, err := json.Marshal(cacheSnapshot{Version: snapshotVersion + 1})
+	assert.Nil(t, err)
+	assert.NotNil(t, lock.ImportCache(data))
+	// the lock expired since export is skipped
+	data, err = json.Marshal(cacheSnapshot{Version: snapshotVersion, Elems: []snapshotElem{
+		{Key: "snapshot-foo", Val: "val", ExpiresAt: time.Now().Add(-time.Second)},
+	}})
+	assert.Nil(t, err)
+	assert.Nil(t, lock.ImportCache(data))
+	assert.False(t, lock.Owns("snapshot-foo"))
+
+	nocache, err := NewRedLock(ctx, redisServers, WithNoCache())
+	assert.Nil(t, err)
+	defer nocache.Close()
+	_, err = nocache.ExportCache()
+	assert.Equal(t, ErrExportUnsupported, err)
+	assert.Equal(t, ErrExportUnsupported, nocache.ImportCache(data))
+}
@@ -0,0 +1,183 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrStoreUnsupported means the operation needs redis and is not supported by
+// a LockStore
+var ErrStoreUnsupported = errors.New("operation is not supported by lock store")
+
+// LockStore is a store that locks can be set on, such as etcd, Consul or an
+// in-memory store, RedLock acquires a lock on a quorum of them with the same
+// retry and validity logic as on redis instances.
+type LockStore interface {
+	// SetNX sets key to val with ttl if key doesn't exist, returns whether
+	// key is set
+	SetNX(ctx context.Context, key, val string, ttl time.Duration) (bool, error)
+
+	// Unlock deletes key if its value is val, a key that doesn't exist or
+	// holds another value is not an error
+	Unlock(ctx context.Context, key, val string) error
+}
+
+// StoreExtender is implemented by a LockStore that supports extending locks,
+// which is required by Extend and the renewal of locks
+type StoreExtender interface {
+	// Extend resets the ttl of key if its value is val, returns whether the
+	// ttl is reset
+	Extend(ctx context.Context, key, val string, ttl time.Duration) (bool, error)
+}
+
+// NewRedisStore returns the LockStore of a redis client, which is the store
+// NewRedLockFromClients uses. All redis features are available on the store
+// when it is passed to NewRedLockFromStores.
+func NewRedisStore(cli *redis.Client) LockStore {
+	return &RedClient{addr: cli.Options().Addr, cli: cli}
+}
+
+// NewRedLockFromStores creates a RedLock on the given lock stores, which may
+// be redis stores created by NewRedisStore or stores of other kinds. Stores
+// of other kinds support acquiring and releasing locks, and extending them if
+// they implement StoreExtender, while the features built on redis commands or
+// scripts, such as conditional locks, lease metadata, SET NX GET or WAIT,
+// fail with ErrStoreUnsupported on them. Unlocking a store always matches the
+// whole value, regardless of the unlock value matcher. A store is named by
+// its String method if it is a fmt.Stringer, or by its index otherwise, the
+// stores are owned by caller and not closed by Close.
+func NewRedLockFromStores(ctx context.Context, stores []LockStore, opts ...Option) (*RedLock, error) {
+	if len(stores)%2 == 0 {
+		return nil, fmt.Errorf("error redis server list: %d", len(stores))
+	}
+	clients := make([]*RedClient, 0, len(stores))
+	for idx, store := range stores {
+		if cli, ok := store.(*RedClient); ok {
+			clients = append(clients, cli)
+			continue
+		}
+		addr := fmt.Sprintf("store%d", idx)
+		if s, ok := store.(fmt.Stringer); ok {
+			addr = s.String()
+		}
+		clients = append(clients, &RedClient{addr: addr, cli: storeCmdable{store}, store: store})
+	}
+	return newRedLock(ctx, clients, opts...)
+}
+
+// SetNX implements LockStore.SetNX
+func (c *RedClient) SetNX(ctx context.Context, key, val string, ttl time.Duration) (bool, error) {
+	return c.cli.SetNX(ctx, key, val, ttl).Result()
+}
+
+// Unlock implements LockStore.Unlock
+func (c *RedClient) Unlock(ctx context.Context, key, val string) error {
+	return unlockScript.run(ctx, c.cli, []string{key}, val).Err()
+}
+
+// Extend implements StoreExtender.Extend
+func (c *RedClient) Extend(ctx context.Context, key, val string, ttl time.Duration) (bool, error) {
+	reply := extendScript.run(ctx, c.cli, []string{key}, val, formatMs(ttl))
+	if reply.Err() != nil {
+		return false, reply.Err()
+	}
+	return reply.Val() == int64(1), nil
+}
+
+// redisStore is the LockStore of a redis instance with the settings of a
+// RedLock, the unlock matches the value by its value matcher and the lease
+// metadata is extended and released along with the lock
+type redisStore struct {
+	r      *RedLock
+	client *RedClient
+}
+
+// store returns the LockStore that RedLock sets, extends and releases locks
+// on for client
+func (r *RedLock) store(client *RedClient) LockStore {
+	if client.store != nil {
+		return client.store
+	}
+	return redisStore{r: r, client: client}
+}
+
+func (s redisStore) SetNX(ctx context.Context, key, val string, ttl time.Duration) (bool, error) {
+	return s.client.cli.SetNX(ctx, key, val, ttl).Result()
+}
+
+func (s redisStore) Unlock(ctx context.Context, key, val string) error {
+	keys, args := s.r.unlockArgs(key, val)
+	return s.r.matcher.script.run(ctx, s.client.cli, keys, args...).Err()
+}
+
+func (s redisStore) Extend(ctx context.Context, key, val string, ttl time.Duration) (bool, error) {
+	var reply *redis.Cmd
+	if s.r.leaseMetadata {
+		reply = leaseExtendScript.run(ctx, s.client.cli, []string{key, key + leaseSuffix}, val, formatMs(ttl))
+	} else {
+		reply = extendScript.run(ctx, s.client.cli, []string{key}, val, formatMs(ttl))
+	}
+	if reply.Err() != nil {
+		return false, reply.Err()
+	}
+	return reply.Val() == int64(1), nil
+}
+
+// storeCmdable adapts a LockStore to redisCmdable, the commands other than
+// SETNX fail with ErrStoreUnsupported. It doesn't implement redisPipeliner,
+// so ReleaseAll unlocks a store key by key.
+type storeCmdable struct {
+	store LockStore
+}
+
+func (s storeCmdable) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) *redis.BoolCmd {
+	return redis.NewBoolResult(s.store.SetNX(ctx, key, fmt.Sprint(value), ttl))
+}
+
+func (s storeCmdable) Get(ctx context.Context, key string) *redis.StringCmd {
+	return redis.NewStringResult("", ErrStoreUnsupported)
+}
+
+func (s storeCmdable) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
+	return redis.NewSliceResult(nil, ErrStoreUnsupported)
+}
+
+func (s storeCmdable) Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd {
+	return redis.NewScanCmdResult(nil, 0, ErrStoreUnsupported)
+}
+
+func (s storeCmdable) Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	return redis.NewCmdResult(nil, ErrStoreUnsupported)
+}
+
+func (s storeCmdable) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	return redis.NewCmdResult(nil, ErrStoreUnsupported)
+}
+
+func (s storeCmdable) ScriptLoad(ctx context.Context, script string) *redis.StringCmd {
+	return redis.NewStringResult("", ErrStoreUnsupported)
+}
+
+func (s storeCmdable) Wait(ctx context.Context, numSlaves int, timeout time.Duration) *redis.IntCmd {
+	return redis.NewIntResult(0, ErrStoreUnsupported)
+}
+
+func (s storeCmdable) Do(ctx context.Context, args ...interface{}) *redis.Cmd {
+	return redis.NewCmdResult(nil, ErrStoreUnsupported)
+}
+
+func (s storeCmdable) Watch(ctx context.Context, fn func(*redis.Tx) error, keys ...string) error {
+	return ErrStoreUnsupported
+}
+
+func (s storeCmdable) Ping(ctx context.Context) *redis.StatusCmd {
+	return redis.NewStatusResult("PONG", nil)
+}
+
+func (s storeCmdable) Close() error {
+	return nil
+}
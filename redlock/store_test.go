@@ -0,0 +1,168 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// memStore is an in-memory LockStore
+type memStore struct {
+	mu   sync.Mutex
+	name string
+	keys map[string]memEntry
+	err  error
+}
+
+type memEntry struct {
+	val       string
+	expiresAt time.Time
+}
+
+func newMemStore(name string) *memStore {
+	return &memStore{name: name, keys: make(map[string]memEntry)}
+}
+
+func (s *memStore) String() string {
+	return s.name
+}
+
+func (s *memStore) get(key string) (memEntry, bool) {
+	e, ok := s.keys[key]
+	if ok && time.Now().After(e.expiresAt) {
+		delete(s.keys, key)
+		return memEntry{}, false
+	}
+	return e, ok
+}
+
+func (s *memStore) SetNX(ctx context.Context, key, val string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return false, s.err
+	}
+	if _, ok := s.get(key); ok {
+		return false, nil
+	}
+	s.keys[key] = memEntry{val: val, expiresAt: time.Now().Add(ttl)}
+	return true, nil
+}
+
+func (s *memStore) Unlock(ctx context.Context, key, val string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if e, ok := s.get(key); ok && e.val == val {
+		delete(s.keys, key)
+	}
+	return nil
+}
+
+// extendableMemStore is a memStore supporting StoreExtender
+type extendableMemStore struct {
+	*memStore
+}
+
+func (s extendableMemStore) Extend(ctx context.Context, key, val string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.get(key); ok && e.val == val {
+		s.keys[key] = memEntry{val: val, expiresAt: time.Now().Add(ttl)}
+		return true, nil
+	}
+	return false, nil
+}
+
+func TestMockLockStores(t *testing.T) {
+	ctx := context.Background()
+	stores := []*memStore{newMemStore("a"), newMemStore("b"), newMemStore("c")}
+	_, err := NewRedLockFromStores(ctx, []LockStore{stores[0], stores[1]})
+	assert.NotNil(t, err)
+
+	lock, err := NewRedLockFromStores(ctx, []LockStore{stores[0], stores[1], stores[2]}, WithRetryCount(1))
+	assert.Nil(t, err)
+	defer lock.Close()
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	for _, s := range stores {
+		assert.Len(t, s.keys, 1)
+	}
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.NotNil(t, err)
+
+	// extending needs StoreExtender
+	_, err = lock.Extend(ctx, "foo", time.Second)
+	assert.Equal(t, ErrExtendLock, err)
+	_, err = lock.Inspect(ctx, "foo")
+	assert.True(t, errors.Is(err, ErrStoreUnsupported))
+
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+	for _, s := range stores {
+		assert.Empty(t, s.keys)
+	}
+
+	// a minority of failed stores doesn't break quorum
+	stores[2].err = errors.New("injected error")
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	_, err = lock.Lock(ctx, "bar", time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.ReleaseAll(ctx, "foo", "bar"))
+	for _, s := range stores {
+		assert.Empty(t, s.keys)
+	}
+	stores[2].err = nil
+	// stores are unlocked key by key rather than pipelined
+	_, ok := interface{}(storeCmdable{}).(redisPipeliner)
+	assert.False(t, ok)
+
+	extendable := []LockStore{
+		extendableMemStore{newMemStore("a")}, extendableMemStore{newMemStore("b")}, extendableMemStore{newMemStore("c")},
+	}
+	lock, err = NewRedLockFromStores(ctx, extendable)
+	assert.Nil(t, err)
+	defer lock.Close()
+	_, err = lock.Lock(ctx, "foo", 100*time.Millisecond)
+	assert.Nil(t, err)
+	validity, err := lock.Extend(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(100*time.Millisecond))
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+}
+
+func TestLockStoresWithRedis(t *testing.T) {
+	ctx := context.Background()
+	opts, err := parseConnString(redisServers[0])
+	assert.Nil(t, err)
+	cli := redis.NewClient(opts)
+	defer cli.Close()
+	a, b := newMemStore("a"), newMemStore("b")
+
+	lock, err := NewRedLockFromStores(ctx, []LockStore{NewRedisStore(cli), a, b})
+	assert.Nil(t, err)
+	defer lock.Close()
+	_, err = lock.Lock(ctx, "store-foo", time.Second)
+	assert.Nil(t, err)
+	val, err := cli.Get(ctx, "store-foo").Result()
+	assert.Nil(t, err)
+	assert.Equal(t, a.keys["store-foo"].val, val)
+	assert.Equal(t, b.keys["store-foo"].val, val)
+
+	// redis features are available on the redis store
+	values, err := lock.Inspect(ctx, "store-foo")
+	assert.True(t, errors.Is(err, ErrStoreUnsupported))
+	assert.Equal(t, map[string]string{strings.TrimPrefix(redisServers[0], "tcp://"): val}, values)
+
+	assert.Nil(t, lock.UnLock(ctx, "store-foo"))
+	assert.Equal(t, redis.Nil, cli.Get(ctx, "store-foo").Err())
+	assert.Empty(t, a.keys)
+}
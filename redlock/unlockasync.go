@@ -0,0 +1,35 @@
+package redlock
+
+import (
+	"context"
+	"time"
+)
+
+// UnLockAsync releases an acquired lock like UnLock without waiting for the
+// instances. The lock is removed from the local cache before it returns, so
+// r no longer owns it, while the release on redis runs in background with
+// ctx and its result is sent on the returned channel, which is then closed.
+// The result is nil if the lock is not held, or the error UnLock would
+// return. Until the release completes the lock key persists on redis, and
+// acquiring the lock again may fail on it, Close doesn't wait for the release.
+func (r *RedLock) UnLockAsync(ctx context.Context, resource string) <-chan error {
+	done := make(chan error, 1)
+	if err := r.checkResource(resource); err != nil {
+		done <- err
+		close(done)
+		return done
+	}
+	elem, err := r.releasing(resource)
+	if err != nil || elem == nil {
+		done <- err
+		close(done)
+		return done
+	}
+	expiresAt := time.Now().Add(time.Duration(elem.remaining()))
+	r.releaseDone(resource, true)
+	go func() {
+		done <- r.unlockValue(ctx, resource, elem.Val, expiresAt)
+		close(done)
+	}()
+	return done
+}
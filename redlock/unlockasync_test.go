@@ -0,0 +1,62 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnLockAsync(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	_, err = lock.Lock(ctx, "unlock-async-foo", time.Second)
+	assert.Nil(t, err)
+	done := lock.UnLockAsync(ctx, "unlock-async-foo")
+	assert.False(t, lock.Owns("unlock-async-foo"))
+	assert.Nil(t, <-done)
+	_, ok := <-done
+	assert.False(t, ok)
+	values, err := lock.Inspect(ctx, "unlock-async-foo")
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+
+	// the lock not held
+	assert.Nil(t, <-lock.UnLockAsync(ctx, "unlock-async-foo"))
+}
+
+func TestMockUnLockAsync(t *testing.T) {
+	ctx := context.Background()
+	errInjected := errors.New("injected error")
+	release := make(chan struct{})
+	instance := func(err error) *mockCmdable {
+		return &mockCmdable{
+			eval: func(context.Context, string, []string, ...interface{}) (interface{}, error) {
+				<-release
+				return int64(1), err
+			},
+		}
+	}
+	lock := newMockRedLock(t, instance(nil), instance(errInjected), instance(errInjected))
+
+	_, err := lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	done := lock.UnLockAsync(ctx, "foo")
+	// the lock is released locally before the instances reply
+	assert.False(t, lock.Owns("foo"))
+	select {
+	case <-done:
+		t.Fatal("unlock completed before the instances replied")
+	default:
+	}
+	close(release)
+	err = <-done
+	var unlockErr *UnlockError
+	assert.True(t, errors.As(err, &unlockErr))
+	assert.Equal(t, "foo", unlockErr.Resource())
+}
@@ -0,0 +1,63 @@
+package redlock
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// unlockRetryAttempts is the max background retries of releasing a lock
+	// on an instance
+	unlockRetryAttempts = 5
+
+	// unlockRetryDelay is the wait before the first background retry, it is
+	// doubled after each retry
+	unlockRetryDelay = 100 * time.Millisecond
+)
+
+// WithUnlockRetry makes UnLock retry releasing the lock in background on the
+// instances that failed to release it, so a transiently unreachable instance
+// is cleaned up once it recovers rather than holding the lock until its ttl
+// expires. Each instance is retried with exponential backoff until it
+// succeeds, the lock expires or the retries run out. UnLock still returns
+// without waiting for the retries, PendingUnlockRetries reports how many are
+// pending. Retrying is safe since the lock is released only if its value
+// still matches.
+func WithUnlockRetry() LockOption {
+	return func(r *RedLock) {
+		r.unlockRetry = true
+	}
+}
+
+// PendingUnlockRetries returns the count of instances that the release of a
+// lock is being retried on in background
+func (r *RedLock) PendingUnlockRetries() int {
+	return int(atomic.LoadInt64(&r.pendingUnlocks))
+}
+
+// retryUnlock retries releasing the lock on cli in background, until it
+// succeeds, the lock expires at expiresAt, the retries run out or r is closed
+func (r *RedLock) retryUnlock(cli *RedClient, resource, val string, expiresAt time.Time) {
+	atomic.AddInt64(&r.pendingUnlocks, 1)
+	go func() {
+		defer atomic.AddInt64(&r.pendingUnlocks, -1)
+		delay := unlockRetryDelay
+		for i := 0; i < unlockRetryAttempts; i++ {
+			if wait := time.Until(expiresAt); wait < delay {
+				delay = wait
+			}
+			time.Sleep(delay)
+			if atomic.LoadInt32(&r.closed) == 1 || !time.Now().Before(expiresAt) {
+				return
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), r.unlockTimeout)
+			_, err := r.unlockInstance(ctx, cli, resource, val)
+			cancel()
+			if err == nil {
+				return
+			}
+			delay *= 2
+		}
+	}()
+}
@@ -0,0 +1,69 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyUnlock returns a mock instance that fails to release locks while down
+// is set, released counts the successful releases
+func flakyUnlock(down *int32, released *int32) *mockCmdable {
+	return &mockCmdable{
+		eval: func(context.Context, string, []string, ...interface{}) (interface{}, error) {
+			if atomic.LoadInt32(down) == 1 {
+				return nil, errors.New("connection refused")
+			}
+			atomic.AddInt32(released, 1)
+			return int64(1), nil
+		},
+	}
+}
+
+func waitPendingUnlocks(lock *RedLock, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if lock.PendingUnlockRetries() == 0 {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestMockUnlockRetry(t *testing.T) {
+	ctx := context.Background()
+	var down, released int32 = 1, 0
+	lock := newMockRedLockWithOptions(t,
+		[]redisCmdable{&mockCmdable{}, &mockCmdable{}, flakyUnlock(&down, &released)}, WithUnlockRetry())
+
+	_, err := lock.Lock(ctx, "foo", 5*time.Second)
+	assert.Nil(t, err)
+	// released on quorum, the failed instance is retried in background
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+	assert.Equal(t, 1, lock.PendingUnlockRetries())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&released))
+
+	atomic.StoreInt32(&down, 0)
+	assert.True(t, waitPendingUnlocks(lock, 2*time.Second))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&released))
+
+	// retries stop once the lock expires
+	atomic.StoreInt32(&down, 1)
+	_, err = lock.Lock(ctx, "foo", 150*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+	assert.Equal(t, 1, lock.PendingUnlockRetries())
+	assert.True(t, waitPendingUnlocks(lock, time.Second))
+
+	// no retry by default
+	lock = newMockRedLock(t, &mockCmdable{}, &mockCmdable{}, flakyUnlock(&down, &released))
+	_, err = lock.Lock(ctx, "foo", 5*time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+	assert.Equal(t, 0, lock.PendingUnlockRetries())
+}
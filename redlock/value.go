@@ -0,0 +1,229 @@
+package redlock
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ownerSep separates owner and random token in lock value
+const ownerSep = ":"
+
+// expirySep separates random token and expiry in lock value
+const expirySep = "."
+
+// DefaultMaxValueBytes is the default max length of lock value
+const DefaultMaxValueBytes = 4096
+
+// WithMaxValueBytes sets the max length in bytes of lock value, a lock whose
+// value is longer is rejected with ErrValueTooLarge before being sent to
+// redis. It guards against stuffing a large payload into lock values, which
+// bloats the memory of redis per lock.
+func WithMaxValueBytes(n int) LockOption {
+	return func(r *RedLock) {
+		if n <= 0 {
+			r.setOptErr(fmt.Errorf("invalid max value bytes %d, must be positive", n))
+			return
+		}
+		r.maxValueBytes = n
+	}
+}
+
+func (r *RedLock) checkValue(val string) error {
+	if len(val) > r.maxValueBytes {
+		return fmt.Errorf("%w: %d > %d bytes", ErrValueTooLarge, len(val), r.maxValueBytes)
+	}
+	return nil
+}
+
+// LockWithValue acquires a distribute lock the same as Lock, with val given
+// by caller as the lock value instead of a generated one. val must be unique
+// across clients and acquisitions, since the lock is released only if the
+// value matches, and its length must not exceed the max value bytes.
+func (r *RedLock) LockWithValue(ctx context.Context, resource string, ttl time.Duration, val string) (time.Duration, error) {
+	l, err := r.acquire(ctx, resource, ttl, val, r.retry(), func(ctx context.Context, cli *RedClient) lockResult {
+		return r.lockInstance(ctx, cli, resource, val, ttl)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return l.validity, nil
+}
+
+// ErrInvalidOwner means the owner returned by the OwnerProvider contains a
+// separator of the lock value, which would corrupt parsing the value
+var ErrInvalidOwner = errors.New("invalid lock owner")
+
+// OwnerProvider returns the owner of a lock acquired with ctx
+type OwnerProvider func(ctx context.Context) string
+
+// WithOwnerProvider embeds an owner into every lock value, the value becomes
+// `<owner>:<random token>`. The unlock script still matches the full value.
+// An owner containing `@` or `|`, which separate the identity and priority,
+// fails the acquisition with an error wrapping ErrInvalidOwner.
+func WithOwnerProvider(provider OwnerProvider) LockOption {
+	return func(r *RedLock) {
+		r.ownerProvider = provider
+	}
+}
+
+// WithULIDValue generates the random token of lock value as a ULID, which is
+// a 48-bit millisecond timestamp followed by 80-bit entropy encoded in 26
+// characters, so the lexicographic order of values reflects acquisition
+// time. Note the order across hosts is affected by their clock differences,
+// and an owner embedded by WithOwnerProvider takes precedence in ordering.
+func WithULIDValue() LockOption {
+	return func(r *RedLock) {
+		r.ulidValue = true
+	}
+}
+
+// crockford is the Crockford's base32 alphabet used by ULID
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID generates a ULID with timestamp of t and 10 bytes of entropy
+func newULID(t time.Time, entropy []byte) string {
+	var b [16]byte
+	ms := uint64(t.UnixNano() / int64(time.Millisecond))
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+	copy(b[6:], entropy)
+	// encode 128 bits in 26 characters of 5 bits, the first character
+	// carries the 3 leading bits
+	out := make([]byte, 26)
+	var acc uint32
+	bits := 2
+	idx := 0
+	for _, c := range b {
+		acc = acc<<8 | uint32(c)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[idx] = crockford[(acc>>uint(bits))&0x1f]
+			idx++
+		}
+	}
+	return string(out)
+}
+
+// WithExpiryInValue embeds the expiry of lock into its value, as unix
+// milliseconds after the random token, the value becomes
+// `<random token>.<expiry>`. A process recovering from a crash can read the
+// value of a lock it held by ParseLockValue, and skip releasing it if it is
+// already expired. The expiry is counted from the start of acquisition with
+// the ttl, a lock acquired after retries or extended lasts longer, so a lock
+// past the embedded expiry may still be held for a while, which costs only
+// the wait of others. The unlock script still matches the full value.
+func WithExpiryInValue() LockOption {
+	return func(r *RedLock) {
+		r.expiryInValue = true
+	}
+}
+
+// WithValuePrefix prepends a static prefix to every generated lock value,
+// such as the version of service `v2:`, so the locks held by each version
+// are told apart in the values returned by Inspect and ScanLocks during a
+// rollout. The prefix comes before the owner, the value becomes
+// `<prefix><owner>:<random token>`, and since `:` separates the owner, a
+// prefix containing it is read as part of the owner by OwnerOf, such as
+// `v2:tenant`, or `v2` for a value without owner. RedLock skips its own
+// prefix when it reads the owner of a value, such as for the deadlock
+// detection, so a holder is told by its owner rather than its version. The
+// prefix must not contain `@` or `|`, which separate the identity and
+// priority. The unlock script still matches the full value.
+func WithValuePrefix(prefix string) LockOption {
+	return func(r *RedLock) {
+		if strings.ContainsAny(prefix, identitySep+prioritySep) {
+			r.setOptErr(fmt.Errorf("invalid value prefix %q, must not contain any of %q", prefix, identitySep+prioritySep))
+			return
+		}
+		r.valuePrefix = prefix
+	}
+}
+
+// newValue generates the value of a new lock with ttl, it fails only if
+// crypto/rand fails under EntropyFail policy
+func (r *RedLock) newValue(ctx context.Context, ttl time.Duration) (string, error) {
+	var val string
+	if r.ulidValue {
+		entropy := make([]byte, 10)
+		if err := r.readEntropy(entropy); err != nil {
+			return "", err
+		}
+		val = newULID(time.Now(), entropy)
+	} else {
+		b := make([]byte, 16)
+		if err := r.readEntropy(b); err != nil {
+			return "", err
+		}
+		val = base64.StdEncoding.EncodeToString(b)
+	}
+	if r.expiryInValue {
+		expiry := time.Now().Add(ttl).UnixNano() / int64(time.Millisecond)
+		val += expirySep + strconv.FormatInt(expiry, 10)
+	}
+	if r.ownerProvider != nil {
+		owner := r.ownerProvider(ctx)
+		if strings.ContainsAny(owner, identitySep+prioritySep) {
+			return "", fmt.Errorf("%w %q, must not contain any of %q", ErrInvalidOwner, owner, identitySep+prioritySep)
+		}
+		val = owner + ownerSep + val
+	}
+	val = r.valuePrefix + val
+	if r.identity != "" {
+		val += identitySep + r.identity
+	}
+	return val, nil
+}
+
+// ownerOf returns the owner embedded in a lock value generated by r, the
+// value prefix of r is skipped, so a prefix containing `:` isn't the owner
+func (r *RedLock) ownerOf(val string) string {
+	return OwnerOf(strings.TrimPrefix(val, r.valuePrefix))
+}
+
+// OwnerOf returns the owner embedded in a lock value, or empty string if
+// the value has no owner.
+func OwnerOf(val string) string {
+	// the random token is base64 or base32 encoded and never contains the
+	// separator
+	val, _ = splitIdentity(val)
+	idx := strings.LastIndex(val, ownerSep)
+	if idx < 0 {
+		return ""
+	}
+	return val[:idx]
+}
+
+// ParseLockValue returns the random token and the expiry embedded in a lock
+// value by WithExpiryInValue, the owner, identity and priority of the value
+// are skipped. ok is false if the value has no expiry.
+func ParseLockValue(s string) (token string, expiry time.Time, ok bool) {
+	if _, ok := PriorityOf(s); ok {
+		s = s[:strings.LastIndex(s, prioritySep)]
+	}
+	s, _ = splitIdentity(s)
+	if idx := strings.LastIndex(s, ownerSep); idx >= 0 {
+		s = s[idx+1:]
+	}
+	idx := strings.LastIndex(s, expirySep)
+	if idx < 0 {
+		return s, time.Time{}, false
+	}
+	ms, err := strconv.ParseInt(s[idx+1:], 10, 64)
+	if err != nil {
+		return s, time.Time{}, false
+	}
+	return s[:idx], time.Unix(0, ms*int64(time.Millisecond)), true
+}
+
+// Owner returns the owner embedded in the lock value
+func (e *LockElem) Owner() string {
+	return OwnerOf(e.Val)
+}
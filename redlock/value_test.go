@@ -0,0 +1,221 @@
+package redlock
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tenantKey struct{}
+
+func TestOwnerProvider(t *testing.T) {
+	ctx := context.WithValue(context.Background(), tenantKey{}, "tenant-a")
+	lock, err := NewRedLock(ctx, redisServers, WithOwnerProvider(func(ctx context.Context) string {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return tenant
+	}))
+	assert.Nil(t, err)
+
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.Nil(t, err)
+	elem, err := lock.cache.Get("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, "tenant-a", elem.Owner())
+
+	values, err := lock.Inspect(ctx, "foo")
+	assert.Nil(t, err)
+	assert.Len(t, values, len(redisServers))
+	for _, val := range values {
+		assert.Equal(t, elem.Val, val)
+		assert.Equal(t, "tenant-a", OwnerOf(val))
+	}
+
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+	values, err = lock.Inspect(ctx, "foo")
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+
+	// an owner containing the separators of identity or priority is rejected
+	for _, owner := range []string{"tenant@a", "tenant|1"} {
+		_, err = lock.Lock(context.WithValue(ctx, tenantKey{}, owner), "foo", time.Second)
+		assert.True(t, errors.Is(err, ErrInvalidOwner), owner)
+	}
+	values, err = lock.Inspect(ctx, "foo")
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+}
+
+// getRandStr returns a random lock value
+func getRandStr() string {
+	val, _ := (&RedLock{}).newValue(context.Background(), 0)
+	return val
+}
+
+func TestOwnerOf(t *testing.T) {
+	assert.Equal(t, "", OwnerOf(getRandStr()))
+	assert.Equal(t, "a", OwnerOf("a:"+getRandStr()))
+	assert.Equal(t, "a:b", OwnerOf("a:b:"+getRandStr()))
+}
+
+func TestULIDValue(t *testing.T) {
+	// the timestamp of the example in ULID spec
+	id := newULID(time.Unix(0, 1469918176385*int64(time.Millisecond)), make([]byte, 10))
+	assert.Len(t, id, 26)
+	assert.Equal(t, "01ARYZ6S41", id[:10])
+
+	// values are ordered by acquisition time
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithULIDValue())
+	assert.Nil(t, err)
+	var last string
+	for i := 0; i < 3; i++ {
+		_, err = lock.Lock(ctx, "foo", time.Second)
+		assert.Nil(t, err)
+		elem, err := lock.cache.Get("foo")
+		assert.Nil(t, err)
+		assert.Len(t, elem.Val, 26)
+		assert.Greater(t, elem.Val, last)
+		last = elem.Val
+		assert.Nil(t, lock.UnLock(ctx, "foo"))
+		time.Sleep(2 * time.Millisecond)
+	}
+	assert.Equal(t, "", OwnerOf(newULID(time.Now(), make([]byte, 10))))
+}
+
+func TestMockMaxValueBytes(t *testing.T) {
+	ctx := context.Background()
+	_, err := NewRedLock(ctx, []string{"tcp://mock0:6379"}, WithMaxValueBytes(0))
+	assert.NotNil(t, err)
+
+	attempts := int32(0)
+	instance := func() *mockCmdable {
+		return &mockCmdable{
+			setNX: func(context.Context, string, interface{}, time.Duration) (bool, error) {
+				atomic.AddInt32(&attempts, 1)
+				return true, nil
+			},
+		}
+	}
+	lock := newMockRedLockWithOptions(t, []redisCmdable{instance(), instance(), instance()}, WithMaxValueBytes(16))
+
+	validity, err := lock.LockWithValue(ctx, "foo", time.Second, strings.Repeat("v", 16))
+	assert.Nil(t, err)
+	assert.Greater(t, int64(validity), int64(0))
+	elem, err := lock.cache.Get("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, strings.Repeat("v", 16), elem.Val)
+	assert.Nil(t, lock.UnLock(ctx, "foo"))
+
+	// rejected before sent to redis
+	atomic.StoreInt32(&attempts, 0)
+	_, err = lock.LockWithValue(ctx, "foo", time.Second, strings.Repeat("v", 17))
+	assert.True(t, errors.Is(err, ErrValueTooLarge))
+	assert.Zero(t, atomic.LoadInt32(&attempts))
+
+	// generated values are checked as well, an owner may be arbitrarily long
+	WithOwnerProvider(func(context.Context) string { return "tenant" })(lock)
+	_, err = lock.Lock(ctx, "foo", time.Second)
+	assert.True(t, errors.Is(err, ErrValueTooLarge))
+	assert.Zero(t, atomic.LoadInt32(&attempts))
+}
+
+func TestExpiryInValue(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers, WithExpiryInValue(), WithIdentity("worker-1"),
+		WithOwnerProvider(func(context.Context) string { return "tenant-a" }))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	start := time.Now()
+	_, err = lock.LockWithPriority(ctx, "expiry-foo", time.Second, 2)
+	assert.Nil(t, err)
+	values, err := lock.Inspect(ctx, "expiry-foo")
+	assert.Nil(t, err)
+	assert.Len(t, values, len(redisServers))
+	for _, val := range values {
+		token, expiry, ok := ParseLockValue(val)
+		assert.True(t, ok)
+		assert.NotContains(t, token, expirySep)
+		assert.Contains(t, val, "tenant-a:"+token+expirySep)
+		assert.WithinDuration(t, start.Add(time.Second), expiry, 100*time.Millisecond)
+		assert.Equal(t, "tenant-a", OwnerOf(val))
+		assert.Equal(t, "worker-1", IdentityOf(val))
+	}
+	assert.Nil(t, lock.UnLock(ctx, "expiry-foo"))
+	values, err = lock.Inspect(ctx, "expiry-foo")
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+}
+
+func TestParseLockValue(t *testing.T) {
+	token := getRandStr()
+	expiry := time.Unix(1700000000, 123*int64(time.Millisecond))
+	suffix := ".1700000000123"
+	testCases := []struct {
+		val   string
+		token string
+		ok    bool
+	}{
+		{token + suffix, token, true},
+		{"a:b:" + token + suffix + "@host.example/1|5", token, true},
+		{"a|5:" + token + suffix, token, true},
+		{token, token, false},
+		{"a:" + token + "@host.example/1", token, false},
+		{token + ".x", token + ".x", false},
+	}
+	for _, tc := range testCases {
+		tok, exp, ok := ParseLockValue(tc.val)
+		assert.Equal(t, tc.ok, ok, tc.val)
+		assert.Equal(t, tc.token, tok, tc.val)
+		if ok {
+			assert.True(t, expiry.Equal(exp), tc.val)
+		}
+	}
+}
+
+func TestValuePrefix(t *testing.T) {
+	ctx := context.WithValue(context.Background(), tenantKey{}, "tenant-a")
+	lock, err := NewRedLock(ctx, redisServers, WithValuePrefix("v2:"), WithIdentity("host/1"),
+		WithOwnerProvider(func(ctx context.Context) string {
+			tenant, _ := ctx.Value(tenantKey{}).(string)
+			return tenant
+		}))
+	assert.Nil(t, err)
+	defer lock.Close()
+
+	_, err = lock.Lock(ctx, "prefix-foo", time.Second)
+	assert.Nil(t, err)
+	values, err := lock.Inspect(ctx, "prefix-foo")
+	assert.Nil(t, err)
+	assert.Len(t, values, len(redisServers))
+	for _, val := range values {
+		assert.True(t, strings.HasPrefix(val, "v2:tenant-a:"), val)
+		assert.Equal(t, "v2:tenant-a", OwnerOf(val))
+		assert.Equal(t, "tenant-a", lock.ownerOf(val))
+		assert.Equal(t, "host/1", IdentityOf(val))
+	}
+	assert.Nil(t, lock.UnLock(ctx, "prefix-foo"))
+	values, err = lock.Inspect(ctx, "prefix-foo")
+	assert.Nil(t, err)
+	assert.Empty(t, values)
+
+	// the prefix is the owner of a value without owner
+	clone, err := lock.Clone(WithOwnerProvider(nil))
+	assert.Nil(t, err)
+	defer clone.Close()
+	l, err := clone.Acquire(ctx, "prefix-foo", time.Second)
+	assert.Nil(t, err)
+	assert.Equal(t, "v2", OwnerOf(l.Value()))
+	assert.Equal(t, "", clone.ownerOf(l.Value()))
+	assert.Nil(t, l.Unlock(ctx))
+
+	for _, prefix := range []string{"v2@", "v2|"} {
+		_, err = NewRedLock(ctx, redisServers, WithValuePrefix(prefix))
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,73 @@
+package redlock
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// VerifyScript is redis lua script to read the remaining ttl in
+	// milliseconds of a lock held by us, it returns -1 if the lock is not
+	// held by us or has no ttl
+	VerifyScript = `
+        if redis.call("get", KEYS[1]) == ARGV[1] then
+            return redis.call("pttl", KEYS[1])
+        else
+            return -1
+        end
+        `
+)
+
+var verifyScript = newLuaScript(VerifyScript)
+
+// Verify re-reads the lock from all instances and confirms the value in
+// local cache is still held on a quorum, it returns the remaining ttl that
+// quorum holds the lock for, adjusted by the round trip cost and clock drift
+// the same as validity. The lock is not held if it is not in local cache.
+func (r *RedLock) Verify(ctx context.Context, resource string) (bool, time.Duration, error) {
+	elem, err := r.cache.Get(resource)
+	if err != nil {
+		return false, 0, err
+	}
+	if elem == nil {
+		return false, 0, nil
+	}
+	start := time.Now()
+	var (
+		wg    sync.WaitGroup
+		pttls = make([]int64, len(r.clients))
+		errs  = make([]error, len(r.clients))
+	)
+	for idx, cli := range r.clients {
+		idx, cli := idx, cli
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pttls[idx], errs[idx] = verifyScript.run(ctx, cli.cli, []string{r.redisKey(resource)}, elem.Val).Int64()
+		}()
+	}
+	wg.Wait()
+	held := make([]int64, 0, len(pttls))
+	for idx, pttl := range pttls {
+		if errs[idx] == nil && pttl > 0 {
+			held = append(held, pttl)
+		}
+	}
+	if len(held) < r.quorum {
+		for _, err := range errs {
+			if err != nil {
+				return false, 0, err
+			}
+		}
+		return false, 0, nil
+	}
+	// the lock is held on quorum until the quorum-th longest ttl expires
+	sort.Slice(held, func(i, j int) bool { return held[i] > held[j] })
+	remaining := r.validity(time.Duration(held[r.quorum-1])*time.Millisecond, start)
+	if remaining <= 0 {
+		return false, 0, nil
+	}
+	return true, time.Duration(remaining), nil
+}
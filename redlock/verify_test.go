@@ -0,0 +1,48 @@
+package redlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	lock, err := NewRedLock(ctx, redisServers)
+	assert.Nil(t, err)
+
+	// not locked by us
+	held, remaining, err := lock.Verify(ctx, "foo")
+	assert.Nil(t, err)
+	assert.False(t, held)
+	assert.Zero(t, remaining)
+
+	ttl := 2 * time.Second
+	_, err = lock.Lock(ctx, "foo", ttl)
+	assert.Nil(t, err)
+	held, remaining, err = lock.Verify(ctx, "foo")
+	assert.Nil(t, err)
+	assert.True(t, held)
+	assert.Greater(t, int64(remaining), int64(0))
+	assert.Less(t, int64(remaining), int64(ttl))
+
+	// the lock is taken away on a minority, it is still held on quorum
+	cli := rawClient(lock.clients[0])
+	assert.Nil(t, cli.Set(ctx, "foo", "others", time.Second).Err())
+	held, _, err = lock.Verify(ctx, "foo")
+	assert.Nil(t, err)
+	assert.True(t, held)
+
+	// the lock is taken away on a majority
+	cli = rawClient(lock.clients[1])
+	assert.Nil(t, cli.Set(ctx, "foo", "others", time.Second).Err())
+	held, _, err = lock.Verify(ctx, "foo")
+	assert.Nil(t, err)
+	assert.False(t, held)
+	for _, cli := range lock.clients {
+		rawClient(cli).Del(ctx, "foo")
+	}
+	lock.cache.Delete("foo")
+}